@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"lab-devops/internal/labpack"
+	"lab-devops/internal/service"
+	"os"
+	"text/tabwriter"
+)
+
+const labsUsage = "uso: lab-api labs [import [--dry-run] <pasta> | export <pasta>]"
+
+// runLabPacks implementa o subcomando labs: importa ou exporta os lab packs
+// sem arrancar o servidor.
+func runLabPacks(labSvc *service.LabService, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", labsUsage)
+	}
+
+	command, args := args[0], args[1:]
+	dryRun := false
+	if command == "import" && len(args) > 0 && args[0] == "--dry-run" {
+		dryRun, args = true, args[1:]
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("%s", labsUsage)
+	}
+	dir := args[0]
+
+	ctx := context.Background()
+	switch command {
+	case "import":
+		result, err := importLabPacks(ctx, labSvc, dir, dryRun)
+		if result != nil {
+			printLabPackImport(result)
+		}
+		return err
+	case "export":
+		packs, err := labSvc.ExportLabPacks(ctx)
+		if err != nil {
+			return err
+		}
+		if err := labpack.WriteDir(dir, packs); err != nil {
+			return fmt.Errorf("falha ao escrever os lab packs: %w", err)
+		}
+		fmt.Printf("%d pack(s) exportado(s) para %s\n", len(packs), dir)
+		return nil
+	}
+	return fmt.Errorf("comando desconhecido %q\n%s", command, labsUsage)
+}
+
+// importLabPacks lê os packs da pasta dir e importa-os.
+func importLabPacks(ctx context.Context, labSvc *service.LabService, dir string, dryRun bool) (*service.LabPackImport, error) {
+	packs, err := labpack.Load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	return labSvc.ImportLabPacks(ctx, packs, dryRun)
+}
+
+func printLabPackImport(result *service.LabPackImport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIPO\tID\tTÍTULO\tRESULTADO")
+	for _, t := range result.Tracks {
+		fmt.Fprintf(w, "trilha\t%s\t%s\t%s\n", t.ID, t.Title, t.Action)
+	}
+	for _, l := range result.Labs {
+		fmt.Fprintf(w, "lab\t%s\t%s\t%s\n", l.ID, l.Title, l.Action)
+	}
+	w.Flush()
+	if result.DryRun {
+		fmt.Println("(simulação: nada foi gravado)")
+	}
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"lab-devops/internal/api"
+	"lab-devops/internal/domain"
 	"lab-devops/internal/executor"
+	"lab-devops/internal/gitsource"
 	"lab-devops/internal/repository"
 	"lab-devops/internal/service"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -19,32 +25,174 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Valor inválido para %s: %v", key, err)
+	}
+	return parsed
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("Valor inválido para %s: %v", key, err)
+	}
+	return parsed
+}
+
+func getEnvBool(key string, fallback bool) *bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return &fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("Valor inválido para %s: %v", key, err)
+	}
+	return &parsed
+}
+
+func getEnvList(key, fallback string) []string {
+	var items []string
+	for _, item := range strings.Split(getEnv(key, fallback), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
 	// Configurações via Variáveis de Ambiente
 	sqliteDBPath := getEnv("DB_PATH", "./data/lab.db")
-	migrationsPath := getEnv("MIGRATIONS_PATH", "./db/migrations/001_init_schema.sql")
+	// Com DATABASE_URL a API usa o PostgreSQL, que várias réplicas podem
+	// partilhar, senão o SQLite em DB_PATH
+	databaseURL := getEnv("DATABASE_URL", "")
+	// Vazio usa as migrações embutidas no binário
+	migrationsPath := getEnv("MIGRATIONS_PATH", "")
 	dockerNetwork := getEnv("DOCKER_NETWORK", "minha-rede-lab")
 	tempDirRoot := getEnv("TEMP_DIR_ROOT", "/app/data/temp-exec")
 	serverPort := getEnv("SERVER_PORT", ":8080")
+	adminToken := getEnv("ADMIN_TOKEN", "")
+
+	// lab-api migrate ...: gere as migrações e sai sem arrancar o servidor
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		driver, dsn := repository.DriverSQLite, sqliteDBPath
+		if databaseURL != "" {
+			driver, dsn = repository.DriverPostgres, databaseURL
+		}
+		if err := runMigrate(driver, dsn, migrationsPath, os.Args[2:]); err != nil {
+			log.Fatalf("Falha nas migrações: %v", err)
+		}
+		return
+	}
+
+	// Política de recursos padrão dos containers dos labs
+	defaultLimits := domain.ResourceLimits{
+		TimeoutSeconds:   getEnvInt("LAB_TIMEOUT_SECONDS", 600),
+		CPUs:             getEnvFloat("LAB_CPUS", 1),
+		MemoryMB:         int64(getEnvInt("LAB_MEMORY_MB", 1024)),
+		PidsLimit:        int64(getEnvInt("LAB_PIDS_LIMIT", 256)),
+		ReadOnlyRootfs:   getEnvBool("LAB_READ_ONLY_ROOTFS", false),
+		DropCapabilities: getEnvList("LAB_CAP_DROP", "NET_RAW,MKNOD,AUDIT_WRITE"),
+	}
 
 	// 1. Camada de Infraestrutura (Implementações)
-	repo, err := repository.NewSQLiteRepository(sqliteDBPath, migrationsPath)
+	var repo service.WorkspaceRepository
+	var err error
+	if databaseURL != "" {
+		repo, err = repository.NewPostgresRepository(databaseURL, migrationsPath)
+	} else {
+		repo, err = repository.NewSQLiteRepository(sqliteDBPath, migrationsPath)
+	}
 	if err != nil {
-		log.Fatalf("Falha ao iniciar o repositório SQLite: %v", err)
+		log.Fatalf("Falha ao iniciar o repositório: %v", err)
+	}
+
+	// lab-api labs ...: importa ou exporta os lab packs e sai
+	if len(os.Args) > 1 && os.Args[1] == "labs" {
+		if err := runLabPacks(service.NewLabService(repo, nil, nil), os.Args[2:]); err != nil {
+			log.Fatalf("Falha nos lab packs: %v", err)
+		}
+		return
 	}
 
-	exec, err := executor.NewDockerExecutor(dockerNetwork, tempDirRoot)
+	var poolTypes []domain.ExecutionType
+	for _, t := range getEnvList("POOL_TYPES", "terraform,ansible,linux,docker,kubernetes,github-actions") {
+		poolTypes = append(poolTypes, domain.ExecutionType(t))
+	}
+
+	exec, err := executor.NewDockerExecutor(dockerNetwork, tempDirRoot, defaultLimits, getEnvInt("POOL_SIZE", 0), poolTypes)
 	if err != nil {
 		log.Fatalf("Falha ao iniciar o Docker executor: %v", err)
 	}
 
 	// 2. Camada de Lógica de Negócios (Serviço)
 	// (Injeta as implementações nas interfaces)
-	labSvc := service.NewLabService(repo, exec)
-	healthSvc := service.NewHealthService(repo)
+	scheduler := service.NewExecutionScheduler(
+		getEnvInt("MAX_CONCURRENT_EXECUTIONS", 4),
+		getEnvInt("MAX_EXECUTIONS_PER_USER", 1),
+		getEnvInt("MAX_INTERACTIVE_CONTAINERS", 8),
+	)
+	labSvc := service.NewLabService(repo, exec, scheduler)
+
+	// Conteúdo dos labs versionado em lab packs, importado a cada arranque
+	if packsPath := getEnv("LAB_PACKS_PATH", ""); packsPath != "" {
+		if _, err := importLabPacks(context.Background(), labSvc, packsPath, false); err != nil {
+			log.Printf("ERRO [LabPacks]: Falha ao importar os lab packs de %s: %v", packsPath, err)
+		}
+	}
+	pools, _ := exec.(service.PoolStatsProvider)
+	healthSvc := service.NewHealthService(repo, pools)
+	userSvc := service.NewUserService(repo)
+	authSvc := service.NewAuthService(repo)
+
+	if err := authSvc.BootstrapAdmin(context.Background(), adminToken); err != nil {
+		log.Fatalf("Falha ao registar o token de administrador: %v", err)
+	}
+
+	// Catálogo de imagens: aplica os digests fixados e, opcionalmente, baixa
+	// em segundo plano as imagens em falta
+	images, ok := exec.(service.ImageManager)
+	if !ok {
+		log.Fatalf("O executor não suporta a gestão de imagens")
+	}
+	imageSvc := service.NewImageService(repo, images)
+	if err := imageSvc.LoadCatalog(context.Background()); err != nil {
+		log.Fatalf("Falha ao carregar o catálogo de imagens: %v", err)
+	}
+	if *getEnvBool("IMAGE_PREFETCH", true) {
+		go imageSvc.Prefetch(context.Background())
+	}
+
+	// Terminais interativos nos containers dos labs
+	terminals, _ := exec.(service.TerminalProvider)
+	terminalSvc := service.NewTerminalService(labSvc, terminals, service.TerminalLimits{
+		IdleTimeout: time.Duration(getEnvInt("TERMINAL_IDLE_TIMEOUT_SECONDS", 600)) * time.Second,
+		MaxDuration: time.Duration(getEnvInt("TERMINAL_MAX_SECONDS", 3600)) * time.Second,
+	})
+
+	// Sessões de lab sem passos durante este tempo são terminadas
+	go labSvc.ReapIdleSessions(context.Background(), time.Duration(getEnvInt("SESSION_IDLE_TIMEOUT_SECONDS", 900))*time.Second)
+
+	// Catálogo de labs sincronizado a partir de um repositório Git
+	var catalogSource service.CatalogSource
+	if gitPath := getEnv("CATALOG_GIT_PATH", ""); gitPath != "" {
+		catalogSource = gitsource.New(gitPath, getEnv("CATALOG_GIT_SUBDIR", ""))
+	}
+	catalogSvc := service.NewCatalogService(repo, labSvc, catalogSource, getEnv("CATALOG_GIT_REF", "HEAD"))
 
 	// 3. Camada de Apresentação (API/Handlers)
-	handler := api.NewHandler(labSvc, healthSvc)
+	handler := api.NewHandler(labSvc, healthSvc, userSvc, authSvc, imageSvc, terminalSvc, catalogSvc)
 
 	// 4. Configuração do Servidor Web (Echo)
 	e := echo.New()
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"lab-devops/internal/repository"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = "uso: lab-api migrate [status | up | down [passos]]"
+
+// runMigrate implementa o subcomando migrate: mostra o estado das migrações
+// ou aplica/reverte-as sem arrancar o servidor.
+func runMigrate(driver repository.Driver, dsn, migrationsPath string, args []string) error {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "status", "up", "down":
+	default:
+		return fmt.Errorf("comando desconhecido %q\n%s", command, migrateUsage)
+	}
+
+	steps := 1
+	if command == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("número de passos inválido: %q", args[1])
+		}
+		steps = n
+	}
+
+	db, err := repository.OpenDatabase(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fsys, err := repository.MigrationsFS(driver, migrationsPath)
+	if err != nil {
+		return err
+	}
+	migrator, err := repository.NewMigrator(db, driver, fsys)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSÃO\tNOME\tAPLICADA\tREVERSÍVEL")
+		for _, s := range statuses {
+			applied := "pendente"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			reversible := "não"
+			if s.Reversible {
+				reversible = "sim"
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, applied, reversible)
+		}
+		return w.Flush()
+	case "up":
+		done, err := migrator.Up(ctx)
+		log.Printf("INFO [Migrate]: %d migração(ões) aplicada(s)", len(done))
+		return err
+	case "down":
+		done, err := migrator.Down(ctx, steps)
+		log.Printf("INFO [Migrate]: %d migração(ões) revertida(s)", len(done))
+		return err
+	}
+	return nil
+}
@@ -76,7 +76,7 @@ func TestReproListTracks(t *testing.T) {
 		t.Fatalf("Failed to create repo: %v", err)
 	}
 
-	svc := service.NewLabService(repo, nil) // executor can be nil for ListTracks
+	svc := service.NewLabService(repo, nil, nil) // executor and scheduler can be nil for ListTracks
 
 	// 5. Call ListTracks
 	tracks, err := svc.ListTracks(context.Background())
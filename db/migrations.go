@@ -0,0 +1,10 @@
+// Package db contém as migrações SQL da base de dados, embutidas no binário.
+package db
+
+import "embed"
+
+// Migrations contém os ficheiros de db/migrations: os do SQLite na raiz e os
+// do PostgreSQL em postgres/ (ver repository.Migrator).
+//
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var Migrations embed.FS
@@ -16,6 +16,18 @@ CREATE TABLE IF NOT EXISTS labs (
     
     /* O código que o sistema roda para provar se o aluno acertou */
     validation_code TEXT, 
+
+    /* Política de recursos do container (JSON, ver domain.ResourceLimits) */
+    resource_limits TEXT,
+
+    /* Imagem própria do lab (JSON, ver domain.LabImage) */
+    image           TEXT,
+
+    /* Verificações estruturadas da solução (JSON, ver domain.ValidationCheck) */
+    validation_checks TEXT,
+
+    /* Dicas progressivas (JSON, ver domain.Hint) */
+    hints           TEXT,
     
     created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     track_id        TEXT,
@@ -24,17 +36,128 @@ CREATE TABLE IF NOT EXISTS labs (
     FOREIGN KEY (track_id) REFERENCES tracks(id)
 );
 
-/* 3. Tabela de Workspaces */
+/* 3. Tabela de Utilizadores */
+CREATE TABLE IF NOT EXISTS users (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    email      TEXT NOT NULL UNIQUE,
+    role       TEXT NOT NULL DEFAULT 'learner',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+/* Tokens de acesso à API (apenas o hash SHA-256 é guardado) */
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+/* 4. Tabela de Workspaces (um por utilizador e por lab) */
 CREATE TABLE IF NOT EXISTS workspaces (
     id         TEXT PRIMARY KEY,
     lab_id     TEXT NOT NULL,
+    user_id    TEXT NOT NULL DEFAULT '',
     user_code  TEXT NOT NULL,
     state      BLOB,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     status     TEXT NOT NULL DEFAULT 'in_progress',
+
+    /* Pontuação e relatório da última validação (JSON, ver domain.ValidationReport) */
+    score             INTEGER NOT NULL DEFAULT 0,
+    max_score         INTEGER NOT NULL DEFAULT 0,
+    validation_report TEXT,
+
     FOREIGN KEY (lab_id) REFERENCES labs (id)
 );
 
+/* Um único workspace por utilizador e por lab (ver CreateWorkspace) */
+CREATE UNIQUE INDEX IF NOT EXISTS ux_workspaces_user_lab ON workspaces (user_id, lab_id);
+
+/* Ficheiros adicionais de cada workspace (o ficheiro principal fica em user_code) */
+CREATE TABLE IF NOT EXISTS workspace_files (
+    workspace_id TEXT NOT NULL,
+    path         TEXT NOT NULL,
+    content      TEXT NOT NULL,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, path),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id)
+);
+
+/* Histórico de execuções, com o output completo */
+CREATE TABLE IF NOT EXISTS executions (
+    id                   TEXT PRIMARY KEY,
+    workspace_id         TEXT NOT NULL,
+    user_id              TEXT NOT NULL,
+    action               TEXT NOT NULL,
+    outcome              TEXT NOT NULL DEFAULT '',
+    exit_code            INTEGER,
+    validation_exit_code INTEGER,
+    validation_passed    INTEGER,
+    error                TEXT NOT NULL DEFAULT '',
+    output               TEXT NOT NULL DEFAULT '',
+    started_at           TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at          TIMESTAMP,
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id)
+);
+
+/* Revisões do código submetido em cada execução (files em JSON) */
+CREATE TABLE IF NOT EXISTS workspace_revisions (
+    workspace_id TEXT NOT NULL,
+    revision     INTEGER NOT NULL,
+    mode         TEXT NOT NULL DEFAULT 'apply',
+    outcome      TEXT NOT NULL DEFAULT '',
+    code         TEXT NOT NULL,
+    files        TEXT,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, revision),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id)
+);
+
+/* Histórico do estado Terraform de cada workspace (uma linha por gravação) */
+CREATE TABLE IF NOT EXISTS workspace_state_versions (
+    workspace_id  TEXT NOT NULL,
+    version       INTEGER NOT NULL,
+    serial        INTEGER NOT NULL DEFAULT 0,
+    lineage       TEXT NOT NULL DEFAULT '',
+    state         BLOB NOT NULL,
+    restored_from INTEGER,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, version),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id)
+);
+
+/* Dicas reveladas em cada workspace (hint_index é a posição em labs.hints) */
+CREATE TABLE IF NOT EXISTS workspace_hints (
+    workspace_id TEXT NOT NULL,
+    hint_index   INTEGER NOT NULL,
+    penalty      INTEGER NOT NULL DEFAULT 0,
+    revealed_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, hint_index),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id)
+);
+
+/* Catálogo de imagens por tipo de lab */
+CREATE TABLE IF NOT EXISTS images (
+    exec_type  TEXT PRIMARY KEY,
+    reference  TEXT NOT NULL,
+    digest     TEXT NOT NULL DEFAULT '',
+    status     TEXT NOT NULL DEFAULT 'pending',
+    error      TEXT NOT NULL DEFAULT '',
+    pulled_at  TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+/* Imagens que os autores podem usar nos labs (ver domain.AllowedImage) */
+CREATE TABLE IF NOT EXISTS allowed_images (
+    id         TEXT PRIMARY KEY,
+    pattern    TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 /* --- SEED DATA --- */
 
 /* Exemplo de Lab com Validação (CKA) */
@@ -0,0 +1,2 @@
+/* Reverte 002_catalog_syncs */
+DROP TABLE IF EXISTS catalog_syncs;
@@ -0,0 +1,3 @@
+/* Progressão nas trilhas: trilhas sequenciais e pré-requisitos dos labs */
+ALTER TABLE tracks ADD COLUMN sequential BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE labs ADD COLUMN prerequisites TEXT; /* JSON: IDs dos labs a concluir antes */
@@ -0,0 +1,2 @@
+/* Reverte 004_unique_workspaces (os duplicados apagados não são repostos) */
+DROP INDEX IF EXISTS ux_workspaces_user_lab;
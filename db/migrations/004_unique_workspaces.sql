@@ -0,0 +1,22 @@
+/* Um único workspace por utilizador e por lab. Dos duplicados fica o
+   atualizado mais recentemente; os restantes saem com os seus ficheiros,
+   execuções, revisões, versões do estado e dicas. */
+CREATE TEMPORARY TABLE duplicate_workspaces AS
+SELECT w.id FROM workspaces w
+WHERE EXISTS (
+    SELECT 1 FROM workspaces o
+    WHERE o.user_id = w.user_id AND o.lab_id = w.lab_id AND o.id <> w.id
+      AND (o.updated_at > w.updated_at
+           OR (o.updated_at = w.updated_at AND o.id > w.id)
+           OR (w.updated_at IS NULL AND (o.updated_at IS NOT NULL OR o.id > w.id)))
+);
+
+DELETE FROM workspace_files WHERE workspace_id IN (SELECT id FROM duplicate_workspaces);
+DELETE FROM executions WHERE workspace_id IN (SELECT id FROM duplicate_workspaces);
+DELETE FROM workspace_revisions WHERE workspace_id IN (SELECT id FROM duplicate_workspaces);
+DELETE FROM workspace_state_versions WHERE workspace_id IN (SELECT id FROM duplicate_workspaces);
+DELETE FROM workspace_hints WHERE workspace_id IN (SELECT id FROM duplicate_workspaces);
+DELETE FROM workspaces WHERE id IN (SELECT id FROM duplicate_workspaces);
+DROP TABLE duplicate_workspaces;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_workspaces_user_lab ON workspaces (user_id, lab_id);
@@ -0,0 +1,13 @@
+/* Reverte 001_init_schema: remove todas as tabelas e os seus dados */
+DROP TABLE IF EXISTS allowed_images;
+DROP TABLE IF EXISTS images;
+DROP TABLE IF EXISTS workspace_hints;
+DROP TABLE IF EXISTS workspace_state_versions;
+DROP TABLE IF EXISTS workspace_revisions;
+DROP TABLE IF EXISTS executions;
+DROP TABLE IF EXISTS workspace_files;
+DROP TABLE IF EXISTS workspaces;
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS labs;
+DROP TABLE IF EXISTS tracks;
@@ -0,0 +1,129 @@
+/* Esquema inicial no PostgreSQL, equivalente ao db/migrations/001_init_schema.sql.
+   Sem chaves estrangeiras: o SQLite não as aplica e o repositório conta com
+   isso (labs com track_id vazio, labs apagados com workspaces). */
+
+CREATE TABLE IF NOT EXISTS tracks (
+    id          TEXT PRIMARY KEY,
+    title       TEXT NOT NULL,
+    description TEXT,
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS labs (
+    id                TEXT PRIMARY KEY,
+    title             TEXT NOT NULL,
+    type              TEXT NOT NULL,
+    instructions      TEXT NOT NULL,
+    initial_code      TEXT NOT NULL,
+    validation_code   TEXT,
+    resource_limits   TEXT,
+    image             TEXT,
+    validation_checks TEXT,
+    hints             TEXT,
+    created_at        TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    track_id          TEXT,
+    lab_order         INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    email      TEXT NOT NULL UNIQUE,
+    role       TEXT NOT NULL DEFAULT 'learner',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS workspaces (
+    id                TEXT PRIMARY KEY,
+    lab_id            TEXT NOT NULL,
+    user_id           TEXT NOT NULL DEFAULT '',
+    user_code         TEXT NOT NULL,
+    state             BYTEA,
+    updated_at        TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    status            TEXT NOT NULL DEFAULT 'in_progress',
+    score             INTEGER NOT NULL DEFAULT 0,
+    max_score         INTEGER NOT NULL DEFAULT 0,
+    validation_report TEXT
+);
+
+CREATE TABLE IF NOT EXISTS workspace_files (
+    workspace_id TEXT NOT NULL,
+    path         TEXT NOT NULL,
+    content      TEXT NOT NULL,
+    updated_at   TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, path)
+);
+
+CREATE TABLE IF NOT EXISTS executions (
+    id                   TEXT PRIMARY KEY,
+    workspace_id         TEXT NOT NULL,
+    user_id              TEXT NOT NULL,
+    action               TEXT NOT NULL,
+    outcome              TEXT NOT NULL DEFAULT '',
+    exit_code            INTEGER,
+    validation_exit_code INTEGER,
+    validation_passed    BOOLEAN,
+    error                TEXT NOT NULL DEFAULT '',
+    output               TEXT NOT NULL DEFAULT '',
+    started_at           TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    finished_at          TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS workspace_revisions (
+    workspace_id TEXT NOT NULL,
+    revision     INTEGER NOT NULL,
+    mode         TEXT NOT NULL DEFAULT 'apply',
+    outcome      TEXT NOT NULL DEFAULT '',
+    code         TEXT NOT NULL,
+    files        TEXT,
+    created_at   TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, revision)
+);
+
+CREATE TABLE IF NOT EXISTS workspace_state_versions (
+    workspace_id  TEXT NOT NULL,
+    version       INTEGER NOT NULL,
+    serial        INTEGER NOT NULL DEFAULT 0,
+    lineage       TEXT NOT NULL DEFAULT '',
+    state         BYTEA NOT NULL,
+    restored_from INTEGER,
+    created_at    TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS workspace_hints (
+    workspace_id TEXT NOT NULL,
+    hint_index   INTEGER NOT NULL,
+    penalty      INTEGER NOT NULL DEFAULT 0,
+    revealed_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, hint_index)
+);
+
+CREATE TABLE IF NOT EXISTS images (
+    exec_type  TEXT PRIMARY KEY,
+    reference  TEXT NOT NULL,
+    digest     TEXT NOT NULL DEFAULT '',
+    status     TEXT NOT NULL DEFAULT 'pending',
+    error      TEXT NOT NULL DEFAULT '',
+    pulled_at  TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS allowed_images (
+    id         TEXT PRIMARY KEY,
+    pattern    TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+/* Com várias réplicas as consultas por utilizador e por workspace são frequentes */
+CREATE UNIQUE INDEX IF NOT EXISTS ux_workspaces_user_lab ON workspaces (user_id, lab_id);
+CREATE INDEX IF NOT EXISTS idx_executions_workspace ON executions (workspace_id, started_at);
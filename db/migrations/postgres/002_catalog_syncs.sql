@@ -0,0 +1,13 @@
+/* Sincronizações do catálogo de labs com um repositório Git */
+CREATE TABLE IF NOT EXISTS catalog_syncs (
+    id         TEXT PRIMARY KEY,
+    source     TEXT NOT NULL,
+    ref        TEXT NOT NULL,
+    commit_sha TEXT NOT NULL,
+    track_ids  TEXT NOT NULL DEFAULT '[]', /* JSON: trilhas definidas pelo commit */
+    lab_ids    TEXT NOT NULL DEFAULT '[]', /* JSON: labs definidos pelo commit */
+    changes    TEXT NOT NULL DEFAULT '[]', /* JSON: alterações aplicadas */
+    synced_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_catalog_syncs_synced_at ON catalog_syncs (synced_at);
@@ -0,0 +1,3 @@
+/* Reverte 003_lab_prerequisites */
+ALTER TABLE labs DROP COLUMN IF EXISTS prerequisites;
+ALTER TABLE tracks DROP COLUMN IF EXISTS sequential;
@@ -0,0 +1,3 @@
+/* Progressão nas trilhas: trilhas sequenciais e pré-requisitos dos labs */
+ALTER TABLE tracks ADD COLUMN IF NOT EXISTS sequential BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE labs ADD COLUMN IF NOT EXISTS prerequisites TEXT; /* JSON: IDs dos labs a concluir antes */
@@ -0,0 +1,3 @@
+/* Reverte 004_unique_workspaces (os duplicados apagados não são repostos) */
+DROP INDEX IF EXISTS ux_workspaces_user_lab;
+CREATE INDEX IF NOT EXISTS idx_workspaces_user_lab ON workspaces (user_id, lab_id);
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CreateUserRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type CreateTokenRequest struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// HandleCreateUser regista um novo utilizador
+// POST /api/v1/admin/users
+func (h *Handler) HandleCreateUser(c echo.Context) error {
+	var req CreateUserRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Payload inválido"})
+	}
+
+	user, err := h.userService.CreateUser(c.Request().Context(), req.Name, req.Email, req.Role)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, user)
+}
+
+// HandleListUsers lista os utilizadores registados
+// GET /api/v1/admin/users
+func (h *Handler) HandleListUsers(c echo.Context) error {
+	users, err := h.userService.ListUsers(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, users)
+}
+
+// HandleUpdateUserRole altera o papel de um utilizador
+// PATCH /api/v1/admin/users/:userId
+func (h *Handler) HandleUpdateUserRole(c echo.Context) error {
+	var req UpdateUserRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Payload inválido"})
+	}
+
+	user, err := h.userService.UpdateUserRole(c.Request().Context(), c.Param("userId"), req.Role)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// HandleCreateToken emite um token para um utilizador. O valor do token só é
+// devolvido nesta resposta.
+// POST /api/v1/admin/tokens
+func (h *Handler) HandleCreateToken(c echo.Context) error {
+	var req CreateTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Payload inválido"})
+	}
+
+	plain, token, err := h.authService.IssueToken(c.Request().Context(), req.UserID, req.Name)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	response := struct {
+		Token    string      `json:"token"`
+		Metadata interface{} `json:"metadata"`
+	}{
+		Token:    plain,
+		Metadata: token,
+	}
+	return c.JSON(http.StatusCreated, response)
+}
+
+// HandleListTokens lista os tokens emitidos (sem o valor)
+// GET /api/v1/admin/tokens
+func (h *Handler) HandleListTokens(c echo.Context) error {
+	tokens, err := h.authService.ListTokens(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// HandleRevokeToken revoga um token
+// DELETE /api/v1/admin/tokens/:tokenId
+func (h *Handler) HandleRevokeToken(c echo.Context) error {
+	if err := h.authService.RevokeToken(c.Request().Context(), c.Param("tokenId")); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Token revogado com sucesso"})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const userContextKey = "user"
+
+// Authenticate identifica o utilizador pelo token enviado em
+// "Authorization: Bearer <token>". O parâmetro token na query é aceite como
+// alternativa porque os browsers não permitem cabeçalhos customizados no
+// handshake do WebSocket.
+func (h *Handler) Authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := ""
+		if auth := c.Request().Header.Get(echo.HeaderAuthorization); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+		if token == "" {
+			token = c.QueryParam("token")
+		}
+		if token == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "token de acesso ausente"})
+		}
+
+		user, err := h.authService.Authenticate(c.Request().Context(), token)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidToken) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+
+		c.Set(userContextKey, user)
+		return next(c)
+	}
+}
+
+// RequireRole restringe a rota aos papéis informados. Deve ser usado depois
+// do Authenticate.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := currentUser(c)
+			if user == nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "utilizador não autenticado"})
+			}
+			if !user.HasRole(roles...) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "permissão insuficiente"})
+			}
+			return next(c)
+		}
+	}
+}
+
+// currentUser devolve o utilizador resolvido pelo Authenticate.
+func currentUser(c echo.Context) *domain.User {
+	user, _ := c.Get(userContextKey).(*domain.User)
+	return user
+}
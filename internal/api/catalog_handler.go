@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"lab-devops/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// catalogErrorStatus traduz os erros da sincronização do catálogo.
+func catalogErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrCatalogSyncUnavailable):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrCatalogSyncBusy):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrCatalogRefNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidLabPack):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrImageNotAllowed):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+type CatalogSyncRequest struct {
+	Ref string `json:"ref"`
+}
+
+// HandleCatalogDiff mostra o que a sincronização com o Git mudaria, sem
+// gravar nada
+// GET /api/v1/admin/catalog/diff[?ref=main]
+func (h *Handler) HandleCatalogDiff(c echo.Context) error {
+	plan, err := h.catalog.Plan(c.Request().Context(), c.QueryParam("ref"))
+	if err != nil {
+		return c.JSON(catalogErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, plan)
+}
+
+// HandleCatalogSync sincroniza as trilhas e os labs com o Git
+// POST /api/v1/admin/catalog/sync
+func (h *Handler) HandleCatalogSync(c echo.Context) error {
+	var req CatalogSyncRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Payload inválido"})
+	}
+
+	record, err := h.catalog.Sync(c.Request().Context(), req.Ref)
+	if err != nil {
+		return c.JSON(catalogErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, record)
+}
+
+// HandleListCatalogSyncs lista as últimas sincronizações do catálogo
+// GET /api/v1/admin/catalog/syncs[?limit=20]
+func (h *Handler) HandleListCatalogSyncs(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	syncs, err := h.catalog.ListSyncs(c.Request().Context(), limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, syncs)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+// maxReplayInterval limita o intervalo entre linhas na reprodução de logs.
+const maxReplayInterval = time.Second
+
+// executionErrorStatus traduz os erros das operações sobre o histórico de execuções.
+func executionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrExecutionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrForbidden):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// HandleListExecutions lista as execuções de um workspace
+// GET /api/v1/workspaces/:workspaceID/executions
+func (h *Handler) HandleListExecutions(c echo.Context) error {
+	records, err := h.labService.ListExecutions(c.Request().Context(), currentUser(c), c.Param("workspaceID"))
+	if err != nil {
+		return c.JSON(executionErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, records)
+}
+
+// HandleExecutionLogs devolve o output de uma execução em texto, ou
+// reprodu-lo linha a linha quando o pedido é um upgrade para WebSocket
+// GET /api/v1/executions/:executionID/logs[?interval_ms=200]
+func (h *Handler) HandleExecutionLogs(c echo.Context) error {
+	rec, err := h.labService.GetExecution(c.Request().Context(), currentUser(c), c.Param("executionID"))
+	if err != nil {
+		return c.JSON(executionErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	if !websocket.IsWebSocketUpgrade(c.Request()) {
+		return c.String(http.StatusOK, rec.Output)
+	}
+
+	var interval time.Duration
+	if ms, err := strconv.Atoi(c.QueryParam("interval_ms")); err == nil && ms > 0 {
+		interval = min(time.Duration(ms)*time.Millisecond, maxReplayInterval)
+	}
+	return h.replayExecution(c, rec, interval)
+}
+
+// replayExecution reproduz o output guardado numa nova conexão WebSocket.
+func (h *Handler) replayExecution(c echo.Context, rec *domain.ExecutionRecord, interval time.Duration) error {
+	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		log.Printf("ERRO [Handler]: Falha no upgrade do websocket: %v", err)
+		return err
+	}
+	defer ws.Close()
+
+	sendRecordedExecution(c.Request().Context(), ws, rec, interval)
+	ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return nil
+}
+
+// jsonWriter é a parte da conexão WebSocket usada para enviar mensagens.
+type jsonWriter interface {
+	WriteJSON(v interface{}) error
+}
+
+// sendRecordedExecution envia o output guardado como mensagens "log", tal
+// como na execução ao vivo, seguido de uma mensagem "execution" com o registo.
+func sendRecordedExecution(ctx context.Context, ws jsonWriter, rec *domain.ExecutionRecord, interval time.Duration) {
+	if rec.Output != "" {
+		for _, line := range strings.Split(strings.TrimSuffix(rec.Output, "\n"), "\n") {
+			if err := ws.WriteJSON(ServerMessage{Type: "log", Payload: line}); err != nil {
+				return
+			}
+			if interval > 0 {
+				select {
+				case <-time.After(interval):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+
+	ws.WriteJSON(ServerMessage{
+		Type:    "execution",
+		Payload: fmt.Sprintf("Execução %s terminada: %s", rec.Action, rec.Outcome),
+		Data:    rec,
+	})
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"lab-devops/internal/domain"
+)
+
+// recordingWriter guarda as mensagens enviadas pela conexão.
+type recordingWriter struct {
+	messages []ServerMessage
+}
+
+func (w *recordingWriter) WriteJSON(v interface{}) error {
+	w.messages = append(w.messages, v.(ServerMessage))
+	return nil
+}
+
+func TestSendRecordedExecution(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		wantLogs []string
+	}{
+		{"sem output", "", nil},
+		{"uma linha", "ok\n", []string{"ok"}},
+		{"várias linhas", "a iniciar\n\nconcluído\n", []string{"a iniciar", "", "concluído"}},
+		{"sem quebra final", "truncado", []string{"truncado"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &domain.ExecutionRecord{ID: "e1", Action: domain.ActionExecute, Outcome: domain.OutcomeSuccess, Output: tt.output}
+			var w recordingWriter
+			sendRecordedExecution(context.Background(), &w, rec, 0)
+
+			if len(w.messages) != len(tt.wantLogs)+1 {
+				t.Fatalf("mensagens enviadas: %+v", w.messages)
+			}
+			var logs []string
+			for _, m := range w.messages[:len(tt.wantLogs)] {
+				if m.Type != "log" {
+					t.Fatalf("mensagem %+v antes do fim, esperava \"log\"", m)
+				}
+				logs = append(logs, m.Payload)
+			}
+			if !reflect.DeepEqual(logs, tt.wantLogs) {
+				t.Errorf("linhas reproduzidas = %q, esperava %q", logs, tt.wantLogs)
+			}
+			if last := w.messages[len(w.messages)-1]; last.Type != "execution" || last.Data != rec {
+				t.Errorf("última mensagem = %+v, esperava o registo da execução", last)
+			}
+		})
+	}
+}
@@ -1,10 +1,15 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"lab-devops/internal/domain"
 	"lab-devops/internal/service"
 	"log"
 	"net/http"
+	"sync"
 
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
@@ -21,23 +26,107 @@ var (
 type Handler struct {
 	labService    *service.LabService
 	healthService *service.HealthService
+	userService   *service.UserService
+	authService   *service.AuthService
+	imageService  *service.ImageService
+	terminals     *service.TerminalService
+	catalog       *service.CatalogService
 }
 
-func NewHandler(svc *service.LabService, healthSvc *service.HealthService) *Handler {
+func NewHandler(svc *service.LabService, healthSvc *service.HealthService, userSvc *service.UserService, authSvc *service.AuthService, imageSvc *service.ImageService, terminalSvc *service.TerminalService, catalogSvc *service.CatalogService) *Handler {
 	return &Handler{
 		labService:    svc,
 		healthService: healthSvc,
+		userService:   userSvc,
+		authService:   authSvc,
+		imageService:  imageSvc,
+		terminals:     terminalSvc,
+		catalog:       catalogSvc,
 	}
 }
 
 type ClientMessage struct {
 	Action   string `json:"action"`
 	UserCode string `json:"user_code"`
+
+	// ExecutionID identifica a execução a retomar com "attach".
+	ExecutionID string `json:"execution_id,omitempty"`
+	// Command é o comando shell a correr na sessão com "command".
+	Command string `json:"command,omitempty"`
+
+	// Files é a árvore completa do workspace (caminho → conteúdo); Diff
+	// contém apenas as alterações. Ambos são opcionais.
+	Files map[string]string `json:"files,omitempty"`
+	Diff  *FilesDiff        `json:"diff,omitempty"`
+}
+
+// FilesDiff são as alterações à árvore do workspace enviadas com "execute".
+type FilesDiff struct {
+	Files   map[string]string `json:"files"`
+	Deleted []string          `json:"deleted"`
+}
+
+func (m ClientMessage) fileChanges() domain.FileChanges {
+	if m.Files != nil {
+		return domain.FileChanges{Replace: true, Files: m.Files}
+	}
+	if m.Diff != nil {
+		return domain.FileChanges{Files: m.Diff.Files, Deleted: m.Diff.Deleted}
+	}
+	return domain.FileChanges{}
 }
 
 type ServerMessage struct {
-	Type    string `json:"type"`
-	Payload string `json:"payload,omitempty"`
+	Type    string      `json:"type"`
+	Payload string      `json:"payload,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// StepResultPayload é a representação JSON de um domain.StepResult.
+type StepResultPayload struct {
+	Name     string `json:"name,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+	Error    string `json:"error,omitempty"`
+}
+
+// interruptionMessages são as mensagens enviadas ao cliente para cada
+// desfecho que interrompe a execução antes do fim.
+var interruptionMessages = map[string]string{
+	domain.OutcomeCancelled: "⏹️ Execução cancelada.",
+	domain.OutcomeTimeout:   "⏱️ A execução excedeu o tempo limite do laboratório.",
+	domain.OutcomeOOM:       "💥 A execução excedeu o limite de memória do laboratório.",
+}
+
+// wsConn serializa as escritas no WebSocket: a goroutine de streaming e o
+// loop de leitura (ex.: pedidos de dica) escrevem na mesma conexão.
+type wsConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+func (w *wsConn) WriteJSON(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Conn.WriteJSON(v)
+}
+
+func (w *wsConn) WriteMessage(messageType int, data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Conn.WriteMessage(messageType, data)
+}
+
+func newStepResultPayload(res domain.StepResult) StepResultPayload {
+	payload := StepResultPayload{
+		Name:     res.Name,
+		ExitCode: res.ExitCode,
+		Output:   res.Output,
+	}
+	if res.Error != nil {
+		payload.Error = res.Error.Error()
+	}
+	return payload
 }
 
 type CreateLabRequest struct {
@@ -48,20 +137,57 @@ type CreateLabRequest struct {
 	TrackID        string `json:"track_id"`
 	LabOrder       int    `json:"lab_order"`
 	ValidationCode string `json:"validation_code"`
+
+	Limits *domain.ResourceLimits   `json:"limits"`
+	Image  *domain.LabImage         `json:"image"`
+	Checks []domain.ValidationCheck `json:"checks"`
+	Hints  []domain.Hint            `json:"hints"`
+
+	Prerequisites []string `json:"prerequisites"`
+}
+
+func (r CreateLabRequest) limitsOrZero() domain.ResourceLimits {
+	if r.Limits == nil {
+		return domain.ResourceLimits{}
+	}
+	return *r.Limits
+}
+
+// labErrorStatus devolve 403 quando a imagem do lab não é permitida ou o lab
+// está bloqueado, e 400 para pré-requisitos inválidos.
+func labErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrImageNotAllowed), errors.Is(err, service.ErrLabLocked):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidPrerequisites):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// deleteLabErrorStatus devolve 409 quando outros labs dependem do lab a apagar.
+func deleteLabErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidPrerequisites) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
 }
 
 type CreateTrackRequest struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	Sequential  *bool  `json:"sequential"`
 }
 
 func (h *Handler) HandlerLabExecute(c echo.Context) error {
 	labID := c.Param("labID")
-	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	user := currentUser(c)
+	raw, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
 		log.Printf("ERRO [Handler]: Falha no upgrade do websocket: %v", err)
 		return err
 	}
+	ws := &wsConn{Conn: raw}
 
 	defer ws.Close()
 	log.Printf("INFO [Handler]: Cliente WebSocket conectado para Lab %s", labID)
@@ -72,23 +198,52 @@ func (h *Handler) HandlerLabExecute(c echo.Context) error {
 		return err
 	}
 
+	// A execução corre desligada da conexão: se o cliente sair, continua até
+	// ao fim e o estado final é gravado. Só {"action":"cancel"} a interrompe.
 	ctx := c.Request().Context()
 
-	// Variáveis para capturar o retorno do serviço
-	var logStream <-chan service.ExecutionResult
-	var finalState <-chan service.ExecutionFinalState
-	var wsID string
+	var run *service.LiveExecution
 	var errExec error
 
-	// Lógica de Decisão: Executar ou Validar?
+	// Lógica de Decisão: Executar, Validar ou retomar uma execução?
 	switch msg.Action {
 	case "execute":
 		log.Printf("INFO [Handler]: Executando comando do usuário (Lab %s)", labID)
-		logStream, finalState, wsID, errExec = h.labService.ExecuteLab(ctx, labID, msg.UserCode)
+		run, errExec = h.labService.ExecuteLab(ctx, user.ID, labID, msg.UserCode, msg.fileChanges())
 
 	case "validate":
 		log.Printf("INFO [Handler]: Validando solução (Lab %s)", labID)
-		logStream, finalState, wsID, errExec = h.labService.ValidateLab(ctx, labID)
+		run, errExec = h.labService.ValidateLab(ctx, user.ID, labID)
+
+	case "plan":
+		log.Printf("INFO [Handler]: Planeando alterações do usuário (Lab %s)", labID)
+		run, errExec = h.labService.PlanLab(ctx, user.ID, labID, msg.UserCode, msg.fileChanges())
+
+	case "destroy":
+		log.Printf("INFO [Handler]: Destruindo recursos do workspace (Lab %s)", labID)
+		run, errExec = h.labService.DestroyLab(ctx, user.ID, labID)
+
+	case "command":
+		log.Printf("INFO [Handler]: Comando na sessão (Lab %s)", labID)
+		run, errExec = h.labService.RunCommand(ctx, user.ID, labID, msg.Command)
+
+	case "attach":
+		log.Printf("INFO [Handler]: Cliente retomando execução %s (Lab %s)", msg.ExecutionID, labID)
+		run, errExec = h.labService.AttachExecution(ctx, user, msg.ExecutionID)
+		if errors.Is(errExec, service.ErrExecutionNotLive) {
+			// Já saiu da memória: reproduz o output guardado no histórico
+			rec, err := h.labService.GetExecution(ctx, user, msg.ExecutionID)
+			if err != nil {
+				ws.WriteJSON(ServerMessage{Type: "error", Payload: err.Error()})
+				return nil
+			}
+			sendRecordedExecution(ctx, ws, rec, 0)
+			return nil
+		}
+
+	case "hint":
+		h.sendHint(ctx, ws, user.ID, labID)
+		return nil
 
 	default:
 		log.Printf("AVISO [Handler]: Ação desconhecida: %s", msg.Action)
@@ -101,99 +256,172 @@ func (h *Handler) HandlerLabExecute(c echo.Context) error {
 		return errExec
 	}
 
-	// Loop de Streaming
-	go func() {
-		for {
-			select {
-			// Caso A: Nova linha de log do executor
-			case logLine, ok := <-logStream:
-				if !ok {
-					logStream = nil // Canal fechado
-					continue
-				}
-				if err := ws.WriteJSON(ServerMessage{Type: "log", Payload: logLine.Line}); err != nil {
-					log.Printf("AVISO [Handler]: Erro ao escrever log no ws: %v", err)
-					return
-				}
-
-			// Caso B: A execução terminou (Estado Final)
-			case state, ok := <-finalState:
-				if !ok {
-					return
-				}
-
-				// Se houve erro na execução do código do usuário
-				if state.Error != nil {
-					log.Printf("INFO [Handler]: Execução falhou: %v", state.Error)
-					ws.WriteJSON(ServerMessage{Type: "error", Payload: state.Error.Error()})
-					ws.WriteJSON(ServerMessage{Type: "log", Payload: "❌ A execução falhou. Verifique o seu código e tente novamente."})
-					return
-				}
-
-				// Execução OK — verificar validação
-				if state.ValidationResult.ExitCode != 0 && state.ValidationResult.Output != "" {
-					log.Printf("INFO [Handler]: Validação falhou (exit code %d)", state.ValidationResult.ExitCode)
-					ws.WriteJSON(ServerMessage{Type: "log", Payload: "❌ A validação falhou. Verifique a sua solução e tente novamente."})
-					return
-				}
-
-				// Tudo OK — Sucesso!
-				log.Printf("INFO [Handler]: Execução concluída com sucesso.")
-
-				if state.ValidationResult.ExitCode == 0 && state.ValidationResult.Output != "" {
-					// Validação passou → Marca como COMPLETED
-					log.Printf("INFO [Handler]: Lab validado! Salvando status completed.")
-					if err := h.labService.SaveWorkspaceStatus(ctx, wsID, domain.WorkspaceStatusCompleted); err != nil {
-						log.Printf("ERRO [Handler]: Falha ao salvar status: %v", err)
-					}
-					ws.WriteJSON(ServerMessage{Type: "complete", Payload: "✅ Parabéns! Laboratório concluído com sucesso."})
-				} else {
-					// Sem validação → Apenas avisa que terminou
-					ws.WriteJSON(ServerMessage{Type: "complete", Payload: "Comando executado."})
-				}
-
-				// Salva o estado do Terraform (se houver)
-				if state.NewState != nil {
-					h.labService.SaveWorkspaceState(ctx, wsID, state.NewState)
-				}
-				return
+	// O id permite retomar a execução noutra conexão ({"action":"attach"})
+	ws.WriteJSON(ServerMessage{
+		Type:    "started",
+		Payload: run.ID,
+		Data:    map[string]string{"execution_id": run.ID, "action": run.Action},
+	})
 
-			case <-ctx.Done():
-				log.Printf("AVISO [Handler]: Contexto cancelado.")
-				return
-			}
-		}
-	}()
+	go streamExecution(ctx, ws, run)
 
-	// Manter a conexão WebSocket viva até o cliente desconectar
+	// Manter a conexão WebSocket viva até o cliente desconectar,
+	// atendendo pedidos de cancelamento
 	for {
-		if _, _, err := ws.ReadMessage(); err != nil {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
 			log.Printf("INFO [Handler]: Cliente desconectado: %v", err)
 			break
 		}
+
+		var incoming ClientMessage
+		if err := json.Unmarshal(data, &incoming); err != nil {
+			continue
+		}
+		switch incoming.Action {
+		case "cancel":
+			// Quem apenas acompanha a execução de outro não a pode cancelar
+			if run.UserID != user.ID {
+				ws.WriteJSON(ServerMessage{Type: "error", Payload: service.ErrForbidden.Error()})
+				continue
+			}
+			log.Printf("INFO [Handler]: Cancelamento solicitado pelo cliente (Lab %s)", labID)
+			run.Cancel()
+		case "hint":
+			h.sendHint(ctx, ws, user.ID, labID)
+		}
 	}
 
 	return nil
 }
+
+// streamExecution envia o output da execução desde o início (as linhas já
+// produzidas e depois as novas) e, no fim, o seu resultado. Termina sem
+// afetar a execução quando o cliente se desliga.
+func streamExecution(ctx context.Context, ws *wsConn, run *service.LiveExecution) {
+	offset := 0
+	for {
+		lines, changed, final := run.Since(offset)
+		offset += len(lines)
+
+		for _, logLine := range lines {
+			if logLine.QueuePosition > 0 {
+				ws.WriteJSON(ServerMessage{
+					Type:    "queued",
+					Payload: fmt.Sprintf("⏳ A aguardar na fila de execução (posição %d).", logLine.QueuePosition),
+					Data:    map[string]int{"position": logLine.QueuePosition},
+				})
+				continue
+			}
+			if err := ws.WriteJSON(ServerMessage{Type: "log", Payload: logLine.Line}); err != nil {
+				log.Printf("AVISO [Handler]: Erro ao escrever log no ws: %v", err)
+				return
+			}
+		}
+
+		if final != nil {
+			sendFinalState(ws, run.Action, *final)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			log.Printf("AVISO [Handler]: Contexto cancelado; a execução %s continua.", run.ID)
+			return
+		}
+	}
+}
+
+// sendFinalState envia ao cliente o resultado de uma execução. O estado, o
+// relatório e o progresso do workspace já foram gravados pelo serviço.
+func sendFinalState(ws *wsConn, action string, state service.ExecutionFinalState) {
+	// Execução interrompida (cancelamento, timeout ou falta de
+	// memória): devolve o resultado parcial com um tipo próprio
+	if msg, interrupted := interruptionMessages[state.Outcome]; interrupted {
+		log.Printf("INFO [Handler]: Execução interrompida (workspace %s): %v", state.WorkspaceID, state.Error)
+		ws.WriteJSON(ServerMessage{
+			Type:    state.Outcome,
+			Payload: msg,
+			Data:    newStepResultPayload(state.ExecutionResult),
+		})
+		return
+	}
+
+	// Se houve erro na execução do código do usuário
+	if state.Error != nil {
+		log.Printf("INFO [Handler]: Execução falhou: %v", state.Error)
+		ws.WriteJSON(ServerMessage{Type: "error", Payload: state.Error.Error()})
+		ws.WriteJSON(ServerMessage{Type: "log", Payload: "❌ A execução falhou. Verifique o seu código e tente novamente."})
+		return
+	}
+
+	if action == domain.ActionDestroy {
+		ws.WriteJSON(ServerMessage{Type: "complete", Payload: "🧹 Recursos destruídos."})
+		return
+	}
+
+	// Modo plan: envia o resumo das alterações; o estado e o
+	// progresso do workspace não mudam
+	if state.Plan != nil {
+		p := state.Plan
+		ws.WriteJSON(ServerMessage{
+			Type:    "plan",
+			Payload: fmt.Sprintf("Plano: %d a criar, %d a alterar, %d a destruir.", p.Create, p.Update, p.Destroy),
+			Data:    p,
+		})
+		ws.WriteJSON(ServerMessage{Type: "complete", Payload: "Plano concluído. Envie \"execute\" para aplicar."})
+		return
+	}
+
+	// Relatório das verificações estruturadas, passe ou não, já com a
+	// penalização das dicas reveladas
+	if state.Report != nil {
+		ws.WriteJSON(ServerMessage{
+			Type:    "validation_report",
+			Payload: fmt.Sprintf("Pontuação: %d/%d", state.Report.Score, state.Report.MaxScore),
+			Data:    state.Report,
+		})
+	}
+
+	// Execução OK — verificar validação
+	if state.Validated && !state.ValidationPassed() {
+		log.Printf("INFO [Handler]: Validação falhou (exit code %d)", state.ValidationResult.ExitCode)
+		ws.WriteJSON(ServerMessage{Type: "log", Payload: "❌ A validação falhou. Verifique a sua solução e tente novamente."})
+		return
+	}
+
+	// Tudo OK — Sucesso!
+	log.Printf("INFO [Handler]: Execução concluída com sucesso.")
+	if state.Validated {
+		ws.WriteJSON(ServerMessage{Type: "complete", Payload: "✅ Parabéns! Laboratório concluído com sucesso."})
+	} else {
+		// Sem validação → Apenas avisa que terminou
+		ws.WriteJSON(ServerMessage{Type: "complete", Payload: "Comando executado."})
+	}
+}
+
+// sendHint revela a próxima dica do lab e envia-a pelo WebSocket.
+func (h *Handler) sendHint(ctx context.Context, ws *wsConn, userID, labID string) {
+	hint, err := h.labService.RevealNextHint(ctx, userID, labID)
+	if err != nil {
+		ws.WriteJSON(ServerMessage{Type: "error", Payload: err.Error()})
+		return
+	}
+	log.Printf("INFO [Handler]: Dica %d revelada (Lab %s)", hint.Index+1, labID)
+	ws.WriteJSON(ServerMessage{Type: "hint", Payload: hint.Text, Data: hint})
+}
 func (h *Handler) HandleGetLabDetails(c echo.Context) error {
 	labID := c.Param("labID")
 
 	// Chama o serviço
-	lab, ws, err := h.labService.GetLabDetails(c.Request().Context(), labID)
+	details, err := h.labService.GetLabDetails(c.Request().Context(), currentUser(c).ID, labID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
 	}
 
-	// Retorna uma resposta combinada
-	response := struct {
-		Lab       interface{} `json:"lab"`
-		Workspace interface{} `json:"workspace"`
-	}{
-		Lab:       lab,
-		Workspace: ws,
-	}
-
-	return c.JSON(http.StatusOK, response)
+	// Retorna o lab, o workspace e o bloqueio numa resposta combinada
+	return c.JSON(http.StatusOK, details)
 }
 
 func (h *Handler) HandleListLabs(c echo.Context) error {
@@ -214,9 +442,14 @@ func (h *Handler) HandleCreateLab(c echo.Context) error {
 		c.Request().Context(),
 		req.Title, req.Type, req.Instructions, req.InitialCode,
 		req.TrackID, req.LabOrder, req.ValidationCode,
+		req.limitsOrZero(),
+		req.Image,
+		req.Checks,
+		req.Hints,
+		req.Prerequisites,
 	)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return c.JSON(labErrorStatus(err), map[string]string{"error": err.Error()})
 	}
 
 	return c.JSON(http.StatusCreated, lab)
@@ -225,7 +458,7 @@ func (h *Handler) HandlerDeleteLab(c echo.Context) error {
 	labId := c.Param("labId")
 	err := h.labService.CleanLab(c.Request().Context(), labId)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return c.JSON(deleteLabErrorStatus(err), map[string]string{"error": err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Lab deletado com sucesso"})
@@ -237,7 +470,7 @@ func (h *Handler) HandleCreateTrack(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Payload inválido"})
 	}
 
-	track, err := h.labService.CreateTrack(c.Request().Context(), req.Title, req.Description)
+	track, err := h.labService.CreateTrack(c.Request().Context(), req.Title, req.Description, req.Sequential != nil && *req.Sequential)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -262,9 +495,9 @@ func (h *Handler) HandleUpdateLab(c echo.Context) error {
 	}
 
 	labId := c.Param("labId")
-	lab, err := h.labService.UpdateLab(c.Request().Context(), labId, req.Title, req.Type, req.Instructions, req.InitialCode, req.TrackID, req.LabOrder, req.ValidationCode)
+	lab, err := h.labService.UpdateLab(c.Request().Context(), labId, req.Title, req.Type, req.Instructions, req.InitialCode, req.TrackID, req.LabOrder, req.ValidationCode, req.Limits, req.Image, req.Checks, req.Hints, req.Prerequisites)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return c.JSON(labErrorStatus(err), map[string]string{"error": err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, lab)
@@ -278,9 +511,9 @@ func (h *Handler) HandleUpdateTrack(c echo.Context) error {
 	}
 
 	trackId := c.Param("trackId")
-	track, err := h.labService.UpdateTrack(c.Request().Context(), trackId, req.Title, req.Description)
+	track, err := h.labService.UpdateTrack(c.Request().Context(), trackId, req.Title, req.Description, req.Sequential)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return c.JSON(labErrorStatus(err), map[string]string{"error": err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, track)
@@ -290,7 +523,7 @@ func (h *Handler) HandleDeleteLab(c echo.Context) error {
 	labId := c.Param("labId")
 	err := h.labService.DeleteLab(c.Request().Context(), labId)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return c.JSON(deleteLabErrorStatus(err), map[string]string{"error": err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Lab deletado com sucesso"})
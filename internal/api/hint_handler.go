@@ -0,0 +1,32 @@
+package api
+
+import (
+	"errors"
+	"lab-devops/internal/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HandleListHints devolve as dicas já reveladas no workspace do utilizador
+// GET /api/v1/labs/:labID/hints
+func (h *Handler) HandleListHints(c echo.Context) error {
+	summary, err := h.labService.ListRevealedHints(c.Request().Context(), currentUser(c).ID, c.Param("labID"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, summary)
+}
+
+// HandleNextHint revela a próxima dica disponível
+// POST /api/v1/labs/:labID/hints/next
+func (h *Handler) HandleNextHint(c echo.Context) error {
+	hint, err := h.labService.RevealNextHint(c.Request().Context(), currentUser(c).ID, c.Param("labID"))
+	if errors.Is(err, service.ErrNoMoreHints) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, hint)
+}
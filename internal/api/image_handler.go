@@ -0,0 +1,96 @@
+package api
+
+import (
+	"lab-devops/internal/domain"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SetImageRequest struct {
+	Reference string `json:"reference"`
+}
+
+type AllowImageRequest struct {
+	Pattern string `json:"pattern"`
+}
+
+// HandleListImages lista o catálogo de imagens por tipo de lab
+// GET /api/v1/admin/images
+func (h *Handler) HandleListImages(c echo.Context) error {
+	images, err := h.imageService.ListImages(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, images)
+}
+
+// HandleSetImage altera a imagem de um tipo de lab e inicia o pull
+// PUT /api/v1/admin/images/:type
+func (h *Handler) HandleSetImage(c echo.Context) error {
+	var req SetImageRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Payload inválido"})
+	}
+
+	execType := domain.ExecutionType(c.Param("type"))
+	image, err := h.imageService.SetImage(c.Request().Context(), execType, req.Reference)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusAccepted, image)
+}
+
+// HandlePullImage (re)baixa a imagem de um tipo de lab, atualizando o digest
+// POST /api/v1/admin/images/:type/pull
+func (h *Handler) HandlePullImage(c echo.Context) error {
+	execType := domain.ExecutionType(c.Param("type"))
+	image, err := h.imageService.PullImage(c.Request().Context(), execType)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusAccepted, image)
+}
+
+// HandleRefreshImages (re)baixa todas as imagens do catálogo
+// POST /api/v1/admin/images/refresh
+func (h *Handler) HandleRefreshImages(c echo.Context) error {
+	if err := h.imageService.RefreshAll(c.Request().Context()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "Atualização das imagens iniciada"})
+}
+
+// HandleListAllowedImages lista as imagens que os autores podem usar nos labs
+// GET /api/v1/admin/allowed-images
+func (h *Handler) HandleListAllowedImages(c echo.Context) error {
+	allowed, err := h.imageService.ListAllowedImages(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, allowed)
+}
+
+// HandleAllowImage acrescenta um padrão à lista de imagens permitidas
+// POST /api/v1/admin/allowed-images
+func (h *Handler) HandleAllowImage(c echo.Context) error {
+	var req AllowImageRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Payload inválido"})
+	}
+
+	allowed, err := h.imageService.AllowImage(c.Request().Context(), req.Pattern)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, allowed)
+}
+
+// HandleDisallowImage remove um padrão da lista de imagens permitidas
+// DELETE /api/v1/admin/allowed-images/:allowedId
+func (h *Handler) HandleDisallowImage(c echo.Context) error {
+	if err := h.imageService.DisallowImage(c.Request().Context(), c.Param("allowedId")); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Imagem removida da lista de permitidas"})
+}
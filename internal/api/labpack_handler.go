@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"lab-devops/internal/labpack"
+	"lab-devops/internal/service"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// labPackErrorStatus traduz os erros da importação de lab packs.
+func labPackErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidLabPack):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrImageNotAllowed):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// HandleImportLabPacks importa os lab packs de um arquivo .tar.gz enviado no
+// corpo do pedido. Com dry_run=true só devolve o que mudaria
+// POST /api/v1/labpacks/import[?dry_run=true]
+func (h *Handler) HandleImportLabPacks(c echo.Context) error {
+	dir, err := os.MkdirTemp("", "labpacks-")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer os.RemoveAll(dir)
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, labpack.MaxArchiveSize)
+	if err := labpack.ExtractTarGz(body, dir); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	packs, err := labpack.Load(os.DirFS(dir))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+	result, err := h.labService.ImportLabPacks(c.Request().Context(), packs, dryRun)
+	if err != nil {
+		return c.JSON(labPackErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// HandleExportLabPacks devolve todas as trilhas e labs como um arquivo
+// .tar.gz de lab packs
+// GET /api/v1/labpacks/export
+func (h *Handler) HandleExportLabPacks(c echo.Context) error {
+	packs, err := h.labService.ExportLabPacks(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	var buf bytes.Buffer
+	if err := labpack.WriteTarGz(&buf, packs); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="labpacks.tar.gz"`)
+	return c.Blob(http.StatusOK, "application/gzip", buf.Bytes())
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"lab-devops/internal/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// revisionErrorStatus traduz os erros das operações sobre as revisões.
+func revisionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrRevisionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidFile):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// HandleListRevisions lista as revisões do código do workspace
+// GET /api/v1/labs/:labID/revisions
+func (h *Handler) HandleListRevisions(c echo.Context) error {
+	revisions, err := h.labService.ListRevisions(c.Request().Context(), currentUser(c).ID, c.Param("labID"))
+	if err != nil {
+		return c.JSON(revisionErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, revisions)
+}
+
+// HandleGetRevision devolve uma revisão com o código submetido
+// GET /api/v1/labs/:labID/revisions/:revision
+func (h *Handler) HandleGetRevision(c echo.Context) error {
+	revision, err := numberParam("revisão", c.Param("revision"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	rev, err := h.labService.GetRevision(c.Request().Context(), currentUser(c).ID, c.Param("labID"), revision)
+	if err != nil {
+		return c.JSON(revisionErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, rev)
+}
+
+// HandleDiffRevisions devolve o diff unificado entre duas revisões
+// GET /api/v1/labs/:labID/revisions/diff?from=1&to=3
+func (h *Handler) HandleDiffRevisions(c echo.Context) error {
+	from, err := numberParam("revisão", c.QueryParam("from"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	to, err := numberParam("revisão", c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	diff, err := h.labService.DiffRevisions(c.Request().Context(), currentUser(c).ID, c.Param("labID"), from, to)
+	if err != nil {
+		return c.JSON(revisionErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.String(http.StatusOK, diff)
+}
+
+// HandleRestoreRevision repõe uma revisão como o código atual do workspace
+// POST /api/v1/labs/:labID/revisions/:revision/restore
+func (h *Handler) HandleRestoreRevision(c echo.Context) error {
+	revision, err := numberParam("revisão", c.Param("revision"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	ws, err := h.labService.RestoreRevision(c.Request().Context(), currentUser(c).ID, c.Param("labID"), revision)
+	if err != nil {
+		return c.JSON(revisionErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, ws)
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"lab-devops/internal/domain"
+
 	"github.com/labstack/echo/v4"
 )
 
@@ -9,33 +11,100 @@ func RegisterRoutes(e *echo.Echo, h *Handler) {
 	// Agrupa as rotas sob /api/v1
 	g := e.Group("/api/v1")
 
-	// Rota de Health Check
+	// Rota de Health Check (pública)
 	g.GET("/health", h.HandleHealthCheck)
 
+	// Todas as demais rotas exigem um token válido
+	auth := g.Group("", h.Authenticate)
+	authors := RequireRole(domain.RoleAuthor, domain.RoleAdmin)
+
+	auth.GET("/users/me", h.HandleGetCurrentUser)
+
 	// Rota para buscar os detalhes de um Lab (HTTP GET)
+	// O workspace devolvido é o do utilizador autenticado
 	// ex: GET /api/v1/labs/lab-tf-01
-	g.GET("/labs/:labID", h.HandleGetLabDetails)
+	auth.GET("/labs/:labID", h.HandleGetLabDetails)
 
 	// Rota para executar um Lab (WebSocket)
-	// ex: WS /api/v1/labs/lab-tf-01/execute
-	g.GET("/labs/:labID/execute", h.HandlerLabExecute)
+	// ex: WS /api/v1/labs/lab-tf-01/execute?token=...
+	auth.GET("/labs/:labID/execute", h.HandlerLabExecute)
+
+	// Terminal interativo no container do lab (WebSocket)
+	// ex: WS /api/v1/labs/lab-linux-01/terminal?token=...
+	auth.GET("/labs/:labID/terminal", h.HandleLabTerminal)
+
+	// Sessão de lab: um container que se mantém entre execuções
+	// ex: POST /api/v1/labs/lab-linux-01/session
+	auth.POST("/labs/:labID/session", h.HandleStartSession)
+	auth.GET("/labs/:labID/session", h.HandleGetSession)
+	auth.DELETE("/labs/:labID/session", h.HandleStopSession)
+
+	// Rotas para gerir os ficheiros do workspace do utilizador
+	// ex: PUT /api/v1/labs/lab-tf-01/files/modules/vpc/main.tf
+	auth.GET("/labs/:labID/files", h.HandleListWorkspaceFiles)
+	auth.GET("/labs/:labID/files/*", h.HandleReadWorkspaceFile)
+	auth.PUT("/labs/:labID/files/*", h.HandleWriteWorkspaceFile)
+	auth.DELETE("/labs/:labID/files/*", h.HandleDeleteWorkspaceFile)
+
+	// Recomeça o lab a partir do código inicial
+	auth.POST("/labs/:labID/workspace/reset", h.HandleResetWorkspace)
+
+	// Histórico de execuções e reprodução dos seus logs
+	auth.GET("/workspaces/:workspaceID/executions", h.HandleListExecutions)
+	auth.GET("/executions/:executionID/logs", h.HandleExecutionLogs)
+
+	// Revisões do código submetido em cada execução
+	auth.GET("/labs/:labID/revisions", h.HandleListRevisions)
+	auth.GET("/labs/:labID/revisions/diff", h.HandleDiffRevisions)
+	auth.GET("/labs/:labID/revisions/:revision", h.HandleGetRevision)
+	auth.POST("/labs/:labID/revisions/:revision/restore", h.HandleRestoreRevision)
+
+	// Histórico do estado Terraform do workspace
+	auth.GET("/labs/:labID/state/versions", h.HandleListStateVersions)
+	auth.GET("/labs/:labID/state/versions/:version", h.HandleDownloadStateVersion)
+	auth.POST("/labs/:labID/state/versions/:version/rollback", h.HandleRollbackState)
+	auth.GET("/labs/:labID/state/diff", h.HandleDiffStateVersions)
+
+	// Rotas das dicas progressivas do lab
+	auth.GET("/labs/:labID/hints", h.HandleListHints)
+	auth.POST("/labs/:labID/hints/next", h.HandleNextHint)
 
 	// Rota para listar todos os labs
-	g.GET("/labs", h.HandleListLabs)
+	auth.GET("/labs", h.HandleListLabs)
 
 	// Rota para criar um laboratório
-	g.POST("/labs", h.HandleCreateLab)
+	auth.POST("/labs", h.HandleCreateLab, authors)
 
-	// Rota para deletar um laboratório
-	g.DELETE("/labs/:labId", h.HandlerDeleteLab)
-
-	g.GET("/tracks", h.HandleListTracks)
+	auth.GET("/tracks", h.HandleListTracks)
 	// Rota para criar uma nova Trilha
-	g.POST("/tracks", h.HandleCreateTrack)
+	auth.POST("/tracks", h.HandleCreateTrack, authors)
+
+	auth.PATCH("/tracks/:trackId", h.HandleUpdateTrack, authors)
+	auth.DELETE("/tracks/:trackId", h.HandleDeleteTrack, authors)
+
+	auth.PATCH("/labs/:labId", h.HandleUpdateLab, authors)
+	auth.DELETE("/labs/:labId", h.HandlerDeleteLab, authors)
 
-	g.PATCH("/tracks/:trackId", h.HandleUpdateTrack)
-	g.DELETE("/tracks/:trackId", h.HandleDeleteTrack)
+	// Lab packs: trilhas e labs como ficheiros versionáveis (.tar.gz)
+	auth.POST("/labpacks/import", h.HandleImportLabPacks, authors)
+	auth.GET("/labpacks/export", h.HandleExportLabPacks, authors)
 
-	g.PATCH("/labs/:labId", h.HandleUpdateLab)
-	g.DELETE("/labs/:labId", h.HandlerDeleteLab)
+	// Rotas de administração (utilizadores, tokens e imagens)
+	admin := auth.Group("/admin", RequireRole(domain.RoleAdmin))
+	admin.GET("/users", h.HandleListUsers)
+	admin.POST("/users", h.HandleCreateUser)
+	admin.PATCH("/users/:userId", h.HandleUpdateUserRole)
+	admin.GET("/tokens", h.HandleListTokens)
+	admin.POST("/tokens", h.HandleCreateToken)
+	admin.DELETE("/tokens/:tokenId", h.HandleRevokeToken)
+	admin.GET("/images", h.HandleListImages)
+	admin.POST("/images/refresh", h.HandleRefreshImages)
+	admin.PUT("/images/:type", h.HandleSetImage)
+	admin.POST("/images/:type/pull", h.HandlePullImage)
+	admin.GET("/allowed-images", h.HandleListAllowedImages)
+	admin.POST("/allowed-images", h.HandleAllowImage)
+	admin.DELETE("/allowed-images/:allowedId", h.HandleDisallowImage)
+	admin.GET("/catalog/diff", h.HandleCatalogDiff)
+	admin.POST("/catalog/sync", h.HandleCatalogSync)
+	admin.GET("/catalog/syncs", h.HandleListCatalogSyncs)
 }
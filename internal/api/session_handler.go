@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"lab-devops/internal/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sessionErrorStatus traduz os erros das operações sobre as sessões de lab.
+func sessionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNoSession):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrSessionBusy):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrSessionUnavailable):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrImageNotAllowed), errors.Is(err, service.ErrLabLocked):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// HandleStartSession inicia a sessão de lab do utilizador, ou devolve a que
+// já está aberta
+// POST /api/v1/labs/:labID/session
+func (h *Handler) HandleStartSession(c echo.Context) error {
+	session, created, err := h.labService.StartSession(c.Request().Context(), currentUser(c).ID, c.Param("labID"))
+	if err != nil {
+		return c.JSON(sessionErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	if created {
+		return c.JSON(http.StatusCreated, session)
+	}
+	return c.JSON(http.StatusOK, session)
+}
+
+// HandleGetSession devolve a sessão de lab ativa do utilizador
+// GET /api/v1/labs/:labID/session
+func (h *Handler) HandleGetSession(c echo.Context) error {
+	session, err := h.labService.GetSession(c.Request().Context(), currentUser(c).ID, c.Param("labID"))
+	if err != nil {
+		return c.JSON(sessionErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, session)
+}
+
+// HandleStopSession termina a sessão de lab e remove o seu container
+// DELETE /api/v1/labs/:labID/session
+func (h *Handler) HandleStopSession(c echo.Context) error {
+	if err := h.labService.StopSession(c.Request().Context(), currentUser(c).ID, c.Param("labID")); err != nil {
+		return c.JSON(sessionErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
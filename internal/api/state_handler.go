@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"lab-devops/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// stateErrorStatus traduz os erros das operações sobre o histórico do estado.
+func stateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrStateVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrWorkspaceBusy):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// numberParam lê um número de versão ou revisão (parâmetro de rota ou
+// query); name é usado na mensagem de erro.
+func numberParam(name, value string) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("%s inválida: %q", name, value)
+	}
+	return v, nil
+}
+
+// HandleListStateVersions lista o histórico do estado Terraform do workspace
+// GET /api/v1/labs/:labID/state/versions
+func (h *Handler) HandleListStateVersions(c echo.Context) error {
+	versions, err := h.labService.ListStateVersions(c.Request().Context(), currentUser(c).ID, c.Param("labID"))
+	if err != nil {
+		return c.JSON(stateErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, versions)
+}
+
+// HandleDownloadStateVersion devolve o tfstate de uma versão
+// GET /api/v1/labs/:labID/state/versions/:version
+func (h *Handler) HandleDownloadStateVersion(c echo.Context) error {
+	version, err := numberParam("versão", c.Param("version"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	v, err := h.labService.GetStateVersion(c.Request().Context(), currentUser(c).ID, c.Param("labID"), version)
+	if err != nil {
+		return c.JSON(stateErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"terraform-v%d.tfstate\"", v.Version))
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, v.State)
+}
+
+// HandleDiffStateVersions compara duas versões do estado
+// GET /api/v1/labs/:labID/state/diff?from=1&to=3
+func (h *Handler) HandleDiffStateVersions(c echo.Context) error {
+	from, err := numberParam("versão", c.QueryParam("from"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	to, err := numberParam("versão", c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	diff, err := h.labService.DiffStateVersions(c.Request().Context(), currentUser(c).ID, c.Param("labID"), from, to)
+	if err != nil {
+		return c.JSON(stateErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, diff)
+}
+
+// HandleRollbackState repõe uma versão antiga do estado
+// POST /api/v1/labs/:labID/state/versions/:version/rollback
+func (h *Handler) HandleRollbackState(c echo.Context) error {
+	version, err := numberParam("versão", c.Param("version"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	restored, err := h.labService.RollbackState(c.Request().Context(), currentUser(c).ID, c.Param("labID"), version)
+	if err != nil {
+		return c.JSON(stateErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, restored)
+}
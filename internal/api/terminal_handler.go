@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"lab-devops/internal/service"
+	"log"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+// TerminalMessage é uma mensagem de controlo do cliente do terminal. As
+// teclas podem também ser enviadas em mensagens binárias.
+type TerminalMessage struct {
+	Action string `json:"action"`
+	Data   string `json:"data,omitempty"`
+	Cols   uint   `json:"cols,omitempty"`
+	Rows   uint   `json:"rows,omitempty"`
+}
+
+// HandleLabTerminal abre um shell interativo no container do lab. O output
+// do TTY segue em mensagens binárias; as mensagens de controlo e os
+// resultados da validação em JSON, como no endpoint execute
+// GET /api/v1/labs/:labID/terminal (WebSocket)
+func (h *Handler) HandleLabTerminal(c echo.Context) error {
+	labID := c.Param("labID")
+	user := currentUser(c)
+	raw, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		log.Printf("ERRO [Handler]: Falha no upgrade do websocket: %v", err)
+		return err
+	}
+	ws := &wsConn{Conn: raw}
+	defer ws.Close()
+
+	ctx := c.Request().Context()
+	session, err := h.terminals.Open(ctx, user.ID, labID, func(position int) {
+		ws.WriteJSON(ServerMessage{
+			Type:    "queued",
+			Payload: fmt.Sprintf("⏳ A aguardar na fila de execução (posição %d).", position),
+			Data:    map[string]int{"position": position},
+		})
+	})
+	if err != nil {
+		log.Printf("ERRO [Handler]: Falha ao abrir terminal (Lab %s): %v", labID, err)
+		ws.WriteJSON(ServerMessage{Type: "error", Payload: err.Error()})
+		return nil
+	}
+	defer session.Close(service.TerminalClosedClient)
+
+	log.Printf("INFO [Handler]: Terminal %s aberto (Lab %s)", session.ID, labID)
+	ws.WriteJSON(ServerMessage{Type: "ready", Payload: "Terminal pronto.", Data: session})
+
+	// Output do TTY para o cliente, até o shell terminar ou a sessão fechar
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := session.Read(buf)
+			if n > 0 {
+				if werr := ws.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					break
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+
+		session.Close(service.TerminalClosedExited)
+		ws.WriteJSON(ServerMessage{Type: "closed", Payload: session.Reason()})
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		ws.Conn.Close()
+	}()
+
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			log.Printf("INFO [Handler]: Cliente do terminal desconectado: %v", err)
+			return nil
+		}
+		if messageType == websocket.BinaryMessage {
+			session.Write(data)
+			continue
+		}
+
+		var msg TerminalMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch msg.Action {
+		case "input":
+			session.Write([]byte(msg.Data))
+		case "resize":
+			if err := session.Resize(msg.Cols, msg.Rows); err != nil {
+				ws.WriteJSON(ServerMessage{Type: "error", Payload: err.Error()})
+			}
+		case "validate":
+			log.Printf("INFO [Handler]: Validando solução no terminal %s (Lab %s)", session.ID, labID)
+			run, err := session.Validate(ctx)
+			if err != nil {
+				ws.WriteJSON(ServerMessage{Type: "error", Payload: err.Error()})
+				continue
+			}
+			ws.WriteJSON(ServerMessage{
+				Type:    "started",
+				Payload: run.ID,
+				Data:    map[string]string{"execution_id": run.ID, "action": run.Action},
+			})
+			go streamExecution(ctx, ws, run)
+		case "close":
+			return nil
+		default:
+			log.Printf("AVISO [Handler]: Ação desconhecida no terminal: %s", msg.Action)
+		}
+	}
+}
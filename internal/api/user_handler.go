@@ -0,0 +1,13 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HandleGetCurrentUser devolve o utilizador autenticado
+// GET /api/v1/users/me
+func (h *Handler) HandleGetCurrentUser(c echo.Context) error {
+	return c.JSON(http.StatusOK, currentUser(c))
+}
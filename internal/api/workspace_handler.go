@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"lab-devops/internal/service"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+)
+
+type WriteFileRequest struct {
+	Content string `json:"content"`
+}
+
+// fileErrorStatus traduz os erros das operações sobre ficheiros do workspace.
+func fileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidFile):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// filePathParam devolve o caminho do ficheiro (wildcard da rota), sem escapes.
+func filePathParam(c echo.Context) string {
+	p, err := url.PathUnescape(c.Param("*"))
+	if err != nil {
+		return c.Param("*")
+	}
+	return p
+}
+
+// HandleListWorkspaceFiles lista a árvore de ficheiros do workspace do utilizador
+// GET /api/v1/labs/:labID/files
+func (h *Handler) HandleListWorkspaceFiles(c echo.Context) error {
+	files, err := h.labService.ListWorkspaceFiles(c.Request().Context(), currentUser(c).ID, c.Param("labID"))
+	if err != nil {
+		return c.JSON(fileErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, files)
+}
+
+// HandleReadWorkspaceFile devolve um ficheiro do workspace
+// GET /api/v1/labs/:labID/files/*
+func (h *Handler) HandleReadWorkspaceFile(c echo.Context) error {
+	file, err := h.labService.ReadWorkspaceFile(c.Request().Context(), currentUser(c).ID, c.Param("labID"), filePathParam(c))
+	if err != nil {
+		return c.JSON(fileErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, file)
+}
+
+// HandleWriteWorkspaceFile cria ou substitui um ficheiro do workspace
+// PUT /api/v1/labs/:labID/files/*
+func (h *Handler) HandleWriteWorkspaceFile(c echo.Context) error {
+	var req WriteFileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Payload inválido"})
+	}
+
+	file, err := h.labService.WriteWorkspaceFile(c.Request().Context(), currentUser(c).ID, c.Param("labID"), filePathParam(c), req.Content)
+	if err != nil {
+		return c.JSON(fileErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, file)
+}
+
+// HandleDeleteWorkspaceFile apaga um ficheiro do workspace
+// DELETE /api/v1/labs/:labID/files/*
+func (h *Handler) HandleDeleteWorkspaceFile(c echo.Context) error {
+	if err := h.labService.DeleteWorkspaceFile(c.Request().Context(), currentUser(c).ID, c.Param("labID"), filePathParam(c)); err != nil {
+		return c.JSON(fileErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Ficheiro apagado com sucesso"})
+}
+
+// HandleResetWorkspace recomeça o lab a partir do código inicial, destruindo
+// antes os recursos Terraform provisionados
+// POST /api/v1/labs/:labID/workspace/reset
+func (h *Handler) HandleResetWorkspace(c echo.Context) error {
+	ws, err := h.labService.ResetWorkspace(c.Request().Context(), currentUser(c).ID, c.Param("labID"))
+	if err != nil {
+		return c.JSON(labErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, ws)
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+const (
+	CatalogChangeCreated = "created"
+	CatalogChangeUpdated = "updated"
+	CatalogChangeDeleted = "deleted"
+)
+
+// CatalogChange é uma alteração a uma trilha ou a um lab do catálogo.
+type CatalogChange struct {
+	// Kind é "track" ou "lab".
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Action string `json:"action"`
+}
+
+// CatalogWrites são as escritas de uma importação de lab packs ou de uma
+// sincronização do catálogo, aplicadas numa única transação.
+type CatalogWrites struct {
+	CreateTracks []*Track
+	UpdateTracks []*Track
+	CreateLabs   []*Lab
+	UpdateLabs   []*Lab
+	// Os labs são apagados antes das trilhas a que pertencem
+	DeleteLabIDs   []string
+	DeleteTrackIDs []string
+}
+
+// CatalogSync regista uma sincronização do catálogo com um commit de um
+// repositório Git. TrackIDs e LabIDs são os itens que esse commit define:
+// na sincronização seguinte, os que desaparecerem do repositório são
+// apagados.
+type CatalogSync struct {
+	ID       string          `json:"id"`
+	Source   string          `json:"source"`
+	Ref      string          `json:"ref"`
+	Commit   string          `json:"commit"`
+	TrackIDs []string        `json:"track_ids"`
+	LabIDs   []string        `json:"lab_ids"`
+	Changes  []CatalogChange `json:"changes"`
+	SyncedAt time.Time       `json:"synced_at"`
+}
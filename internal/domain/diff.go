@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext é o número de linhas de contexto à volta de cada alteração.
+const diffContext = 3
+
+// maxDiffCells limita a tabela LCS; acima disso o bloco alterado é
+// mostrado como uma substituição integral.
+const maxDiffCells = 4_000_000
+
+type diffOp struct {
+	kind byte // ' ', '-' ou '+'
+	text string
+	a, b int // posição (0-based) em cada versão antes da operação
+}
+
+// UnifiedDiff devolve o diff unificado entre duas versões de um ficheiro,
+// ou "" se forem iguais. Um ficheiro ausente deve ser indicado pelo nome
+// "/dev/null" com conteúdo vazio.
+func UnifiedDiff(fromName, toName, before, after string) string {
+	if before == after {
+		return ""
+	}
+
+	ops := diffLines(splitLines(before), splitLines(after))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for start := 0; start < len(ops); {
+		first := nextChange(ops, start)
+		if first < 0 {
+			break
+		}
+
+		// Junta alterações separadas por menos de 2*diffContext linhas iguais
+		last := first
+		for next := nextChange(ops, last+1); next >= 0 && next-last <= 2*diffContext; next = nextChange(ops, last+1) {
+			last = next
+		}
+
+		from := max(first-diffContext, 0)
+		to := min(last+diffContext+1, len(ops))
+		writeHunk(&out, ops[from:to])
+		start = to
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func nextChange(ops []diffOp, from int) int {
+	for i := from; i < len(ops); i++ {
+		if ops[i].kind != ' ' {
+			return i
+		}
+	}
+	return -1
+}
+
+func writeHunk(out *strings.Builder, ops []diffOp) {
+	aLen, bLen := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			aLen++
+		}
+		if op.kind != '-' {
+			bLen++
+		}
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(ops[0].a, aLen), hunkRange(ops[0].b, bLen))
+	for _, op := range ops {
+		out.WriteByte(op.kind)
+		out.WriteString(op.text)
+		out.WriteByte('\n')
+	}
+}
+
+// hunkRange formata "início,tamanho"; um bloco vazio começa na linha anterior.
+func hunkRange(pos, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, length)
+}
+
+// diffLines calcula as operações que transformam a em b: o prefixo e o
+// sufixo comuns são descartados e o resto é alinhado pela maior
+// subsequência comum.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{kind: ' ', text: a[i], a: i, b: i})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	ai, bi := prefix, prefix
+	for _, kind := range alignLines(midA, midB) {
+		op := diffOp{kind: kind, a: ai, b: bi}
+		switch kind {
+		case ' ':
+			op.text = a[ai]
+			ai++
+			bi++
+		case '-':
+			op.text = a[ai]
+			ai++
+		case '+':
+			op.text = b[bi]
+			bi++
+		}
+		ops = append(ops, op)
+	}
+
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, diffOp{kind: ' ', text: a[ai], a: ai, b: bi})
+		ai++
+		bi++
+	}
+	return ops
+}
+
+// alignLines devolve a sequência de operações (' ', '-', '+') entre a e b.
+func alignLines(a, b []string) []byte {
+	m, n := len(a), len(b)
+	kinds := make([]byte, 0, m+n)
+	if (m+1)*(n+1) > maxDiffCells {
+		for range a {
+			kinds = append(kinds, '-')
+		}
+		for range b {
+			kinds = append(kinds, '+')
+		}
+		return kinds
+	}
+
+	// lcs[i*(n+1)+j] é a maior subsequência comum de a[i:] e b[j:]
+	lcs := make([]int32, (m+1)*(n+1))
+	for i := m - 1; i >= 0; i-- {
+		for j := n - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*(n+1)+j] = lcs[(i+1)*(n+1)+j+1] + 1
+			} else {
+				lcs[i*(n+1)+j] = max(lcs[(i+1)*(n+1)+j], lcs[i*(n+1)+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < m && j < n {
+		switch {
+		case a[i] == b[j]:
+			kinds = append(kinds, ' ')
+			i++
+			j++
+		case lcs[(i+1)*(n+1)+j] >= lcs[i*(n+1)+j+1]:
+			kinds = append(kinds, '-')
+			i++
+		default:
+			kinds = append(kinds, '+')
+			j++
+		}
+	}
+	for ; i < m; i++ {
+		kinds = append(kinds, '-')
+	}
+	for ; j < n; j++ {
+		kinds = append(kinds, '+')
+	}
+	return kinds
+}
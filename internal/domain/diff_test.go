@@ -0,0 +1,46 @@
+package domain
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+	}{
+		{"iguais", "a\nb\n", "a\nb\n", ""},
+		{
+			"linha alterada",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"1\n2\n3\n4\nCINCO\n6\n7\n8\n9\n",
+			"--- a/f\n+++ b/f\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+CINCO\n 6\n 7\n 8\n",
+		},
+		{
+			"ficheiro novo",
+			"",
+			"x\ny\n",
+			"--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			"alterações afastadas em blocos separados",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"UM\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\nDOZE\n",
+			"--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n-1\n+UM\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+DOZE\n",
+		},
+		{
+			"inserção no meio",
+			"a\nb\nc\n",
+			"a\nb\nnovo\nc\n",
+			"--- a/f\n+++ b/f\n@@ -1,3 +1,4 @@\n a\n b\n+novo\n c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff("a/f", "b/f", tt.before, tt.after)
+			if got != tt.want {
+				t.Errorf("UnifiedDiff =\n%s\nesperado\n%s", got, tt.want)
+			}
+		})
+	}
+}
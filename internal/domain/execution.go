@@ -11,6 +11,30 @@ const (
 	TypeGithubActions ExecutionType = "github-actions"
 )
 
+// ExecutionMode é o que uma execução faz com o código do utilizador. O modo
+// vazio equivale a ModeApply. ModePlan e ModeDestroy são só para Terraform;
+// ModeCommand e ModeValidate só correm numa sessão (ver Session).
+type ExecutionMode string
+
+const (
+	ModeApply   ExecutionMode = "apply"
+	ModePlan    ExecutionMode = "plan"
+	ModeDestroy ExecutionMode = "destroy"
+	// ModeCommand corre Code como comando shell, sem validação.
+	ModeCommand ExecutionMode = "command"
+	// ModeValidate corre apenas a validação, sobre o estado do container.
+	ModeValidate ExecutionMode = "validate"
+)
+
+// Desfechos possíveis de uma execução
+const (
+	OutcomeSuccess   = "success"
+	OutcomeFailed    = "failed"
+	OutcomeCancelled = "cancelled"
+	OutcomeTimeout   = "timeout"
+	OutcomeOOM       = "oom"
+)
+
 type StepResult struct {
 	Name     string
 	ExitCode int
@@ -23,5 +47,15 @@ type ExecutionConfig struct {
 	Code           string
 	State          []byte
 	ValidationCode string
+	Checks         []ValidationCheck
 	Type           ExecutionType
+	Limits         ResourceLimits
+	Image          *LabImage
+	// Files são os ficheiros adicionais do workspace (caminho → conteúdo),
+	// escritos no diretório de execução além do ficheiro principal (Code).
+	Files map[string]string
+	// Mode só se aplica a labs Terraform. ModePlan corre "terraform plan":
+	// nada é aplicado, validado ou gravado no estado do workspace.
+	// ModeDestroy destrói os recursos do estado, sem validação.
+	Mode ExecutionMode
 }
@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// Ações que originam uma execução
+const (
+	ActionExecute  = "execute"
+	ActionValidate = "validate"
+	ActionPlan     = "plan"
+	ActionDestroy  = "destroy"
+	ActionCommand  = "command"
+)
+
+// MaxExecutionOutput é o tamanho máximo do output guardado por execução; o
+// excedente é descartado.
+const MaxExecutionOutput = 1 << 20
+
+// ExecutionRecord é o registo persistido de uma execução. Outcome fica
+// vazio e FinishedAt nulo enquanto ela decorre. ValidationPassed só é
+// preenchido quando a validação chegou a correr.
+type ExecutionRecord struct {
+	ID                 string     `json:"id"`
+	WorkspaceID        string     `json:"workspace_id"`
+	UserID             string     `json:"user_id"`
+	Action             string     `json:"action"`
+	Outcome            string     `json:"outcome,omitempty"`
+	ExitCode           *int       `json:"exit_code,omitempty"`
+	ValidationExitCode *int       `json:"validation_exit_code,omitempty"`
+	ValidationPassed   *bool      `json:"validation_passed,omitempty"`
+	Error              string     `json:"error,omitempty"`
+	Output             string     `json:"-"`
+	StartedAt          time.Time  `json:"started_at"`
+	FinishedAt         *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	ImageStatusPending = "pending"
+	ImageStatusPulling = "pulling"
+	ImageStatusReady   = "ready"
+	ImageStatusError   = "error"
+)
+
+// DefaultImages é o catálogo inicial de imagens por tipo de lab. Na primeira
+// execução o digest de cada imagem é registado, fixando a versão usada até
+// que um administrador faça refresh.
+var DefaultImages = map[ExecutionType]string{
+	TypeTerraform:     "hashicorp/terraform:latest",
+	TypeAnsible:       "cytopia/ansible:latest",
+	TypeLinux:         "alpine:latest",
+	TypeDocker:        "docker:cli",
+	TypeK8s:           "bitnami/kubectl:latest",
+	TypeGithubActions: "docker:cli",
+}
+
+// ImageCatalogEntry associa um tipo de lab à imagem usada pelo executor.
+type ImageCatalogEntry struct {
+	Type      ExecutionType `json:"type"`
+	Reference string        `json:"reference"`
+	Digest    string        `json:"digest,omitempty"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	Progress  string        `json:"progress,omitempty"`
+	PulledAt  *time.Time    `json:"pulled_at,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// PinnedReference devolve a referência fixada pelo digest, quando conhecido
+// (ex: "alpine:latest@sha256:...").
+func (e ImageCatalogEntry) PinnedReference() string {
+	if e.Digest == "" {
+		return e.Reference
+	}
+	return e.Reference + "@" + e.Digest
+}
+
+// AllowedImage é uma entrada da lista de imagens que os autores podem usar
+// nos seus labs. Um padrão terminado em "*" aceita qualquer referência com
+// esse prefixo (ex: "hashicorp/terraform:*").
+type AllowedImage struct {
+	ID        string    `json:"id"`
+	Pattern   string    `json:"pattern"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches indica se a referência é permitida por esta entrada.
+func (a AllowedImage) Matches(reference string) bool {
+	if prefix, ok := strings.CutSuffix(a.Pattern, "*"); ok {
+		return strings.HasPrefix(reference, prefix)
+	}
+	return reference == a.Pattern
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "testing"
+
+func TestAllowedImageMatches(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		reference string
+		want      bool
+	}{
+		{"alpine:3.20", "alpine:3.20", true},
+		{"alpine:3.20", "alpine:3.21", false},
+		{"alpine:3.20", "alpine:3.20@sha256:abc", false},
+		{"hashicorp/terraform:*", "hashicorp/terraform:1.9", true},
+		{"hashicorp/terraform:*", "hashicorp/terraform-ls:1.0", false},
+		{"registry.local/labs/*", "registry.local/labs/k8s:1.30", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.reference, func(t *testing.T) {
+			if got := (AllowedImage{Pattern: tt.pattern}).Matches(tt.reference); got != tt.want {
+				t.Errorf("Matches(%q) com %q = %v, esperava %v", tt.reference, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
@@ -13,4 +13,63 @@ type Lab struct {
 	TrackID      string    `json:"track_id"`
 	LabOrder     int       `json:"lab_order"`
 	ValidationCode string  `json:"-"`
+	// Prerequisites são os IDs dos labs, de qualquer trilha, a concluir antes deste
+	Prerequisites []string `json:"prerequisites,omitempty"`
+	// Checks são as verificações estruturadas da solução (não expostas aos alunos)
+	Checks       []ValidationCheck `json:"-"`
+	// Hints são reveladas uma a uma através de /labs/:labID/hints
+	Hints        []Hint `json:"-"`
+
+	Limits       ResourceLimits `json:"limits"`
+	Image        *LabImage      `json:"image,omitempty"`
+}
+
+// LabImage substitui a imagem padrão do tipo do lab. Os comandos de execução
+// e validação continuam a ser os do tipo.
+type LabImage struct {
+	Reference string `json:"reference"`
+	// Entrypoint envolve o processo que mantém o container vivo
+	// (ex: ["dumb-init", "--"]).
+	Entrypoint []string          `json:"entrypoint,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+}
+
+// ResourceLimits define a política de recursos do container de um lab.
+// Campos com valor zero herdam os padrões globais do executor.
+type ResourceLimits struct {
+	TimeoutSeconds   int      `json:"timeout_seconds,omitempty"`
+	CPUs             float64  `json:"cpus,omitempty"`
+	MemoryMB         int64    `json:"memory_mb,omitempty"`
+	PidsLimit        int64    `json:"pids_limit,omitempty"`
+	ReadOnlyRootfs   *bool    `json:"read_only_rootfs,omitempty"`
+	DropCapabilities []string `json:"drop_capabilities,omitempty"`
+}
+
+// WithDefaults completa os campos não definidos com os valores padrão.
+func (l ResourceLimits) WithDefaults(defaults ResourceLimits) ResourceLimits {
+	if l.TimeoutSeconds == 0 {
+		l.TimeoutSeconds = defaults.TimeoutSeconds
+	}
+	if l.CPUs == 0 {
+		l.CPUs = defaults.CPUs
+	}
+	if l.MemoryMB == 0 {
+		l.MemoryMB = defaults.MemoryMB
+	}
+	if l.PidsLimit == 0 {
+		l.PidsLimit = defaults.PidsLimit
+	}
+	if l.ReadOnlyRootfs == nil {
+		l.ReadOnlyRootfs = defaults.ReadOnlyRootfs
+	}
+	if l.DropCapabilities == nil {
+		l.DropCapabilities = defaults.DropCapabilities
+	}
+	return l
+}
+
+// IsZero indica se nenhum limite específico foi definido para o lab.
+func (l ResourceLimits) IsZero() bool {
+	return l.TimeoutSeconds == 0 && l.CPUs == 0 && l.MemoryMB == 0 && l.PidsLimit == 0 &&
+		l.ReadOnlyRootfs == nil && l.DropCapabilities == nil
 }
\ No newline at end of file
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResourceLimitsWithDefaults(t *testing.T) {
+	yes, no := true, false
+	defaults := ResourceLimits{TimeoutSeconds: 300, CPUs: 1, MemoryMB: 512, PidsLimit: 256, ReadOnlyRootfs: &yes, DropCapabilities: []string{"NET_RAW"}}
+
+	tests := []struct {
+		name   string
+		limits ResourceLimits
+		want   ResourceLimits
+	}{
+		{"sem limites do lab", ResourceLimits{}, defaults},
+		{"lab define alguns", ResourceLimits{TimeoutSeconds: 60, MemoryMB: 128},
+			ResourceLimits{TimeoutSeconds: 60, CPUs: 1, MemoryMB: 128, PidsLimit: 256, ReadOnlyRootfs: &yes, DropCapabilities: []string{"NET_RAW"}}},
+		{"rootfs gravável explícito", ResourceLimits{ReadOnlyRootfs: &no},
+			ResourceLimits{TimeoutSeconds: 300, CPUs: 1, MemoryMB: 512, PidsLimit: 256, ReadOnlyRootfs: &no, DropCapabilities: []string{"NET_RAW"}}},
+		{"sem capabilities a remover", ResourceLimits{DropCapabilities: []string{}},
+			ResourceLimits{TimeoutSeconds: 300, CPUs: 1, MemoryMB: 512, PidsLimit: 256, ReadOnlyRootfs: &yes, DropCapabilities: []string{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.WithDefaults(defaults); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WithDefaults() = %+v, esperava %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package domain
+
+// Ações de uma alteração num plano do Terraform
+const (
+	PlanActionCreate  = "create"
+	PlanActionUpdate  = "update"
+	PlanActionDelete  = "delete"
+	PlanActionReplace = "replace"
+)
+
+// PlanChange é a alteração prevista para um recurso.
+type PlanChange struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Action  string `json:"action"`
+}
+
+// PlanSummary resume um "terraform plan". Tal como no Terraform, uma
+// substituição conta como uma criação e uma destruição.
+type PlanSummary struct {
+	Create  int          `json:"create"`
+	Update  int          `json:"update"`
+	Destroy int          `json:"destroy"`
+	Changes []PlanChange `json:"changes"`
+}
+
+// HasChanges indica se o plano altera algum recurso.
+func (p *PlanSummary) HasChanges() bool {
+	return len(p.Changes) > 0
+}
@@ -0,0 +1,101 @@
+package domain
+
+import "sort"
+
+// LabRef identifica um lab nas respostas que só precisam do título.
+type LabRef struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// RequiredLabs devolve os IDs dos labs a concluir antes de lab: numa trilha
+// sequencial, os labs de track.Labs com LabOrder inferior, seguidos dos
+// pré-requisitos declarados no lab. track é a trilha do lab, ou nil.
+func RequiredLabs(lab *Lab, track *Track) []string {
+	seen := map[string]bool{lab.ID: true}
+	var ids []string
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if track != nil && track.Sequential && track.ID == lab.TrackID {
+		for _, other := range track.Labs {
+			if other.LabOrder < lab.LabOrder {
+				add(other.ID)
+			}
+		}
+	}
+	for _, id := range lab.Prerequisites {
+		add(id)
+	}
+	return ids
+}
+
+// PrerequisiteCycle procura um ciclo nos pré-requisitos dos labs, contando
+// com a ordem das trilhas sequenciais. Devolve os IDs do ciclo, com o
+// primeiro repetido no fim, ou nil se não houver. Pré-requisitos que não
+// constam de labs são ignorados.
+func PrerequisiteCycle(labs []*Lab, tracks []*Track) []string {
+	byTrack := make(map[string][]*Lab)
+	byID := make(map[string]*Lab, len(labs))
+	for _, lab := range labs {
+		byTrack[lab.TrackID] = append(byTrack[lab.TrackID], lab)
+		byID[lab.ID] = lab
+	}
+	trackByID := make(map[string]*Track, len(tracks))
+	for _, t := range tracks {
+		track := *t
+		track.Labs = byTrack[t.ID]
+		trackByID[t.ID] = &track
+	}
+
+	ids := make([]string, 0, len(labs))
+	for _, lab := range labs {
+		ids = append(ids, lab.ID)
+	}
+	sort.Strings(ids)
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(labs))
+	var path []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		switch state[id] {
+		case done:
+			return nil
+		case visiting:
+			for i, p := range path {
+				if p == id {
+					return append(append([]string{}, path[i:]...), id)
+				}
+			}
+		}
+		state[id] = visiting
+		path = append(path, id)
+		lab := byID[id]
+		for _, next := range RequiredLabs(lab, trackByID[lab.TrackID]) {
+			if byID[next] == nil {
+				continue
+			}
+			if cycle := visit(next); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, id := range ids {
+		if cycle := visit(id); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
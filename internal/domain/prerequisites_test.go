@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequiredLabs(t *testing.T) {
+	track := &Track{ID: "t", Sequential: true}
+	l1 := &Lab{ID: "l1", TrackID: "t", LabOrder: 1}
+	l2 := &Lab{ID: "l2", TrackID: "t", LabOrder: 2, Prerequisites: []string{"outro", "l1"}}
+	l3 := &Lab{ID: "l3", TrackID: "t", LabOrder: 3}
+	track.Labs = []*Lab{l1, l2, l3}
+
+	if got := RequiredLabs(l1, track); len(got) != 0 {
+		t.Errorf("l1: %v, esperava nenhum", got)
+	}
+	if got, want := RequiredLabs(l2, track), []string{"l1", "outro"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("l2: %v, esperava %v", got, want)
+	}
+	if got, want := RequiredLabs(l3, track), []string{"l1", "l2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("l3: %v, esperava %v", got, want)
+	}
+
+	track.Sequential = false
+	if got, want := RequiredLabs(l3, track), []string(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("l3 sem trilha sequencial: %v, esperava %v", got, want)
+	}
+}
+
+func TestPrerequisiteCycle(t *testing.T) {
+	tracks := []*Track{{ID: "t", Sequential: true}}
+	l1 := &Lab{ID: "l1", TrackID: "t", LabOrder: 1}
+	l2 := &Lab{ID: "l2", TrackID: "t", LabOrder: 2}
+	x := &Lab{ID: "x", Prerequisites: []string{"l2", "apagado"}}
+	labs := []*Lab{l1, l2, x}
+
+	if cycle := PrerequisiteCycle(labs, tracks); cycle != nil {
+		t.Fatalf("ciclo inesperado: %v", cycle)
+	}
+
+	// O primeiro lab da trilha sequencial passa a depender de um lab que
+	// depende do segundo
+	l1.Prerequisites = []string{"x"}
+	if got, want := PrerequisiteCycle(labs, tracks), []string{"l1", "x", "l2", "l1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ciclo: %v, esperava %v", got, want)
+	}
+}
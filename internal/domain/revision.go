@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// CodeRevision é uma fotografia do código submetido numa execução. Code é o
+// ficheiro principal (user_code) e Files os restantes ficheiros do
+// workspace. Outcome fica vazio enquanto a execução decorre.
+type CodeRevision struct {
+	WorkspaceID string            `json:"workspace_id"`
+	Revision    int               `json:"revision"`
+	Mode        ExecutionMode     `json:"mode"`
+	Outcome     string            `json:"outcome,omitempty"`
+	Code        string            `json:"code,omitempty"`
+	Files       map[string]string `json:"files,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// StateVersion é uma versão gravada do estado Terraform de um workspace.
+// Version é sequencial por workspace; Serial e Lineage vêm do próprio
+// tfstate. RestoredFrom indica a versão reposta por um rollback.
+type StateVersion struct {
+	WorkspaceID  string    `json:"workspace_id"`
+	Version      int       `json:"version"`
+	Serial       int64     `json:"serial"`
+	Lineage      string    `json:"lineage"`
+	Size         int       `json:"size"`
+	RestoredFrom *int      `json:"restored_from,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	State        []byte    `json:"-"`
+}
+
+// StateDiff são as diferenças entre duas versões do estado, por endereço
+// de recurso.
+type StateDiff struct {
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+type tfState struct {
+	Serial    int64  `json:"serial"`
+	Lineage   string `json:"lineage"`
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey   any             `json:"index_key"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// StateMetadata lê o serial e a lineage de um tfstate. Estados ilegíveis
+// devolvem os valores zero.
+func StateMetadata(state []byte) (int64, string) {
+	var s tfState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return 0, ""
+	}
+	return s.Serial, s.Lineage
+}
+
+// StateResources devolve os atributos de cada instância de recurso de um
+// tfstate, indexados pelo endereço (ex: module.net.aws_vpc.this,
+// aws_sqs_queue.q[0], data.aws_region.current).
+func StateResources(state []byte) (map[string]any, error) {
+	resources := map[string]any{}
+	if len(state) == 0 {
+		return resources, nil
+	}
+
+	var s tfState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return nil, fmt.Errorf("estado Terraform ilegível: %w", err)
+	}
+
+	for _, r := range s.Resources {
+		address := r.Type + "." + r.Name
+		if r.Mode == "data" {
+			address = "data." + address
+		}
+		if r.Module != "" {
+			address = r.Module + "." + address
+		}
+		for _, inst := range r.Instances {
+			key := address
+			switch idx := inst.IndexKey.(type) {
+			case string:
+				key = fmt.Sprintf("%s[%q]", address, idx)
+			case float64:
+				key = fmt.Sprintf("%s[%d]", address, int(idx))
+			}
+
+			var attrs any
+			if len(inst.Attributes) > 0 {
+				if err := json.Unmarshal(inst.Attributes, &attrs); err != nil {
+					return nil, fmt.Errorf("atributos ilegíveis em %s: %w", key, err)
+				}
+			}
+			resources[key] = attrs
+		}
+	}
+	return resources, nil
+}
+
+// DiffStates compara dois tfstate recurso a recurso.
+func DiffStates(from, to []byte) (*StateDiff, error) {
+	before, err := StateResources(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := StateResources(to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &StateDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for address, attrs := range after {
+		old, ok := before[address]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, address)
+		case !reflect.DeepEqual(old, attrs):
+			diff.Changed = append(diff.Changed, address)
+		}
+	}
+	for address := range before {
+		if _, ok := after[address]; !ok {
+			diff.Removed = append(diff.Removed, address)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff, nil
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+const stateV1 = `{
+  "version": 4, "serial": 3, "lineage": "abc-123",
+  "resources": [
+    {"mode": "managed", "type": "aws_s3_bucket", "name": "data",
+     "instances": [{"attributes": {"bucket": "lab-data", "tags": {"Env": "dev"}}}]},
+    {"mode": "managed", "type": "aws_sqs_queue", "name": "q",
+     "instances": [{"index_key": 0, "attributes": {"name": "q0"}}, {"index_key": 1, "attributes": {"name": "q1"}}]},
+    {"mode": "data", "type": "aws_region", "name": "current", "instances": [{"attributes": {"name": "us-east-1"}}]}
+  ]
+}`
+
+const stateV2 = `{
+  "version": 4, "serial": 5, "lineage": "abc-123",
+  "resources": [
+    {"mode": "managed", "type": "aws_s3_bucket", "name": "data",
+     "instances": [{"attributes": {"bucket": "lab-data", "tags": {"Env": "prod"}}}]},
+    {"mode": "managed", "type": "aws_sqs_queue", "name": "q",
+     "instances": [{"index_key": 0, "attributes": {"name": "q0"}}]},
+    {"module": "module.net", "mode": "managed", "type": "aws_vpc", "name": "this",
+     "instances": [{"index_key": "a", "attributes": {"cidr_block": "10.0.0.0/16"}}]},
+    {"mode": "data", "type": "aws_region", "name": "current", "instances": [{"attributes": {"name": "us-east-1"}}]}
+  ]
+}`
+
+func TestStateMetadata(t *testing.T) {
+	serial, lineage := StateMetadata([]byte(stateV2))
+	if serial != 5 || lineage != "abc-123" {
+		t.Errorf("StateMetadata = %d, %q", serial, lineage)
+	}
+	if serial, lineage := StateMetadata([]byte("lixo")); serial != 0 || lineage != "" {
+		t.Errorf("estado ilegível devia devolver zeros, obteve %d, %q", serial, lineage)
+	}
+}
+
+func TestDiffStates(t *testing.T) {
+	diff, err := DiffStates([]byte(stateV1), []byte(stateV2))
+	if err != nil {
+		t.Fatalf("DiffStates: %v", err)
+	}
+
+	want := &StateDiff{
+		Added:   []string{`module.net.aws_vpc.this["a"]`},
+		Removed: []string{"aws_sqs_queue.q[1]"},
+		Changed: []string{"aws_s3_bucket.data"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffStates = %+v, esperado %+v", diff, want)
+	}
+
+	diff, err = DiffStates(nil, []byte(stateV1))
+	if err != nil {
+		t.Fatalf("DiffStates a partir de estado vazio: %v", err)
+	}
+	if len(diff.Added) != 4 || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Errorf("a partir de estado vazio todos os recursos deviam ser adicionados: %+v", diff)
+	}
+}
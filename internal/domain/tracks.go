@@ -7,6 +7,8 @@ type Track struct {
 	Title       string     `json:"title"`
 	Description string     `json:"description"`
 	CreatedAt   time.Time  `json:"created_at"`
+	// Sequential obriga a concluir os labs da trilha pela ordem de LabOrder
+	Sequential  bool       `json:"sequential"`
 
 
 	Labs        []*Lab     `json:"labs"`
@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+const (
+	RoleAdmin   = "admin"
+	RoleAuthor  = "author"
+	RoleLearner = "learner"
+)
+
+type User struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HasRole indica se o utilizador possui um dos papéis informados.
+func (u *User) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleAuthor, RoleLearner:
+		return true
+	}
+	return false
+}
+
+// APIToken é um token de acesso à API. Apenas o hash SHA-256 do token é
+// persistido; o valor em claro só é devolvido no momento da emissão.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ValidationCheck é uma verificação nomeada da solução de um lab. O comando
+// corre em "sh -c" no container da execução, depois do passo do utilizador.
+type ValidationCheck struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	// ExpectExitCode é o código de saída esperado (0 por omissão).
+	ExpectExitCode int `json:"expect_exit_code,omitempty"`
+	// ExpectOutput, se definido, tem de constar do output do comando.
+	ExpectOutput string `json:"expect_output,omitempty"`
+	// Weight é o peso da verificação na pontuação (1 por omissão).
+	Weight int    `json:"weight,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// EffectiveWeight devolve o peso da verificação, aplicando o padrão.
+func (c ValidationCheck) EffectiveWeight() int {
+	if c.Weight <= 0 {
+		return 1
+	}
+	return c.Weight
+}
+
+// ValidateChecks verifica se a lista de verificações de um lab está completa.
+func ValidateChecks(checks []ValidationCheck) error {
+	names := make(map[string]bool, len(checks))
+	for i, c := range checks {
+		if c.Name == "" || c.Command == "" {
+			return fmt.Errorf("verificação %d: nome e comando são obrigatórios", i+1)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("verificação duplicada: %s", c.Name)
+		}
+		if c.Weight < 0 {
+			return fmt.Errorf("verificação %s: peso negativo", c.Name)
+		}
+		names[c.Name] = true
+	}
+	return nil
+}
+
+// CheckResult é o resultado de uma ValidationCheck. Hint só é preenchido
+// quando a verificação falha.
+type CheckResult struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Weight   int    `json:"weight"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output,omitempty"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// ValidationReport agrega os resultados das verificações de uma execução.
+type ValidationReport struct {
+	Checks   []CheckResult `json:"checks"`
+	Score    int           `json:"score"`
+	MaxScore int           `json:"max_score"`
+	Passed   bool          `json:"passed"`
+	// HintPenalty é o total descontado de Score pelas dicas reveladas.
+	HintPenalty int `json:"hint_penalty,omitempty"`
+}
+
+// ApplyHintPenalty desconta da pontuação a penalização das dicas reveladas.
+func (r *ValidationReport) ApplyHintPenalty(penalty int) {
+	r.HintPenalty = penalty
+	r.Score -= penalty
+	if r.Score < 0 {
+		r.Score = 0
+	}
+}
+
+// FailedChecks devolve os nomes das verificações que falharam.
+func (r *ValidationReport) FailedChecks() map[string]bool {
+	failed := make(map[string]bool)
+	for _, c := range r.Checks {
+		if !c.Passed {
+			failed[c.Name] = true
+		}
+	}
+	return failed
+}
+
+// NewValidationReport calcula a pontuação a partir dos resultados.
+func NewValidationReport(results []CheckResult) *ValidationReport {
+	report := &ValidationReport{Checks: results, Passed: true}
+	for _, r := range results {
+		report.MaxScore += r.Weight
+		if r.Passed {
+			report.Score += r.Weight
+		} else {
+			report.Passed = false
+		}
+	}
+	return report
+}
+
+// Hint é uma dica de um lab, revelada progressivamente a pedido do aluno.
+// Com Check, a dica só é revelada quando essa verificação falhou na última
+// validação. Penalty é descontado da pontuação das validações seguintes.
+type Hint struct {
+	Text    string `json:"text"`
+	Check   string `json:"check,omitempty"`
+	Penalty int    `json:"penalty,omitempty"`
+}
+
+// ValidateHints verifica as dicas de um lab contra as suas verificações.
+func ValidateHints(hints []Hint, checks []ValidationCheck) error {
+	names := make(map[string]bool, len(checks))
+	for _, c := range checks {
+		names[c.Name] = true
+	}
+	for i, h := range hints {
+		if h.Text == "" {
+			return fmt.Errorf("dica %d: texto é obrigatório", i+1)
+		}
+		if h.Check != "" && !names[h.Check] {
+			return fmt.Errorf("dica %d: verificação desconhecida: %s", i+1, h.Check)
+		}
+		if h.Penalty < 0 {
+			return fmt.Errorf("dica %d: penalização negativa", i+1)
+		}
+	}
+	return nil
+}
+
+// RevealedHint é uma dica já revelada num workspace. Index é a posição da
+// dica na lista do lab.
+type RevealedHint struct {
+	Index      int       `json:"index"`
+	Text       string    `json:"text"`
+	Check      string    `json:"check,omitempty"`
+	Penalty    int       `json:"penalty,omitempty"`
+	RevealedAt time.Time `json:"revealed_at"`
+}
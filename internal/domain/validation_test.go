@@ -0,0 +1,72 @@
+package domain
+
+import "testing"
+
+func TestNewValidationReport(t *testing.T) {
+	tests := []struct {
+		name         string
+		results      []CheckResult
+		wantScore    int
+		wantMaxScore int
+		wantPassed   bool
+	}{
+		{"sem verificações", nil, 0, 0, true},
+		{"todas passam", []CheckResult{{Passed: true, Weight: 1}, {Passed: true, Weight: 3}}, 4, 4, true},
+		{"uma falha", []CheckResult{{Passed: true, Weight: 1}, {Passed: false, Weight: 3}}, 1, 4, false},
+		{"todas falham", []CheckResult{{Weight: 2}, {Weight: 2}}, 0, 4, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewValidationReport(tt.results)
+			if r.Score != tt.wantScore || r.MaxScore != tt.wantMaxScore || r.Passed != tt.wantPassed {
+				t.Errorf("relatório = %d/%d passed=%v, esperava %d/%d passed=%v",
+					r.Score, r.MaxScore, r.Passed, tt.wantScore, tt.wantMaxScore, tt.wantPassed)
+			}
+		})
+	}
+}
+
+func TestValidateChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		checks  []ValidationCheck
+		wantErr bool
+	}{
+		{"válidas", []ValidationCheck{{Name: "a", Command: "true"}, {Name: "b", Command: "true", Weight: 2}}, false},
+		{"sem comando", []ValidationCheck{{Name: "a"}}, true},
+		{"nome repetido", []ValidationCheck{{Name: "a", Command: "true"}, {Name: "a", Command: "false"}}, true},
+		{"peso negativo", []ValidationCheck{{Name: "a", Command: "true", Weight: -1}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateChecks(tt.checks); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateChecks() erro = %v, esperava erro = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyHintPenalty(t *testing.T) {
+	tests := []struct {
+		name      string
+		score     int
+		penalty   int
+		wantScore int
+	}{
+		{"sem dicas", 4, 0, 4},
+		{"desconta a penalização", 4, 3, 1},
+		{"nunca fica negativa", 2, 5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ValidationReport{Score: tt.score, MaxScore: 4}
+			r.ApplyHintPenalty(tt.penalty)
+			if r.Score != tt.wantScore || r.HintPenalty != tt.penalty || r.MaxScore != 4 {
+				t.Errorf("relatório = %+v, esperava pontuação %d e penalização %d", r, tt.wantScore, tt.penalty)
+			}
+		})
+	}
+}
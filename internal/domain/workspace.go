@@ -1,6 +1,11 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
 
 const (
 	WorkspaceStatusInProgress = "in_progress"
@@ -10,9 +15,90 @@ const (
 type Workspace struct {
 	ID        string 	`json:"id"`
 	LabID     string 	`json:"lab_id"`
+	UserID    string 	`json:"user_id"`
 	UserCode  string 	`json:"user_code"`
 	State     []byte 	`json:"state"`
 	UpdatedAt time.Time `json:"updated_at"`
 
 	Status    string    `json:"status"`
-}	
\ No newline at end of file
+
+	// Score e MaxScore são os da última validação com verificações estruturadas
+	Score     int               `json:"score"`
+	MaxScore  int               `json:"max_score"`
+	Report    *ValidationReport `json:"validation_report,omitempty"`
+	HintsUsed int               `json:"hints_used"`
+
+	Files     []*WorkspaceFile `json:"files,omitempty"`
+}	
+
+// Limites da árvore de ficheiros de um workspace
+const (
+	MaxWorkspaceFiles    = 200
+	MaxWorkspaceFileSize = 512 * 1024
+)
+
+// WorkspaceFile é um ficheiro da árvore de um workspace. O ficheiro principal
+// do tipo do lab (ver EntryFile) corresponde a Workspace.UserCode.
+type WorkspaceFile struct {
+	Path      string    `json:"path"`
+	Content   string    `json:"content,omitempty"`
+	Size      int       `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FileChanges descreve alterações à árvore de ficheiros de um workspace. Com
+// Replace, Files é a árvore completa e os ficheiros ausentes são apagados;
+// caso contrário Files contém os ficheiros criados ou alterados e Deleted os
+// caminhos removidos.
+type FileChanges struct {
+	Replace bool
+	Files   map[string]string
+	Deleted []string
+}
+
+// IsEmpty indica se não há alterações a aplicar.
+func (c FileChanges) IsEmpty() bool {
+	return !c.Replace && len(c.Files) == 0 && len(c.Deleted) == 0
+}
+
+// EntryFile devolve o ficheiro onde o executor escreve o código principal
+// (UserCode) de cada tipo de lab.
+func EntryFile(execType ExecutionType) string {
+	switch execType {
+	case TypeTerraform:
+		return "main.tf"
+	case TypeAnsible:
+		return "playbook.yml"
+	case TypeGithubActions:
+		return ".github/workflows/main.yml"
+	}
+	return "run.sh"
+}
+
+// reservedFiles são gerados pelo executor e não podem ser escritos pelo utilizador.
+var reservedFiles = map[string]bool{
+	"provider.tf":              true,
+	"terraform.tfstate":        true,
+	"terraform.tfstate.backup": true,
+	"inventory.ini":            true,
+	"kubeconfig.yaml":          true,
+	"validation.yml":           true,
+	"validation.sh":            true,
+}
+
+// CleanFilePath normaliza o caminho de um ficheiro do workspace, rejeitando
+// caminhos absolutos, que saiam do workspace ou reservados ao executor.
+func CleanFilePath(p string) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(strings.TrimSpace(p), "\\", "/"))
+	switch {
+	case cleaned == "." || cleaned == "/":
+		return "", fmt.Errorf("caminho de ficheiro vazio")
+	case strings.HasPrefix(cleaned, "/"):
+		return "", fmt.Errorf("caminho absoluto não permitido: %s", p)
+	case cleaned == ".." || strings.HasPrefix(cleaned, "../"):
+		return "", fmt.Errorf("caminho fora do workspace: %s", p)
+	case reservedFiles[cleaned]:
+		return "", fmt.Errorf("ficheiro reservado: %s", cleaned)
+	}
+	return cleaned, nil
+}
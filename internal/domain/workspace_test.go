@@ -0,0 +1,37 @@
+package domain
+
+import "testing"
+
+func TestCleanFilePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"main.tf", "main.tf", false},
+		{" modules/net/main.tf ", "modules/net/main.tf", false},
+		{"modules\\net\\vars.tf", "modules/net/vars.tf", false},
+		{"./a/../b.tf", "b.tf", false},
+		{"", "", true},
+		{".", "", true},
+		{"/etc/passwd", "", true},
+		{"..", "", true},
+		{"../fora.tf", "", true},
+		{"a/../../fora.tf", "", true},
+		{"..\\fora.tf", "", true},
+		{"terraform.tfstate", "", true},
+		{"./provider.tf", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := CleanFilePath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CleanFilePath(%q) erro = %v, esperava erro = %v", tt.path, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CleanFilePath(%q) = %q, esperava %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+	"strings"
+	"time"
+)
+
+// maxCheckOutput limita o output de cada verificação guardado no relatório.
+const maxCheckOutput = 2048
+
+// runChecks corre as verificações estruturadas do lab uma a uma, publicando
+// uma linha por verificação, e devolve o relatório com a pontuação.
+func (e *dockerExecutor) runChecks(ctx context.Context, containerID string, config domain.ExecutionConfig, logStream chan<- service.ExecutionResult) *domain.ValidationReport {
+	_, env := e.getStepCommand(config, true)
+	// Os recursos do Kubernetes podem demorar a ficar prontos
+	retry := config.Type == domain.TypeK8s
+
+	results := make([]domain.CheckResult, 0, len(config.Checks))
+	for _, check := range config.Checks {
+		if ctx.Err() != nil {
+			break
+		}
+		res := e.runCheck(ctx, containerID, check, env, retry)
+		if res.Passed {
+			logStream <- service.ExecutionResult{Line: fmt.Sprintf("✅ %s", check.Name)}
+		} else {
+			logStream <- service.ExecutionResult{Line: fmt.Sprintf("❌ %s", check.Name)}
+		}
+		results = append(results, res)
+	}
+	return domain.NewValidationReport(results)
+}
+
+func (e *dockerExecutor) runCheck(ctx context.Context, containerID string, check domain.ValidationCheck, env []string, retry bool) domain.CheckResult {
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		res := e.execQuiet(ctx, containerID, []string{"sh", "-c", check.Command}, env)
+		result := checkResult(check, res)
+		if result.Passed || !retry || time.Now().After(deadline) {
+			return result
+		}
+
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return domain.CheckResult{Name: check.Name, Weight: check.EffectiveWeight(), ExitCode: res.ExitCode, Hint: check.Hint}
+		}
+	}
+}
+
+// checkResult avalia o resultado do comando da verificação: passa com o
+// código de saída esperado e, se definido, o output esperado.
+func checkResult(check domain.ValidationCheck, res domain.StepResult) domain.CheckResult {
+	passed := res.Error == nil && res.ExitCode == check.ExpectExitCode &&
+		strings.Contains(res.Output, check.ExpectOutput)
+
+	result := domain.CheckResult{
+		Name:     check.Name,
+		Passed:   passed,
+		Weight:   check.EffectiveWeight(),
+		ExitCode: res.ExitCode,
+		Output:   truncateOutput(res.Output),
+	}
+	if !passed {
+		result.Hint = check.Hint
+	}
+	return result
+}
+
+// execQuiet corre um comando no container sem publicar o seu output.
+func (e *dockerExecutor) execQuiet(ctx context.Context, containerID string, cmd []string, env []string) domain.StepResult {
+	discard := make(chan service.ExecutionResult)
+	drained := make(chan struct{})
+	go func() {
+		for range discard {
+		}
+		close(drained)
+	}()
+
+	res := e.execStep(ctx, containerID, cmd, env, "/workspace", discard)
+	close(discard)
+	<-drained
+	return res
+}
+
+func truncateOutput(output string) string {
+	if len(output) <= maxCheckOutput {
+		return output
+	}
+	return "..." + output[len(output)-maxCheckOutput:]
+}
@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"lab-devops/internal/domain"
+)
+
+func TestCheckResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		check      domain.ValidationCheck
+		res        domain.StepResult
+		wantPassed bool
+		wantWeight int
+	}{
+		{"código zero", domain.ValidationCheck{Name: "ok"}, domain.StepResult{}, true, 1},
+		{"código diferente", domain.ValidationCheck{Name: "ok", Hint: "veja o bucket"}, domain.StepResult{ExitCode: 1}, false, 1},
+		{"código esperado não zero", domain.ValidationCheck{Name: "sem ficheiro", ExpectExitCode: 1, Weight: 3}, domain.StepResult{ExitCode: 1}, true, 3},
+		{"output esperado", domain.ValidationCheck{Name: "versão", ExpectOutput: "v1.2"}, domain.StepResult{Output: "app v1.2\n"}, true, 1},
+		{"output em falta", domain.ValidationCheck{Name: "versão", ExpectOutput: "v1.2"}, domain.StepResult{Output: "app v1.1\n"}, false, 1},
+		{"falha ao correr", domain.ValidationCheck{Name: "ok"}, domain.StepResult{Error: errors.New("exec falhou")}, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkResult(tt.check, tt.res)
+			if got.Passed != tt.wantPassed || got.Weight != tt.wantWeight || got.Name != tt.check.Name {
+				t.Fatalf("checkResult() = %+v, esperava passed=%v weight=%d", got, tt.wantPassed, tt.wantWeight)
+			}
+			if wantHint := !tt.wantPassed && tt.check.Hint != ""; (got.Hint != "") != wantHint {
+				t.Errorf("dica = %q: só deve aparecer quando a verificação falha", got.Hint)
+			}
+		})
+	}
+}
+
+func TestCheckResultTruncatesOutput(t *testing.T) {
+	output := strings.Repeat("x", maxCheckOutput) + "fim"
+	got := checkResult(domain.ValidationCheck{Name: "ok"}, domain.StepResult{Output: output})
+	if len(got.Output) != maxCheckOutput+len("...") || !strings.HasSuffix(got.Output, "fim") {
+		t.Fatalf("output guardado com %d bytes, esperava o fim truncado a %d", len(got.Output), maxCheckOutput)
+	}
+}
@@ -3,13 +3,16 @@ package executor
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"lab-devops/internal/domain"
 	"lab-devops/internal/service"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -55,9 +58,17 @@ type dockerExecutor struct {
 	dockerNetwork string
 	tempDirRoot   string
 	hostExecPath  string
+	defaultLimits domain.ResourceLimits
+	pool          *containerPool
+
+	imagesMu sync.RWMutex
+	images   map[domain.ExecutionType]string
 }
 
-func NewDockerExecutor(dockerNetwork string, tempDirRoot string) (service.Executor, error) {
+// NewDockerExecutor cria o executor. defaultLimits é aplicado aos labs que não
+// definem a sua própria política de recursos. Com poolSize > 0 são mantidos
+// poolSize containers pré-iniciados para cada tipo em poolTypes.
+func NewDockerExecutor(dockerNetwork string, tempDirRoot string, defaultLimits domain.ResourceLimits, poolSize int, poolTypes []domain.ExecutionType) (service.Executor, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return nil, fmt.Errorf("falha ao criar cliente Docker: %w", err)
@@ -72,12 +83,29 @@ func NewDockerExecutor(dockerNetwork string, tempDirRoot string) (service.Execut
 		return nil, fmt.Errorf("variável de ambiente HOST_EXEC_PATH não está definida")
 	}
 
-	return &dockerExecutor{
+	e := &dockerExecutor{
 		cli:           cli,
 		dockerNetwork: dockerNetwork,
 		tempDirRoot:   tempDirRoot,
 		hostExecPath:  hostPath,
-	}, nil
+		defaultLimits: defaultLimits,
+		images:        make(map[domain.ExecutionType]string, len(domain.DefaultImages)),
+	}
+	for execType, img := range domain.DefaultImages {
+		e.images[execType] = img
+	}
+
+	if poolSize > 0 {
+		for _, t := range poolTypes {
+			if _, _, err := e.imageForType(t); err != nil {
+				return nil, fmt.Errorf("pool de containers: %w", err)
+			}
+		}
+		e.pool = newContainerPool(e, poolSize, poolTypes)
+		go e.pool.start()
+	}
+
+	return e, nil
 }
 
 // Helper: Tenta ler provider.f da pasta data, senão usa o default
@@ -100,6 +128,16 @@ func (e *dockerExecutor) Execute(ctx context.Context, config domain.ExecutionCon
 		defer close(logStream)
 		defer close(finalState)
 
+		// O timeout do lab cobre todo o ciclo (container, execução e validação).
+		// runCtx termina no timeout ou quando ctx é cancelado pelo cliente.
+		limits := config.Limits.WithDefaults(e.defaultLimits)
+		runCtx := ctx
+		if limits.TimeoutSeconds > 0 {
+			var cancelTimeout context.CancelFunc
+			runCtx, cancelTimeout = context.WithTimeout(ctx, time.Duration(limits.TimeoutSeconds)*time.Second)
+			defer cancelTimeout()
+		}
+
 		// 1. Preparar arquivos locais
 		execDir, err := e.prepareWorkspace(config)
 		if err != nil {
@@ -108,88 +146,177 @@ func (e *dockerExecutor) Execute(ctx context.Context, config domain.ExecutionCon
 		}
 		defer os.RemoveAll(execDir)
 
-		// Aguardar sincronização do filesystem (Docker Desktop WSL2)
-		// O prepareWorkspace escreve ficheiros via bind mount, mas o Docker daemon
-		// pode não ver os ficheiros imediatamente devido ao delay de sync do WSL2.
-		time.Sleep(1 * time.Second)
-
-		// 2. Iniciar Container (Session Manager)
-		containerID, err := e.startContainer(ctx, config)
+		// 2. Obter Container: pré-aquecido do pool ou criado para esta execução
+		lc, err := e.acquireContainer(runCtx, config, limits, execDir)
 		if err != nil {
+			if runCtx.Err() != nil {
+				reportInterrupted(ctx, config.WorkspaceID, limits, domain.StepResult{}, nil, finalState)
+				return
+			}
 			reportError(config.WorkspaceID, fmt.Errorf("falha ao iniciar container: %w", err), finalState)
 			return
 		}
-		defer e.stopContainer(context.Background(), containerID)
+		defer e.releaseContainer(lc)
+
+		e.runSteps(ctx, runCtx, limits, lc, execDir, config, logStream, finalState)
+	}()
+
+	return logStream, finalState, nil
+}
+
+// runSteps corre no container os passos da execução (código do utilizador
+// e validação) e envia o estado final. ctx é o contexto do cliente e runCtx
+// o mesmo com o timeout do lab. Usado pelo Execute e pelas sessões.
+func (e *dockerExecutor) runSteps(ctx, runCtx context.Context, limits domain.ResourceLimits, lc labContainer, execDir string, config domain.ExecutionConfig, logStream chan<- service.ExecutionResult, finalState chan<- service.ExecutionFinalState) {
+	containerID := lc.id
+	readState := func() ([]byte, error) {
+		if config.Mode == domain.ModePlan || config.Mode == domain.ModeCommand {
+			return nil, nil
+		}
+		return e.collectFinalState(lc, execDir, config)
+	}
 
-		// 3. Executar Código do Usuário
+	// 3. Executar Código do Usuário (numa sessão, ModeValidate valida o
+	// que já está no container)
+	var execResult domain.StepResult
+	if config.Mode != domain.ModeValidate {
 		logStream <- service.ExecutionResult{Line: "--- INICIANDO EXECUÇÃO ---"}
 		execCmd, execEnv := e.getStepCommand(config, false)
+		execResult = e.execStep(runCtx, containerID, execCmd, execEnv, "/workspace", logStream)
+	}
+	if runCtx.Err() != nil {
+		newState, _ := readState()
+		reportInterrupted(ctx, config.WorkspaceID, limits, execResult, newState, finalState)
+		return
+	}
+	if e.wasOOMKilled(containerID, execResult, limits) {
+		newState, _ := readState()
+		reportOOM(config.WorkspaceID, limits, execResult, newState, finalState)
+		return
+	}
 
-		// Pequeno sleep para garantir que container está pronto (workaround para race conditions)
-		time.Sleep(500 * time.Millisecond)
+	// Modos plan, destroy e command: não há nada a validar
+	switch config.Mode {
+	case domain.ModePlan:
+		finalState <- e.finishPlan(lc, execDir, config, execResult)
+		return
+	case domain.ModeDestroy:
+		newState, readErr := readState()
+		final := service.ExecutionFinalState{
+			WorkspaceID:     config.WorkspaceID,
+			NewState:        newState,
+			Error:           readErr,
+			Outcome:         domain.OutcomeSuccess,
+			ExecutionResult: execResult,
+		}
+		if execResult.ExitCode != 0 {
+			final.Error = fmt.Errorf("destroy falhou com código %d", execResult.ExitCode)
+		}
+		if final.Error != nil {
+			final.Outcome = domain.OutcomeFailed
+		}
+		finalState <- final
+		return
+	case domain.ModeCommand:
+		final := service.ExecutionFinalState{
+			WorkspaceID:     config.WorkspaceID,
+			Outcome:         domain.OutcomeSuccess,
+			ExecutionResult: execResult,
+		}
+		if execResult.Error != nil {
+			final.Error = execResult.Error
+		} else if execResult.ExitCode != 0 {
+			final.Error = fmt.Errorf("comando terminou com código %d", execResult.ExitCode)
+		}
+		if final.Error != nil {
+			final.Outcome = domain.OutcomeFailed
+		}
+		finalState <- final
+		return
+	}
 
-		execResult := e.execStep(ctx, containerID, execCmd, execEnv, "/workspace", logStream)
+	var validationResult domain.StepResult
+	var report *domain.ValidationReport
+	validated := false
 
-		var validationResult domain.StepResult
+	// 4. Executar Validação (Se necessário e se execução passou)
+	if execResult.ExitCode == 0 {
+		validationResult, report, validated = e.validate(runCtx, lc, execDir, config, logStream)
+	}
+	if runCtx.Err() != nil {
+		newState, _ := readState()
+		reportInterrupted(ctx, config.WorkspaceID, limits, validationResult, newState, finalState)
+		return
+	}
 
-		// 4. Executar Validação (Se necessário e se execução passou)
-		if execResult.ExitCode == 0 && config.ValidationCode != "" {
-			logStream <- service.ExecutionResult{Line: "\n--- INICIANDO VALIDAÇÃO ---"}
-			valCmd, valEnv := e.getStepCommand(config, true)
+	// 5. Ler Estado Final (Terraform)
+	newState, readErr := readState()
+	var finalErr error
+	if execResult.ExitCode != 0 {
+		finalErr = fmt.Errorf("execução falhou com código %d", execResult.ExitCode)
+	} else if readErr != nil {
+		finalErr = readErr
+	}
 
-			// Se for Kubernetes, usa lógica de retry
-			if config.Type == domain.TypeK8s {
-				validationResult = e.runWithRetry(ctx, containerID, valCmd, valEnv, "/workspace", logStream)
-			} else {
-				validationResult = e.execStep(ctx, containerID, valCmd, valEnv, "/workspace", logStream)
-			}
-		}
+	outcome := domain.OutcomeSuccess
+	if finalErr != nil || validationResult.ExitCode != 0 || validationResult.Error != nil || (report != nil && !report.Passed) {
+		outcome = domain.OutcomeFailed
+	}
 
-		// 5. Ler Estado Final (Terraform)
-		newState, readErr := e.readFinalState(execDir, config)
-		var finalErr error
-		if execResult.ExitCode != 0 {
-			finalErr = fmt.Errorf("execução falhou com código %d", execResult.ExitCode)
-		} else if readErr != nil {
-			finalErr = readErr
-		}
+	finalState <- service.ExecutionFinalState{
+		WorkspaceID:      config.WorkspaceID,
+		NewState:         newState,
+		Error:            finalErr,
+		Outcome:          outcome,
+		ExecutionResult:  execResult,
+		ValidationResult: validationResult,
+		Validated:        validated,
+		Report:           report,
+	}
+}
 
-		finalState <- service.ExecutionFinalState{
-			WorkspaceID:      config.WorkspaceID,
-			NewState:         newState,
-			Error:            finalErr,
-			ExecutionResult:  execResult,
-			ValidationResult: validationResult,
+// validate corre a validação do lab no container da execução: o script de
+// validação (se houver) e depois as verificações estruturadas. validated é
+// falso quando o lab não define nenhuma das duas.
+func (e *dockerExecutor) validate(ctx context.Context, lc labContainer, execDir string, config domain.ExecutionConfig, logStream chan<- service.ExecutionResult) (validationResult domain.StepResult, report *domain.ValidationReport, validated bool) {
+	if config.ValidationCode != "" {
+		validated = true
+		logStream <- service.ExecutionResult{Line: "\n--- INICIANDO VALIDAÇÃO ---"}
+		valCmd, valEnv := e.getStepCommand(config, true)
+
+		switch {
+		case config.Type == domain.TypeK8s:
+			// Se for Kubernetes, usa lógica de retry
+			validationResult = e.runWithRetry(ctx, lc.id, valCmd, valEnv, "/workspace", logStream)
+		case isTerraformAssertions(config):
+			validationResult = e.runTerraformAssertions(ctx, lc, execDir, config.ValidationCode, logStream)
+		default:
+			validationResult = e.execStep(ctx, lc.id, valCmd, valEnv, "/workspace", logStream)
 		}
-	}()
+	}
 
-	return logStream, finalState, nil
+	// Verificações estruturadas, avaliadas uma a uma
+	if len(config.Checks) > 0 && ctx.Err() == nil {
+		validated = true
+		logStream <- service.ExecutionResult{Line: "\n--- VERIFICAÇÕES ---"}
+		report = e.runChecks(ctx, lc.id, config, logStream)
+	}
+	return validationResult, report, validated
 }
 
-func (e *dockerExecutor) startContainer(ctx context.Context, config domain.ExecutionConfig) (string, error) {
+func (e *dockerExecutor) startContainer(ctx context.Context, config domain.ExecutionConfig, limits domain.ResourceLimits) (string, error) {
 	hostDir := filepath.Join(e.hostExecPath, config.WorkspaceID)
-	mounts := []mount.Mount{
-		{Type: mount.TypeBind, Source: hostDir, Target: "/workspace"},
-	}
+	workspaceMount := mount.Mount{Type: mount.TypeBind, Source: hostDir, Target: "/workspace"}
+	return e.runLabContainer(ctx, config.Type, config.Image, workspaceMount, limits, nil)
+}
 
-	var img string
-	switch config.Type {
-	case domain.TypeTerraform:
-		img = "hashicorp/terraform:latest"
-	case domain.TypeAnsible:
-		img = "cytopia/ansible:latest"
-	case domain.TypeLinux:
-		img = "alpine:latest"
-	case domain.TypeDocker:
-		img = "docker:cli"
-		mounts = append(mounts, mount.Mount{Type: mount.TypeBind, Source: "/var/run/docker.sock", Target: "/var/run/docker.sock"})
-	case domain.TypeK8s:
-		img = "bitnami/kubectl:latest"
-	case domain.TypeGithubActions:
-		img = "docker:cli"
-		mounts = append(mounts, mount.Mount{Type: mount.TypeBind, Source: "/var/run/docker.sock", Target: "/var/run/docker.sock"})
-	default:
-		return "", fmt.Errorf("tipo não suportado: %s", config.Type)
+// runLabContainer cria e inicia um container do tipo informado, mantido vivo
+// por "tail -f /dev/null", com workspaceMount montado em /workspace. Se o lab
+// tiver imagem própria (custom), esta substitui a imagem do tipo.
+func (e *dockerExecutor) runLabContainer(ctx context.Context, execType domain.ExecutionType, custom *domain.LabImage, workspaceMount mount.Mount, limits domain.ResourceLimits, labels map[string]string) (string, error) {
+	img, extraMounts, err := e.imageForType(execType)
+	if err != nil {
+		return "", err
 	}
 
 	containerConfig := &container.Config{
@@ -197,12 +324,23 @@ func (e *dockerExecutor) startContainer(ctx context.Context, config domain.Execu
 		Entrypoint: []string{"tail", "-f", "/dev/null"},
 		WorkingDir: "/workspace",
 		Tty:        true,
+		Labels:     labels,
+	}
+	if custom != nil {
+		containerConfig.Image = custom.Reference
+		containerConfig.Entrypoint = append(append([]string{}, custom.Entrypoint...), containerConfig.Entrypoint...)
+		for key, value := range custom.Env {
+			containerConfig.Env = append(containerConfig.Env, key+"="+value)
+		}
+		sort.Strings(containerConfig.Env)
+		img = custom.Reference
 	}
 
 	hostConfig := &container.HostConfig{
-		Mounts:     mounts,
+		Mounts:     append([]mount.Mount{workspaceMount}, extraMounts...),
 		AutoRemove: false,
 	}
+	applyResourceLimits(hostConfig, limits)
 
 	netConfig := &network.NetworkingConfig{
 		EndpointsConfig: map[string]*network.EndpointSettings{
@@ -257,8 +395,50 @@ func (e *dockerExecutor) startContainer(ctx context.Context, config domain.Execu
 	return "", fmt.Errorf("falha após %d tentativas: %w", maxRetries, lastErr)
 }
 
+// applyResourceLimits traduz a política do lab para a HostConfig do Docker.
+func applyResourceLimits(hostConfig *container.HostConfig, limits domain.ResourceLimits) {
+	if limits.CPUs > 0 {
+		hostConfig.Resources.NanoCPUs = int64(limits.CPUs * 1e9)
+	}
+	if limits.MemoryMB > 0 {
+		memory := limits.MemoryMB * 1024 * 1024
+		hostConfig.Resources.Memory = memory
+		hostConfig.Resources.MemorySwap = memory // sem swap além do limite
+	}
+	if limits.PidsLimit > 0 {
+		pids := limits.PidsLimit
+		hostConfig.Resources.PidsLimit = &pids
+	}
+	if limits.ReadOnlyRootfs != nil && *limits.ReadOnlyRootfs {
+		hostConfig.ReadonlyRootfs = true
+		// As ferramentas ainda precisam de diretórios graváveis (cache de
+		// plugins do Terraform em /tmp, ~/.ansible, etc.)
+		hostConfig.Tmpfs = map[string]string{
+			"/tmp":  "rw,exec,size=512m",
+			"/root": "rw,size=64m",
+		}
+	}
+	hostConfig.CapDrop = limits.DropCapabilities
+}
+
+// wasOOMKilled verifica se o passo terminou por falta de memória. O exit code
+// 137 (SIGKILL) num container com limite de memória é tratado como OOM mesmo
+// quando o Docker não marca o container como OOMKilled.
+func (e *dockerExecutor) wasOOMKilled(containerID string, res domain.StepResult, limits domain.ResourceLimits) bool {
+	if res.ExitCode != 137 {
+		return false
+	}
+
+	inspect, err := e.cli.ContainerInspect(context.Background(), containerID)
+	if err == nil && inspect.State != nil && inspect.State.OOMKilled {
+		return true
+	}
+	return limits.MemoryMB > 0
+}
+
 func (e *dockerExecutor) stopContainer(ctx context.Context, containerID string) {
-	removeOpts := container.RemoveOptions{Force: true}
+	// RemoveVolumes descarta o volume anónimo do /workspace dos containers do pool
+	removeOpts := container.RemoveOptions{Force: true, RemoveVolumes: true}
 	if err := e.cli.ContainerRemove(ctx, containerID, removeOpts); err != nil {
 		log.Printf("ERRO [Executor]: Falha ao remover container %s: %v", containerID, err)
 	}
@@ -268,26 +448,28 @@ func (e *dockerExecutor) getStepCommand(config domain.ExecutionConfig, isValidat
 	var cmd []string
 	var env []string
 
+	// A validação corre no mesmo container, depois do passo do utilizador
 	if isValidation {
 		switch config.Type {
 		case domain.TypeAnsible:
 			return []string{"ansible-playbook", "-i", "inventory.ini", "validation.yml"}, nil
 		case domain.TypeK8s:
-			if config.ValidationCode != "" {
-				return []string{"sh", "validation.sh"}, []string{"KUBECONFIG=/workspace/kubeconfig.yaml"}
-			}
-		case domain.TypeLinux:
-			// Para Linux, se houver código de validação, assumimos que foi escrito em validation.sh (ainda não implementado no prepareWorkspace para TypeLinux, mas podemos adicionar)
-			// Por enquanto, retorna echo
-			return []string{"echo", "validation not implemented for linux"}, nil
+			return []string{"sh", "validation.sh"}, []string{"KUBECONFIG=/workspace/kubeconfig.yaml"}
 		}
-		return []string{"echo", "validation not implemented"}, nil
+		return []string{"sh", "validation.sh"}, nil
 	}
 
 	switch config.Type {
 	case domain.TypeTerraform:
-		cmd = []string{"sh", "-c", "mkdir -p /tmp/plugins && rm -rf .terraform/ && terraform init -upgrade && terraform apply -auto-approve"}
 		env = []string{"TF_PLUGIN_CACHE_DIR=/tmp/plugins"}
+		switch config.Mode {
+		case domain.ModePlan:
+			cmd = []string{"sh", "-c", terraformPlanCommand}
+		case domain.ModeDestroy:
+			cmd = []string{"sh", "-c", "mkdir -p /tmp/plugins && rm -rf .terraform/ && terraform init -upgrade && terraform destroy -auto-approve"}
+		default:
+			cmd = []string{"sh", "-c", "mkdir -p /tmp/plugins && rm -rf .terraform/ && terraform init -upgrade && terraform apply -auto-approve"}
+		}
 	case domain.TypeAnsible:
 		cmd = []string{"ansible-playbook", "-i", "inventory.ini", "playbook.yml"}
 	case domain.TypeLinux, domain.TypeDocker:
@@ -298,6 +480,11 @@ func (e *dockerExecutor) getStepCommand(config domain.ExecutionConfig, isValidat
 	case domain.TypeGithubActions:
 		cmd = []string{"sh", "-c", "apk add --no-cache act --repository=http://dl-cdn.alpinelinux.org/alpine/edge/community && act push --bind --directory /workspace -P ubuntu-latest=node:18-buster-slim --container-architecture linux/amd64"}
 	}
+
+	// Um comando de sessão corre tal como foi escrito, com o ambiente do tipo
+	if config.Mode == domain.ModeCommand {
+		cmd = []string{"sh", "-c", config.Code}
+	}
 	return cmd, env
 }
 
@@ -321,6 +508,19 @@ func (e *dockerExecutor) execStep(ctx context.Context, containerID string, cmd [
 	}
 	defer resp.Close()
 
+	// Cancelamento: mata os processos do exec e fecha o stream para
+	// desbloquear a leitura abaixo
+	stepDone := make(chan struct{})
+	defer close(stepDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			e.killExecProcesses(containerID)
+			resp.Close()
+		case <-stepDone:
+		}
+	}()
+
 	var outBuf strings.Builder
 
 	// Create a WaitGroup to ensure we finish reading logs before returning
@@ -346,11 +546,14 @@ func (e *dockerExecutor) execStep(ctx context.Context, containerID string, cmd [
 
 	wg.Wait()
 
-	inspectResp, err := e.cli.ContainerExecInspect(ctx, execIDResp.ID)
+	inspectResp, err := e.cli.ContainerExecInspect(context.Background(), execIDResp.ID)
 	exitCode := 0
 	if err == nil {
 		exitCode = inspectResp.ExitCode
 	}
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
 
 	return domain.StepResult{
 		ExitCode: exitCode,
@@ -359,6 +562,24 @@ func (e *dockerExecutor) execStep(ctx context.Context, containerID string, cmd [
 	}
 }
 
+// killExecProcesses envia SIGKILL a todos os processos do container exceto o
+// PID 1 (o "tail" que mantém o container vivo), interrompendo o exec em curso.
+func (e *dockerExecutor) killExecProcesses(containerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	killResp, err := e.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd: []string{"sh", "-c", "kill -9 -1"},
+	})
+	if err != nil {
+		log.Printf("AVISO [Executor]: Falha ao criar exec de cancelamento em %s: %v", containerID, err)
+		return
+	}
+	if err := e.cli.ContainerExecStart(ctx, killResp.ID, container.ExecStartOptions{}); err != nil {
+		log.Printf("AVISO [Executor]: Falha ao cancelar exec em %s: %v", containerID, err)
+	}
+}
+
 func (e *dockerExecutor) runWithRetry(ctx context.Context, containerID string, cmd []string, env []string, workDir string, logStream chan<- service.ExecutionResult) domain.StepResult {
 	timeout := time.After(30 * time.Second)
 	ticker := time.NewTicker(2 * time.Second)
@@ -382,7 +603,39 @@ func (e *dockerExecutor) runWithRetry(ctx context.Context, containerID string, c
 
 func reportError(wsID string, err error, ch chan<- service.ExecutionFinalState) {
 	log.Printf("ERRO [Executor]: %v", err)
-	ch <- service.ExecutionFinalState{WorkspaceID: wsID, Error: err}
+	ch <- service.ExecutionFinalState{WorkspaceID: wsID, Error: err, Outcome: domain.OutcomeFailed}
+}
+
+// reportInterrupted reporta uma execução interrompida: cancelada pelo
+// cliente (parent cancelado) ou encerrada pelo timeout do lab.
+func reportInterrupted(parent context.Context, wsID string, limits domain.ResourceLimits, partial domain.StepResult, newState []byte, ch chan<- service.ExecutionFinalState) {
+	outcome := domain.OutcomeTimeout
+	err := fmt.Errorf("tempo limite de %ds excedido", limits.TimeoutSeconds)
+	if parent.Err() != nil {
+		outcome = domain.OutcomeCancelled
+		err = fmt.Errorf("execução cancelada")
+	}
+
+	log.Printf("INFO [Executor]: Execução do workspace %s interrompida: %v", wsID, err)
+	ch <- service.ExecutionFinalState{
+		WorkspaceID:     wsID,
+		NewState:        newState,
+		Error:           err,
+		Outcome:         outcome,
+		ExecutionResult: partial,
+	}
+}
+
+func reportOOM(wsID string, limits domain.ResourceLimits, partial domain.StepResult, newState []byte, ch chan<- service.ExecutionFinalState) {
+	err := fmt.Errorf("memória esgotada (limite de %d MB)", limits.MemoryMB)
+	log.Printf("INFO [Executor]: Execução do workspace %s interrompida: %v", wsID, err)
+	ch <- service.ExecutionFinalState{
+		WorkspaceID:     wsID,
+		NewState:        newState,
+		Error:           err,
+		Outcome:         domain.OutcomeOOM,
+		ExecutionResult: partial,
+	}
 }
 
 func (e *dockerExecutor) prepareWorkspace(config domain.ExecutionConfig) (string, error) {
@@ -394,6 +647,22 @@ func (e *dockerExecutor) prepareWorkspace(config domain.ExecutionConfig) (string
 	if err := os.MkdirAll(execDir, 0755); err != nil {
 		return "", err
 	}
+	if err := e.writeWorkspace(execDir, config); err != nil {
+		return "", err
+	}
+	return execDir, nil
+}
+
+// writeWorkspace escreve no execDir os ficheiros da execução. Ficheiros que
+// já lá estejam e não façam parte dela (ex.: criados numa sessão) ficam.
+// Numa sessão o utilizador controla o execDir, por isso nada aqui segue
+// links (ver writeWorkspaceFile).
+func (e *dockerExecutor) writeWorkspace(execDir string, config domain.ExecutionConfig) error {
+	// Ficheiros adicionais primeiro: os ficheiros gerados abaixo (código
+	// principal, provider, inventário...) prevalecem sobre eles
+	if err := writeWorkspaceFiles(execDir, config.Files); err != nil {
+		return err
+	}
 
 	cleanCode := strings.ReplaceAll(config.Code, "\r\n", "\n")
 
@@ -401,73 +670,91 @@ func (e *dockerExecutor) prepareWorkspace(config domain.ExecutionConfig) (string
 
 	switch config.Type {
 	case domain.TypeTerraform:
-		if err := os.WriteFile(filepath.Join(execDir, "main.tf"), []byte(cleanCode), 0644); err != nil {
-			return "", err
+		if err := writeWorkspaceFile(execDir, "main.tf", []byte(cleanCode), 0644); err != nil {
+			return err
 		}
 		providerConfig := e.getTerraformProvider()
-		if err := os.WriteFile(filepath.Join(execDir, "provider.tf"), providerConfig, 0644); err != nil {
-			return "", err
+		if err := writeWorkspaceFile(execDir, "provider.tf", providerConfig, 0644); err != nil {
+			return err
 		}
-		if err := os.WriteFile(filepath.Join(execDir, "terraform.tfstate"), config.State, 0644); err != nil {
-			return "", err
+		if err := writeWorkspaceFile(execDir, "terraform.tfstate", config.State, 0644); err != nil {
+			return err
 		}
 	case domain.TypeAnsible:
-		if err := os.WriteFile(filepath.Join(execDir, "playbook.yml"), []byte(cleanCode), 0644); err != nil {
-			return "", err
+		if err := writeWorkspaceFile(execDir, "playbook.yml", []byte(cleanCode), 0644); err != nil {
+			return err
 		}
-		if config.ValidationCode != "" {
-			cleanValidation := strings.ReplaceAll(config.ValidationCode, "\r\n", "\n")
-			if err := os.WriteFile(filepath.Join(execDir, "validation.yml"), []byte(cleanValidation), 0644); err != nil {
-				return "", err
-			}
-		}
-		if err := os.WriteFile(filepath.Join(execDir, "inventory.ini"), []byte(ansibleLocalInventory), 0644); err != nil {
-			return "", err
+		if err := writeWorkspaceFile(execDir, "inventory.ini", []byte(ansibleLocalInventory), 0644); err != nil {
+			return err
 		}
 	case domain.TypeLinux, domain.TypeDocker:
-		if err := os.WriteFile(filepath.Join(execDir, "run.sh"), []byte(cleanCode), 0755); err != nil {
-			return "", err
+		if err := writeWorkspaceFile(execDir, "run.sh", []byte(cleanCode), 0755); err != nil {
+			return err
 		}
 	case domain.TypeK8s:
-		if err := os.WriteFile(filepath.Join(execDir, "run.sh"), []byte(cleanCode), 0755); err != nil {
-			return "", err
+		if err := writeWorkspaceFile(execDir, "run.sh", []byte(cleanCode), 0755); err != nil {
+			return err
 		}
 
 		k3sConfifPath := "/app/data/k3s/kubeconfig.yaml"
 		content, err := os.ReadFile(k3sConfifPath)
 		if err != nil {
-			return "", fmt.Errorf("falha ao ler kubeconfig do K3s (o cluster está de pé?): %w", err)
+			return fmt.Errorf("falha ao ler kubeconfig do K3s (o cluster está de pé?): %w", err)
 		}
 
 		kcStr := string(content)
 		kcStr = strings.Replace(kcStr, "127.0.0.1", "k3s", -1)
 		kcStr = strings.Replace(kcStr, "localhost", "k3s", -1)
 
-		if err := os.WriteFile(filepath.Join(execDir, "kubeconfig.yaml"), []byte(kcStr), 0644); err != nil {
-			return "", err
-		}
-
-		if config.ValidationCode != "" {
-			cleanValidation := strings.ReplaceAll(config.ValidationCode, "\r\n", "\n")
-			if err := os.WriteFile(filepath.Join(execDir, "validation.sh"), []byte(cleanValidation), 0755); err != nil {
-				return "", err
-			}
+		if err := writeWorkspaceFile(execDir, "kubeconfig.yaml", []byte(kcStr), 0644); err != nil {
+			return err
 		}
 
 	case domain.TypeGithubActions:
-		workflowDir := filepath.Join(execDir, ".github", "workflows")
-		if err := os.MkdirAll(workflowDir, 0755); err != nil {
-			return "", fmt.Errorf("falha ao criar diretório de workflows: %w", err)
-		}
-
-		if err := os.WriteFile(filepath.Join(workflowDir, "main.yml"), []byte(cleanCode), 0644); err != nil {
-			return "", err
+		if err := writeWorkspaceFile(execDir, ".github/workflows/main.yml", []byte(cleanCode), 0644); err != nil {
+			return fmt.Errorf("falha ao escrever o workflow: %w", err)
 		}
 	default:
-		os.WriteFile(filepath.Join(execDir, "run.sh"), []byte(cleanCode), 0755)
+		if err := writeWorkspaceFile(execDir, "run.sh", []byte(cleanCode), 0755); err != nil {
+			return err
+		}
 	}
 
-	return execDir, nil
+	return writeValidationFiles(execDir, config)
+}
+
+// writeValidationFiles escreve o código de validação do lab no execDir, sem
+// seguir links (ver writeWorkspaceFile). O Ansible usa validation.yml; os
+// demais tipos validam com um script shell (o Terraform aceita também
+// asserções JSON, avaliadas pelo executor).
+func writeValidationFiles(execDir string, config domain.ExecutionConfig) error {
+	if config.ValidationCode == "" || isTerraformAssertions(config) {
+		return nil
+	}
+
+	cleanValidation := strings.ReplaceAll(config.ValidationCode, "\r\n", "\n")
+	if config.Type == domain.TypeAnsible {
+		return writeWorkspaceFile(execDir, "validation.yml", []byte(cleanValidation), 0644)
+	}
+	return writeWorkspaceFile(execDir, "validation.sh", []byte(cleanValidation), 0755)
+}
+
+// writeWorkspaceFiles materializa a árvore de ficheiros do workspace no execDir.
+func writeWorkspaceFiles(execDir string, files map[string]string) error {
+	for p, content := range files {
+		rel, err := domain.CleanFilePath(p)
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		if strings.HasSuffix(rel, ".sh") {
+			mode = 0755
+		}
+		if err := writeWorkspaceFile(execDir, filepath.FromSlash(rel), []byte(strings.ReplaceAll(content, "\r\n", "\n")), mode); err != nil {
+			return fmt.Errorf("falha ao escrever %s: %w", rel, err)
+		}
+	}
+	return nil
 }
 
 func (e *dockerExecutor) readFinalState(execDir string, config domain.ExecutionConfig) ([]byte, error) {
@@ -475,14 +762,11 @@ func (e *dockerExecutor) readFinalState(execDir string, config domain.ExecutionC
 		return nil, nil // Ansible não tem estado
 	}
 
-	statePath := filepath.Join(execDir, "terraform.tfstate")
-
-	if _, err := os.Stat(statePath); os.IsNotExist(err) {
-		log.Printf("AVISO [Executor]: Arquivo .tfstate não encontrado após execução: %s", statePath)
+	data, err := readWorkspaceFile(execDir, "terraform.tfstate")
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("AVISO [Executor]: Arquivo .tfstate não encontrado após execução: %s", filepath.Join(execDir, "terraform.tfstate"))
 		return nil, nil
 	}
-
-	data, err := os.ReadFile(statePath)
 	if err != nil {
 		return nil, fmt.Errorf("falha ao ler arquivo .tfstate final: %w", err)
 	}
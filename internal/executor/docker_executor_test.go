@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"lab-devops/internal/domain"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestApplyResourceLimits(t *testing.T) {
+	yes := true
+	pids := int64(64)
+
+	tests := []struct {
+		name   string
+		limits domain.ResourceLimits
+		want   container.HostConfig
+	}{
+		{"sem limites", domain.ResourceLimits{}, container.HostConfig{}},
+		{"cpu, memória e processos", domain.ResourceLimits{CPUs: 0.5, MemoryMB: 256, PidsLimit: 64},
+			container.HostConfig{Resources: container.Resources{NanoCPUs: 5e8, Memory: 256 << 20, MemorySwap: 256 << 20, PidsLimit: &pids}}},
+		{"rootfs só de leitura", domain.ResourceLimits{ReadOnlyRootfs: &yes, DropCapabilities: []string{"NET_RAW"}},
+			container.HostConfig{ReadonlyRootfs: true, CapDrop: []string{"NET_RAW"},
+				Tmpfs: map[string]string{"/tmp": "rw,exec,size=512m", "/root": "rw,size=64m"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got container.HostConfig
+			applyResourceLimits(&got, tt.limits)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyResourceLimits() = %+v, esperava %+v", got, tt.want)
+			}
+		})
+	}
+}
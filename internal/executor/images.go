@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// imageForType devolve a imagem do catálogo e os mounts extra de cada tipo de lab.
+func (e *dockerExecutor) imageForType(execType domain.ExecutionType) (string, []mount.Mount, error) {
+	e.imagesMu.RLock()
+	img, ok := e.images[execType]
+	e.imagesMu.RUnlock()
+	if !ok {
+		return "", nil, fmt.Errorf("tipo não suportado: %s", execType)
+	}
+
+	switch execType {
+	case domain.TypeDocker, domain.TypeGithubActions:
+		dockerSock := mount.Mount{Type: mount.TypeBind, Source: "/var/run/docker.sock", Target: "/var/run/docker.sock"}
+		return img, []mount.Mount{dockerSock}, nil
+	}
+	return img, nil, nil
+}
+
+// UseImage define a imagem usada para o tipo de lab (implementa
+// service.ImageManager). Os containers do pool com a imagem antiga são
+// descartados.
+func (e *dockerExecutor) UseImage(execType domain.ExecutionType, reference string) {
+	e.imagesMu.Lock()
+	changed := e.images[execType] != reference
+	e.images[execType] = reference
+	e.imagesMu.Unlock()
+
+	if changed && e.pool != nil && e.pool.serves(execType) {
+		e.pool.recycle(execType)
+	}
+}
+
+// PullImage baixa a imagem, reportando o progresso agregado de todas as
+// camadas, e devolve o seu digest.
+func (e *dockerExecutor) PullImage(ctx context.Context, reference string, onProgress func(service.ImagePullProgress)) (string, error) {
+	reader, err := e.cli.ImagePull(ctx, reference, image.PullOptions{})
+	if err != nil {
+		return "", fmt.Errorf("falha ao baixar imagem %s: %w", reference, err)
+	}
+	defer reader.Close()
+
+	type layer struct{ current, total int64 }
+	layers := make(map[string]layer)
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("falha ao ler progresso do pull de %s: %w", reference, err)
+		}
+		if msg.Error != nil {
+			return "", fmt.Errorf("falha ao baixar imagem %s: %w", reference, msg.Error)
+		}
+
+		if msg.ID != "" && msg.Progress != nil && msg.Progress.Total > 0 {
+			layers[msg.ID] = layer{current: msg.Progress.Current, total: msg.Progress.Total}
+		}
+		if onProgress == nil {
+			continue
+		}
+
+		progress := service.ImagePullProgress{Status: msg.Status}
+		for _, l := range layers {
+			progress.Current += l.current
+			progress.Total += l.total
+		}
+		onProgress(progress)
+	}
+
+	digest, present, err := e.ImageDigest(ctx, reference)
+	if err != nil {
+		return "", err
+	}
+	if !present || digest == "" {
+		return "", fmt.Errorf("digest da imagem %s não disponível após o pull", reference)
+	}
+	return digest, nil
+}
+
+// ImageDigest devolve o digest do registo para a imagem local.
+func (e *dockerExecutor) ImageDigest(ctx context.Context, reference string) (string, bool, error) {
+	info, err := e.cli.ImageInspect(ctx, reference)
+	if client.IsErrNotFound(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("falha ao inspecionar imagem %s: %w", reference, err)
+	}
+
+	name := repositoryName(reference)
+	for _, repoDigest := range info.RepoDigests {
+		repo, digest, ok := strings.Cut(repoDigest, "@")
+		if ok && repositoryName(repo) == name {
+			return digest, true, nil
+		}
+	}
+	return "", true, nil
+}
+
+// repositoryName normaliza uma referência para o nome do repositório, sem
+// tag nem digest (ex: "docker.io/library/alpine:latest" → "alpine").
+func repositoryName(reference string) string {
+	name, _, _ := strings.Cut(reference, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "docker.io/")
+	return strings.TrimPrefix(name, "library/")
+}
@@ -0,0 +1,329 @@
+package executor
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// poolLabel identifica os containers criados pelo pool, para que possam ser
+// removidos caso a API termine sem os destruir.
+const poolLabel = "lab-devops.pool"
+
+// labContainer é o container usado por uma execução. Containers do pool não
+// têm o workspace montado: os ficheiros são copiados para um volume anónimo.
+type labContainer struct {
+	id       string
+	execType domain.ExecutionType
+	pooled   bool
+}
+
+// idleContainer é um container do pool à espera de execução, criado com image.
+type idleContainer struct {
+	id    string
+	image string
+}
+
+// containerPool mantém containers pré-iniciados por ExecutionType, eliminando
+// o custo de criação (e de um eventual pull) no início de cada execução. Os
+// containers são de uso único: são destruídos após a execução e o pool é
+// reabastecido em segundo plano.
+type containerPool struct {
+	exec  *dockerExecutor
+	size  int
+	types []domain.ExecutionType
+
+	mu      sync.Mutex
+	idle    map[domain.ExecutionType][]idleContainer
+	pending map[domain.ExecutionType]int
+	hits    map[domain.ExecutionType]int64
+	misses  map[domain.ExecutionType]int64
+}
+
+func newContainerPool(exec *dockerExecutor, size int, types []domain.ExecutionType) *containerPool {
+	return &containerPool{
+		exec:    exec,
+		size:    size,
+		types:   types,
+		idle:    make(map[domain.ExecutionType][]idleContainer),
+		pending: make(map[domain.ExecutionType]int),
+		hits:    make(map[domain.ExecutionType]int64),
+		misses:  make(map[domain.ExecutionType]int64),
+	}
+}
+
+// start remove containers órfãos de execuções anteriores da API e enche o pool.
+func (p *containerPool) start() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stale, err := p.exec.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", poolLabel)),
+	})
+	if err != nil {
+		log.Printf("AVISO [Pool]: Falha ao listar containers antigos do pool: %v", err)
+	}
+	for _, c := range stale {
+		p.exec.stopContainer(ctx, c.ID)
+	}
+
+	for _, t := range p.types {
+		p.replenish(t)
+	}
+}
+
+func (p *containerPool) serves(execType domain.ExecutionType) bool {
+	for _, t := range p.types {
+		if t == execType {
+			return true
+		}
+	}
+	return false
+}
+
+// eligible indica se a execução pode usar um container do pool. Os containers
+// do pool são criados com a imagem e os limites padrão, por isso labs com
+// política ou imagem própria seguem pelo caminho normal.
+func (p *containerPool) eligible(config domain.ExecutionConfig) bool {
+	return p.serves(config.Type) && config.Limits.IsZero() && config.Image == nil
+}
+
+// take retira um container pronto do pool, se houver. Containers criados
+// com uma imagem que já não é a do catálogo são descartados.
+func (p *containerPool) take(execType domain.ExecutionType) (string, bool) {
+	current, _, _ := p.exec.imageForType(execType)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for idle := p.idle[execType]; len(idle) > 0; idle = p.idle[execType] {
+		c := idle[len(idle)-1]
+		p.idle[execType] = idle[:len(idle)-1]
+		if c.image != current {
+			go p.exec.stopContainer(context.Background(), c.id)
+			continue
+		}
+		p.hits[execType]++
+		return c.id, true
+	}
+	p.misses[execType]++
+	return "", false
+}
+
+// recycle substitui os containers parados do tipo, após mudança de imagem.
+func (p *containerPool) recycle(execType domain.ExecutionType) {
+	p.mu.Lock()
+	stale := p.idle[execType]
+	p.idle[execType] = nil
+	p.mu.Unlock()
+
+	for _, c := range stale {
+		go p.exec.stopContainer(context.Background(), c.id)
+	}
+	p.replenish(execType)
+}
+
+// replenish cria em segundo plano os containers em falta para o tipo.
+func (p *containerPool) replenish(execType domain.ExecutionType) {
+	p.mu.Lock()
+	missing := p.size - len(p.idle[execType]) - p.pending[execType]
+	if missing > 0 {
+		p.pending[execType] += missing
+	}
+	p.mu.Unlock()
+
+	for i := 0; i < missing; i++ {
+		go func() {
+			img, _, _ := p.exec.imageForType(execType)
+			id, err := p.create(execType)
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.pending[execType]--
+			if err != nil {
+				log.Printf("AVISO [Pool]: Falha ao criar container %s para o pool: %v", execType, err)
+				return
+			}
+			p.idle[execType] = append(p.idle[execType], idleContainer{id: id, image: img})
+		}()
+	}
+}
+
+func (p *containerPool) create(execType domain.ExecutionType) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	workspaceMount := mount.Mount{Type: mount.TypeVolume, Target: "/workspace"}
+	labels := map[string]string{poolLabel: string(execType)}
+	return p.exec.runLabContainer(ctx, execType, nil, workspaceMount, p.exec.defaultLimits, labels)
+}
+
+func (p *containerPool) stats() []service.PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]service.PoolStats, 0, len(p.types))
+	for _, t := range p.types {
+		img, _, _ := p.exec.imageForType(t)
+		stats = append(stats, service.PoolStats{
+			Type:     string(t),
+			Image:    img,
+			Idle:     len(p.idle[t]),
+			Starting: p.pending[t],
+			Target:   p.size,
+			Hits:     p.hits[t],
+			Misses:   p.misses[t],
+		})
+	}
+	return stats
+}
+
+// PoolStats expõe o estado do pool (implementa service.PoolStatsProvider).
+func (e *dockerExecutor) PoolStats() []service.PoolStats {
+	if e.pool == nil {
+		return nil
+	}
+	return e.pool.stats()
+}
+
+// acquireContainer obtém o container da execução: um container pré-aquecido
+// do pool (com os ficheiros do execDir copiados) ou um novo container com o
+// workspace montado via bind mount.
+func (e *dockerExecutor) acquireContainer(ctx context.Context, config domain.ExecutionConfig, limits domain.ResourceLimits, execDir string) (labContainer, error) {
+	if e.pool != nil && e.pool.eligible(config) {
+		if id, ok := e.pool.take(config.Type); ok {
+			e.pool.replenish(config.Type)
+			err := e.copyToContainer(ctx, id, execDir)
+			if err == nil {
+				return labContainer{id: id, execType: config.Type, pooled: true}, nil
+			}
+			log.Printf("AVISO [Pool]: Container %s inutilizável, a criar um novo: %v", id[:12], err)
+			e.stopContainer(context.Background(), id)
+		}
+	}
+
+	// Aguardar sincronização do filesystem (Docker Desktop WSL2)
+	// O prepareWorkspace escreve ficheiros via bind mount, mas o Docker daemon
+	// pode não ver os ficheiros imediatamente devido ao delay de sync do WSL2.
+	time.Sleep(1 * time.Second)
+
+	id, err := e.startContainer(ctx, config, limits)
+	if err != nil {
+		return labContainer{}, err
+	}
+
+	// Pequeno sleep para garantir que container está pronto (workaround para race conditions)
+	time.Sleep(500 * time.Millisecond)
+
+	return labContainer{id: id, execType: config.Type}, nil
+}
+
+func (e *dockerExecutor) releaseContainer(lc labContainer) {
+	e.stopContainer(context.Background(), lc.id)
+}
+
+// collectFinalState lê o estado final da execução. Nos containers do pool o
+// .tfstate vive no volume do container e é copiado de volta para o execDir.
+func (e *dockerExecutor) collectFinalState(lc labContainer, execDir string, config domain.ExecutionConfig) ([]byte, error) {
+	if lc.pooled && config.Type == domain.TypeTerraform {
+		if err := e.copyFileFromContainer(lc.id, "/workspace/terraform.tfstate", execDir, "terraform.tfstate"); err != nil {
+			log.Printf("AVISO [Executor]: Falha ao copiar .tfstate do container %s: %v", lc.id[:12], err)
+		}
+	}
+	return e.readFinalState(execDir, config)
+}
+
+// copyToContainer envia o conteúdo de srcDir para /workspace no container.
+func (e *dockerExecutor) copyToContainer(ctx context.Context, containerID, srcDir string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, srcDir))
+	}()
+	defer pr.Close()
+
+	return e.cli.CopyToContainer(ctx, containerID, "/workspace", pr, container.CopyToContainerOptions{})
+}
+
+func writeTar(w io.Writer, srcDir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(srcDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// copyFileFromContainer copia um único ficheiro do container para name
+// dentro de execDir.
+func (e *dockerExecutor) copyFileFromContainer(containerID, src, execDir, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	reader, _, err := e.cli.CopyFromContainer(ctx, containerID, src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s não encontrado no container", src)
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Name != path.Base(src) {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		return writeWorkspaceFile(execDir, name, data, 0644)
+	}
+}
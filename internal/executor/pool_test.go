@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"testing"
+
+	"lab-devops/internal/domain"
+)
+
+func TestPoolEligible(t *testing.T) {
+	pool := newContainerPool(&dockerExecutor{}, 2, []domain.ExecutionType{domain.TypeTerraform, domain.TypeLinux})
+
+	tests := []struct {
+		name   string
+		config domain.ExecutionConfig
+		want   bool
+	}{
+		{"tipo do pool", domain.ExecutionConfig{Type: domain.TypeTerraform}, true},
+		{"tipo fora do pool", domain.ExecutionConfig{Type: domain.TypeAnsible}, false},
+		{"limites próprios", domain.ExecutionConfig{Type: domain.TypeLinux, Limits: domain.ResourceLimits{MemoryMB: 128}}, false},
+		{"imagem própria", domain.ExecutionConfig{Type: domain.TypeLinux, Image: &domain.LabImage{Reference: "alpine:3.20"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pool.eligible(tt.config); got != tt.want {
+				t.Errorf("eligible() = %v, esperava %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolTake(t *testing.T) {
+	e := &dockerExecutor{images: map[domain.ExecutionType]string{domain.TypeLinux: "alpine:latest"}}
+	pool := newContainerPool(e, 1, []domain.ExecutionType{domain.TypeLinux})
+	pool.idle[domain.TypeLinux] = []idleContainer{{id: "c1", image: "alpine:latest"}}
+
+	if id, ok := pool.take(domain.TypeLinux); !ok || id != "c1" {
+		t.Fatalf("take() = %q, %v, esperava o container parado", id, ok)
+	}
+	if _, ok := pool.take(domain.TypeLinux); ok {
+		t.Fatal("take() com o pool vazio devolveu um container")
+	}
+	if pool.hits[domain.TypeLinux] != 1 || pool.misses[domain.TypeLinux] != 1 {
+		t.Fatalf("hits = %d, misses = %d, esperava 1 e 1", pool.hits[domain.TypeLinux], pool.misses[domain.TypeLinux])
+	}
+}
@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Nas sessões e terminais o execDir está montado, com escrita, num container
+// em que o utilizador tem um shell. Tudo o que a API lá escreve ou lê passa
+// por estas funções, que nunca seguem links: um main.tf ou terraform.tfstate
+// trocado por um link (ex: para /app/data/lab.db ou /proc/self/environ) não
+// pode levar a API a escrever ou ler fora do workspace.
+
+// writeWorkspaceFile escreve data em rel dentro de execDir, criando os
+// diretórios em falta. Recusa links e ficheiros que não sejam regulares em
+// qualquer ponto do caminho.
+func writeWorkspaceFile(execDir, rel string, data []byte, mode os.FileMode) error {
+	root, err := os.OpenRoot(execDir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	if err := mkdirNoFollow(root, filepath.Dir(rel)); err != nil {
+		return err
+	}
+	if err := checkRegular(root, rel); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	f, err := root.OpenFile(rel, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, mode)
+	if err != nil {
+		return fmt.Errorf("falha ao abrir %s no workspace: %w", rel, err)
+	}
+	defer f.Close()
+	if err := checkOpenedRegular(f, rel); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readWorkspaceFile lê rel dentro de execDir, com as mesmas regras do
+// writeWorkspaceFile. Um ficheiro em falta devolve um erro fs.ErrNotExist.
+func readWorkspaceFile(execDir, rel string) ([]byte, error) {
+	root, err := os.OpenRoot(execDir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	if err := checkPathNoLinks(root, filepath.Dir(rel)); err != nil {
+		return nil, err
+	}
+	if err := checkRegular(root, rel); err != nil {
+		return nil, err
+	}
+
+	f, err := root.OpenFile(rel, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao abrir %s no workspace: %w", rel, err)
+	}
+	defer f.Close()
+	if err := checkOpenedRegular(f, rel); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+// mkdirNoFollow cria os diretórios de dir, um a um, recusando links.
+func mkdirNoFollow(root *os.Root, dir string) error {
+	current := "."
+	for _, part := range splitPath(dir) {
+		current = filepath.Join(current, part)
+		info, err := root.Lstat(current)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err := root.Mkdir(current, 0755); err != nil && !errors.Is(err, fs.ErrExist) {
+				return fmt.Errorf("falha ao criar diretório %s: %w", current, err)
+			}
+		case err != nil:
+			return err
+		case !info.IsDir():
+			return fmt.Errorf("%s no workspace não é um diretório", current)
+		}
+	}
+	return nil
+}
+
+// checkPathNoLinks confirma que todos os componentes de dir são diretórios.
+func checkPathNoLinks(root *os.Root, dir string) error {
+	current := "."
+	for _, part := range splitPath(dir) {
+		current = filepath.Join(current, part)
+		info, err := root.Lstat(current)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s no workspace não é um diretório", current)
+		}
+	}
+	return nil
+}
+
+func checkRegular(root *os.Root, rel string) error {
+	info, err := root.Lstat(rel)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s no workspace não é um ficheiro regular", rel)
+	}
+	return nil
+}
+
+// checkOpenedRegular repete a verificação no ficheiro aberto: entre o Lstat
+// e a abertura o utilizador pode ter trocado o ficheiro (ex: por um FIFO).
+func checkOpenedRegular(f *os.File, rel string) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s no workspace não é um ficheiro regular", rel)
+	}
+	return nil
+}
+
+func splitPath(dir string) []string {
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Clean(dir)), "/") {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lab-devops/internal/domain"
+)
+
+func TestWriteValidationFilesDoesNotFollowLinks(t *testing.T) {
+	execDir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "lab.db")
+	if err := os.WriteFile(outside, []byte("dados"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(execDir, "validation.sh")); err != nil {
+		t.Fatal(err)
+	}
+
+	config := domain.ExecutionConfig{Type: domain.TypeLinux, ValidationCode: "exit 0"}
+	if err := writeValidationFiles(execDir, config); err == nil {
+		t.Fatal("esperava erro ao escrever sobre um link")
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "dados" {
+		t.Fatalf("ficheiro fora do workspace alterado: %q", data)
+	}
+}
+
+func TestWriteWorkspaceFile(t *testing.T) {
+	execDir := t.TempDir()
+	outsideDir := t.TempDir()
+	if err := os.Symlink(outsideDir, filepath.Join(execDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(execDir, "scripts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		rel     string
+		wantErr bool
+	}{
+		{"ficheiro novo", "main.tf", false},
+		{"subdiretório novo", ".github/workflows/main.yml", false},
+		{"diretório com link", "link/main.tf", true},
+		{"destino é diretório", "scripts", true},
+		{"fora do workspace", "../main.tf", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := writeWorkspaceFile(execDir, tt.rel, []byte("x"), 0644)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeWorkspaceFile(%q) erro = %v, esperava erro = %v", tt.rel, err, tt.wantErr)
+			}
+		})
+	}
+	if entries, _ := os.ReadDir(outsideDir); len(entries) != 0 {
+		t.Fatalf("escreveu fora do workspace: %v", entries)
+	}
+}
+
+func TestSessionWorkspaceDoesNotFollowLinks(t *testing.T) {
+	e := &dockerExecutor{tempDirRoot: t.TempDir()}
+	execDir := t.TempDir()
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "environ")
+	if err := os.WriteFile(secret, []byte("ADMIN_TOKEN=secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db := filepath.Join(outside, "lab.db")
+	if err := os.WriteFile(db, []byte("dados"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(db, filepath.Join(execDir, "main.tf")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(execDir, "terraform.tfstate")); err != nil {
+		t.Fatal(err)
+	}
+
+	config := domain.ExecutionConfig{Type: domain.TypeTerraform, Code: `resource "null_resource" "x" {}`}
+	if err := e.writeWorkspace(execDir, config); err == nil {
+		t.Fatal("esperava erro ao escrever main.tf sobre um link")
+	}
+	if data, _ := os.ReadFile(db); string(data) != "dados" {
+		t.Fatalf("ficheiro fora do workspace alterado: %q", data)
+	}
+	if state, err := e.readFinalState(execDir, config); err == nil || len(state) != 0 {
+		t.Fatalf("readFinalState seguiu o link: estado %q, erro %v", state, err)
+	}
+
+	config.Files = map[string]string{"modules/net/main.tf": "x"}
+	if err := os.Symlink(outside, filepath.Join(execDir, "modules")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeWorkspaceFiles(execDir, config.Files); err == nil {
+		t.Fatal("esperava erro ao escrever num diretório que é um link")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "net")); !os.IsNotExist(err) {
+		t.Fatalf("criou diretório fora do workspace: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// dockerSession é um container de lab que vive entre execuções: ficheiros,
+// pacotes instalados e processos em segundo plano ficam de um passo para o
+// seguinte. O workspace é montado via bind mount, como fora do pool.
+type dockerSession struct {
+	e       *dockerExecutor
+	lc      labContainer
+	execDir string
+
+	closeOnce sync.Once
+}
+
+// StartSession prepara o workspace e inicia o container da sessão.
+func (e *dockerExecutor) StartSession(ctx context.Context, config domain.ExecutionConfig) (service.Session, error) {
+	limits := config.Limits.WithDefaults(e.defaultLimits)
+
+	execDir, err := e.prepareWorkspace(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mesma espera do acquireContainer: o bind mount pode demorar a
+	// sincronizar no Docker Desktop (WSL2)
+	time.Sleep(1 * time.Second)
+
+	id, err := e.startContainer(ctx, config, limits)
+	if err != nil {
+		os.RemoveAll(execDir)
+		return nil, fmt.Errorf("falha ao iniciar container: %w", err)
+	}
+
+	log.Printf("INFO [Executor]: Sessão iniciada no container %s (workspace %s)", id[:12], config.WorkspaceID)
+	return &dockerSession{e: e, lc: labContainer{id: id, execType: config.Type}, execDir: execDir}, nil
+}
+
+// Execute corre um passo no container da sessão. Nos modos de execução o
+// código e os ficheiros do workspace são reescritos antes, sem apagar o que
+// o utilizador criou; ModeCommand e ModeValidate usam o que lá está.
+func (s *dockerSession) Execute(ctx context.Context, config domain.ExecutionConfig) (<-chan service.ExecutionResult, <-chan service.ExecutionFinalState, error) {
+	var err error
+	switch config.Mode {
+	case domain.ModeCommand:
+	case domain.ModeValidate:
+		// O utilizador pode ter alterado ou apagado os ficheiros de validação
+		err = writeValidationFiles(s.execDir, config)
+	default:
+		err = s.e.writeWorkspace(s.execDir, config)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("falha ao atualizar workspace da sessão: %w", err)
+	}
+
+	return s.e.runInContainer(ctx, s.lc, s.execDir, config)
+}
+
+// OpenShell abre um terminal no container da sessão. Fechar o terminal não
+// termina a sessão.
+func (s *dockerSession) OpenShell(ctx context.Context, config domain.ExecutionConfig) (service.Terminal, error) {
+	t := &dockerTerminal{e: s.e, lc: s.lc, execDir: s.execDir}
+	if err := t.attach(ctx, config); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Close remove o container e os ficheiros da sessão.
+func (s *dockerSession) Close() error {
+	s.closeOnce.Do(func() {
+		s.e.releaseContainer(s.lc)
+		os.RemoveAll(s.execDir)
+		log.Printf("INFO [Executor]: Sessão do container %s terminada", s.lc.id[:12])
+	})
+	return nil
+}
+
+// runInContainer corre os passos da execução num container que não é
+// removido no fim (sessões e terminais), com o timeout do lab.
+func (e *dockerExecutor) runInContainer(ctx context.Context, lc labContainer, execDir string, config domain.ExecutionConfig) (<-chan service.ExecutionResult, <-chan service.ExecutionFinalState, error) {
+	logStream := make(chan service.ExecutionResult)
+	finalState := make(chan service.ExecutionFinalState)
+
+	go func() {
+		defer close(logStream)
+		defer close(finalState)
+
+		limits := config.Limits.WithDefaults(e.defaultLimits)
+		runCtx := ctx
+		if limits.TimeoutSeconds > 0 {
+			var cancelTimeout context.CancelFunc
+			runCtx, cancelTimeout = context.WithTimeout(ctx, time.Duration(limits.TimeoutSeconds)*time.Second)
+			defer cancelTimeout()
+		}
+
+		e.runSteps(ctx, runCtx, limits, lc, execDir, config, logStream, finalState)
+	}()
+
+	return logStream, finalState, nil
+}
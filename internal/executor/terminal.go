@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// terminalShell abre o bash quando a imagem o tem, senão o sh.
+const terminalShell = "if command -v bash >/dev/null 2>&1; then exec bash -l; else exec sh -l; fi"
+
+// dockerTerminal é um shell interativo (TTY) num container do lab: um
+// container próprio, criado com o workspace montado como nas execuções, ou
+// o de uma sessão. Só o container próprio é removido no Close.
+type dockerTerminal struct {
+	e       *dockerExecutor
+	lc      labContainer
+	execDir string
+	execID  string
+	conn    types.HijackedResponse
+	owned   bool
+
+	closeOnce sync.Once
+}
+
+// OpenTerminal prepara o workspace, inicia o container do lab e abre nele
+// um shell com TTY. Nunca usa o pool: o terminal precisa do bind mount.
+func (e *dockerExecutor) OpenTerminal(ctx context.Context, config domain.ExecutionConfig) (service.Terminal, error) {
+	limits := config.Limits.WithDefaults(e.defaultLimits)
+
+	execDir, err := e.prepareWorkspace(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mesma espera do acquireContainer: o bind mount pode demorar a
+	// sincronizar no Docker Desktop (WSL2)
+	time.Sleep(1 * time.Second)
+
+	id, err := e.startContainer(ctx, config, limits)
+	if err != nil {
+		os.RemoveAll(execDir)
+		return nil, fmt.Errorf("falha ao iniciar container: %w", err)
+	}
+
+	t := &dockerTerminal{e: e, lc: labContainer{id: id, execType: config.Type}, execDir: execDir, owned: true}
+	if err := t.attach(ctx, config); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// attach abre o shell no container, com o ambiente do passo do utilizador
+// (ex.: KUBECONFIG nos labs Kubernetes).
+func (t *dockerTerminal) attach(ctx context.Context, config domain.ExecutionConfig) error {
+	_, env := t.e.getStepCommand(config, false)
+	execResp, err := t.e.cli.ContainerExecCreate(ctx, t.lc.id, container.ExecOptions{
+		Cmd:          []string{"sh", "-c", terminalShell},
+		Env:          append(env, "TERM=xterm-256color"),
+		WorkingDir:   "/workspace",
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("falha ao criar shell no container: %w", err)
+	}
+	t.execID = execResp.ID
+
+	t.conn, err = t.e.cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{Tty: true})
+	if err != nil {
+		return fmt.Errorf("falha ao ligar ao shell do container: %w", err)
+	}
+
+	log.Printf("INFO [Executor]: Terminal aberto no container %s (workspace %s)", t.lc.id[:12], config.WorkspaceID)
+	return nil
+}
+
+// Read lê o output do TTY. Com TTY o stream do Docker não é multiplexado.
+func (t *dockerTerminal) Read(p []byte) (int, error) {
+	return t.conn.Reader.Read(p)
+}
+
+// Write envia as teclas do utilizador para o shell.
+func (t *dockerTerminal) Write(p []byte) (int, error) {
+	return t.conn.Conn.Write(p)
+}
+
+func (t *dockerTerminal) Resize(cols, rows uint) error {
+	return t.e.cli.ContainerExecResize(context.Background(), t.execID, container.ResizeOptions{Width: cols, Height: rows})
+}
+
+// Execute corre a validação do lab no container do terminal, sobre o que o
+// utilizador lá deixou. config deve ser a usada para abrir o terminal.
+func (t *dockerTerminal) Execute(ctx context.Context, config domain.ExecutionConfig) (<-chan service.ExecutionResult, <-chan service.ExecutionFinalState, error) {
+	// O utilizador pode ter alterado ou apagado os ficheiros de validação
+	if err := writeValidationFiles(t.execDir, config); err != nil {
+		return nil, nil, fmt.Errorf("falha ao repor ficheiros de validação: %w", err)
+	}
+
+	config.Mode = domain.ModeValidate
+	return t.e.runInContainer(ctx, t.lc, t.execDir, config)
+}
+
+// Close fecha o shell e, se o container for do terminal, remove-o com os
+// ficheiros do workspace.
+func (t *dockerTerminal) Close() error {
+	t.closeOnce.Do(func() {
+		if t.conn.Conn != nil {
+			t.conn.Close()
+		}
+		if t.owned {
+			t.e.releaseContainer(t.lc)
+			os.RemoveAll(t.execDir)
+		}
+		log.Printf("INFO [Executor]: Terminal do container %s fechado", t.lc.id[:12])
+	})
+	return nil
+}
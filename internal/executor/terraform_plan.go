@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+// Ficheiros do modo plan no workspace: o plano binário e a sua exportação JSON.
+const (
+	terraformPlanFile     = ".lab.tfplan"
+	terraformPlanJSONFile = ".lab-plan.json"
+)
+
+// terraformPlanCommand corre o plano contra o estado gravado do workspace e
+// exporta-o em JSON para ser resumido.
+const terraformPlanCommand = "mkdir -p /tmp/plugins && rm -rf .terraform/ && terraform init -upgrade && " +
+	"terraform plan -input=false -out=" + terraformPlanFile + " && " +
+	"terraform show -json " + terraformPlanFile + " > " + terraformPlanJSONFile
+
+type terraformPlan struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Mode    string `json:"mode"`
+		Type    string `json:"type"`
+		Change  struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// finishPlan monta o estado final de uma execução em modo plan. O estado do
+// workspace não muda, por isso NewState fica vazio.
+func (e *dockerExecutor) finishPlan(lc labContainer, execDir string, config domain.ExecutionConfig, execResult domain.StepResult) service.ExecutionFinalState {
+	final := service.ExecutionFinalState{
+		WorkspaceID:     config.WorkspaceID,
+		Outcome:         domain.OutcomeSuccess,
+		ExecutionResult: execResult,
+	}
+
+	if execResult.ExitCode != 0 {
+		final.Error = fmt.Errorf("plano falhou com código %d", execResult.ExitCode)
+	} else if data, err := e.readContainerFile(lc, execDir, terraformPlanJSONFile); err != nil {
+		final.Error = fmt.Errorf("falha ao ler o plano do Terraform: %w", err)
+	} else if final.Plan, err = summarizeTerraformPlan(data); err != nil {
+		final.Error = err
+	}
+
+	if final.Error != nil {
+		final.Outcome = domain.OutcomeFailed
+	}
+	return final
+}
+
+// summarizeTerraformPlan resume o resultado de "terraform show -json" de um
+// plano. Data sources e recursos sem alterações são ignorados.
+func summarizeTerraformPlan(data []byte) (*domain.PlanSummary, error) {
+	var plan terraformPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("plano do Terraform ilegível: %w", err)
+	}
+
+	summary := &domain.PlanSummary{Changes: []domain.PlanChange{}}
+	for _, rc := range plan.ResourceChanges {
+		if rc.Mode == "data" {
+			continue
+		}
+
+		action := planAction(rc.Change.Actions)
+		switch action {
+		case domain.PlanActionCreate:
+			summary.Create++
+		case domain.PlanActionUpdate:
+			summary.Update++
+		case domain.PlanActionDelete:
+			summary.Destroy++
+		case domain.PlanActionReplace:
+			summary.Create++
+			summary.Destroy++
+		default:
+			continue
+		}
+		summary.Changes = append(summary.Changes, domain.PlanChange{Address: rc.Address, Type: rc.Type, Action: action})
+	}
+	return summary, nil
+}
+
+// planAction traduz a lista de ações do Terraform ("no-op", "create",
+// ["delete", "create"], ...) para uma única ação; "" quando nada muda.
+func planAction(actions []string) string {
+	switch len(actions) {
+	case 1:
+		switch actions[0] {
+		case "create":
+			return domain.PlanActionCreate
+		case "update":
+			return domain.PlanActionUpdate
+		case "delete":
+			return domain.PlanActionDelete
+		}
+	case 2:
+		return domain.PlanActionReplace
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"lab-devops/internal/domain"
+	"reflect"
+	"testing"
+)
+
+const samplePlan = `{
+  "resource_changes": [
+    {"address": "aws_s3_bucket.data", "mode": "managed", "type": "aws_s3_bucket", "change": {"actions": ["create"]}},
+    {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "change": {"actions": ["no-op"]}},
+    {"address": "aws_sqs_queue.q", "mode": "managed", "type": "aws_sqs_queue", "change": {"actions": ["update"]}},
+    {"address": "aws_iam_role.r", "mode": "managed", "type": "aws_iam_role", "change": {"actions": ["delete", "create"]}},
+    {"address": "module.net.aws_vpc.this", "mode": "managed", "type": "aws_vpc", "change": {"actions": ["delete"]}},
+    {"address": "data.aws_region.current", "mode": "data", "type": "aws_region", "change": {"actions": ["read"]}}
+  ]
+}`
+
+func TestSummarizeTerraformPlan(t *testing.T) {
+	summary, err := summarizeTerraformPlan([]byte(samplePlan))
+	if err != nil {
+		t.Fatalf("summarizeTerraformPlan: %v", err)
+	}
+
+	if summary.Create != 2 || summary.Update != 1 || summary.Destroy != 2 {
+		t.Errorf("contagens = %d/%d/%d, esperado 2/1/2", summary.Create, summary.Update, summary.Destroy)
+	}
+
+	want := []domain.PlanChange{
+		{Address: "aws_s3_bucket.data", Type: "aws_s3_bucket", Action: domain.PlanActionCreate},
+		{Address: "aws_sqs_queue.q", Type: "aws_sqs_queue", Action: domain.PlanActionUpdate},
+		{Address: "aws_iam_role.r", Type: "aws_iam_role", Action: domain.PlanActionReplace},
+		{Address: "module.net.aws_vpc.this", Type: "aws_vpc", Action: domain.PlanActionDelete},
+	}
+	if !reflect.DeepEqual(summary.Changes, want) {
+		t.Errorf("alterações = %+v, esperado %+v", summary.Changes, want)
+	}
+}
+
+func TestSummarizeTerraformPlanEmpty(t *testing.T) {
+	summary, err := summarizeTerraformPlan([]byte(`{"format_version": "1.2"}`))
+	if err != nil {
+		t.Fatalf("summarizeTerraformPlan: %v", err)
+	}
+	if summary.HasChanges() {
+		t.Errorf("plano sem resource_changes não devia ter alterações: %+v", summary)
+	}
+
+	if _, err := summarizeTerraformPlan([]byte("não é json")); err == nil {
+		t.Error("esperava erro para JSON inválido")
+	}
+}
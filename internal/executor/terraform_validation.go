@@ -0,0 +1,233 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// terraformShowFile recebe o resultado de "terraform show -json" no workspace.
+const terraformShowFile = ".lab-show.json"
+
+// terraformAssertion é uma verificação sobre o estado do Terraform, escrita
+// pelo autor do lab como alternativa a um script de validação. Exemplos:
+//
+//	{"resource": "aws_s3_bucket.data"}                              → o recurso existe
+//	{"resource": "aws_s3_bucket.data", "attribute": "tags.Env", "equals": "dev"}
+//	{"type": "aws_sqs_queue", "count": 2}                          → número de recursos do tipo
+type terraformAssertion struct {
+	Resource  string          `json:"resource,omitempty"`
+	Type      string          `json:"type,omitempty"`
+	Count     *int            `json:"count,omitempty"`
+	Attribute string          `json:"attribute,omitempty"`
+	Equals    json.RawMessage `json:"equals,omitempty"`
+	Message   string          `json:"message,omitempty"`
+}
+
+type terraformShow struct {
+	Values *struct {
+		RootModule terraformModule `json:"root_module"`
+	} `json:"values"`
+}
+
+type terraformModule struct {
+	Resources    []terraformResource `json:"resources"`
+	ChildModules []terraformModule   `json:"child_modules"`
+}
+
+type terraformResource struct {
+	Address string         `json:"address"`
+	Mode    string         `json:"mode"`
+	Type    string         `json:"type"`
+	Values  map[string]any `json:"values"`
+}
+
+// isTerraformAssertions indica se a validação do lab é uma lista de asserções
+// JSON em vez de um script shell.
+func isTerraformAssertions(config domain.ExecutionConfig) bool {
+	return config.Type == domain.TypeTerraform && strings.HasPrefix(strings.TrimSpace(config.ValidationCode), "[")
+}
+
+func parseTerraformAssertions(code string) ([]terraformAssertion, error) {
+	var assertions []terraformAssertion
+	if err := json.Unmarshal([]byte(code), &assertions); err != nil {
+		return nil, fmt.Errorf("asserções de validação inválidas: %w", err)
+	}
+	if len(assertions) == 0 {
+		return nil, fmt.Errorf("a validação não contém asserções")
+	}
+	for i, a := range assertions {
+		if a.Resource == "" && a.Type == "" {
+			return nil, fmt.Errorf("asserção %d: indique \"resource\" ou \"type\"", i+1)
+		}
+	}
+	return assertions, nil
+}
+
+// runTerraformAssertions exporta o estado com "terraform show -json" e avalia
+// as asserções do lab, publicando uma linha por asserção.
+func (e *dockerExecutor) runTerraformAssertions(ctx context.Context, lc labContainer, execDir string, code string, logStream chan<- service.ExecutionResult) domain.StepResult {
+	assertions, err := parseTerraformAssertions(code)
+	if err != nil {
+		line := "❌ " + err.Error()
+		logStream <- service.ExecutionResult{Line: line}
+		return domain.StepResult{ExitCode: 1, Output: line + "\n", Error: err}
+	}
+
+	cmd := []string{"sh", "-c", "terraform show -json > " + terraformShowFile}
+	res := e.execStep(ctx, lc.id, cmd, nil, "/workspace", logStream)
+	if res.ExitCode != 0 || res.Error != nil {
+		return res
+	}
+
+	show, err := e.readContainerFile(lc, execDir, terraformShowFile)
+	if err != nil {
+		return domain.StepResult{ExitCode: 1, Output: "❌ Falha ao ler o estado do Terraform\n", Error: err}
+	}
+
+	lines, passed := evaluateTerraformAssertions(show, assertions)
+	for _, line := range lines {
+		logStream <- service.ExecutionResult{Line: line}
+	}
+
+	exitCode := 0
+	if !passed {
+		exitCode = 1
+	}
+	return domain.StepResult{ExitCode: exitCode, Output: strings.Join(lines, "\n") + "\n"}
+}
+
+// readContainerFile lê um ficheiro do workspace da execução. Nos containers
+// do pool o workspace vive num volume e o ficheiro é copiado para o execDir.
+func (e *dockerExecutor) readContainerFile(lc labContainer, execDir, name string) ([]byte, error) {
+	if lc.pooled {
+		if err := e.copyFileFromContainer(lc.id, "/workspace/"+name, execDir, name); err != nil {
+			return nil, err
+		}
+	}
+	return readWorkspaceFile(execDir, name)
+}
+
+// evaluateTerraformAssertions avalia as asserções sobre o resultado de
+// "terraform show -json", devolvendo uma linha por asserção.
+func evaluateTerraformAssertions(show []byte, assertions []terraformAssertion) ([]string, bool) {
+	var parsed terraformShow
+	if err := json.Unmarshal(show, &parsed); err != nil {
+		return []string{"❌ Estado do Terraform ilegível: " + err.Error()}, false
+	}
+
+	var resources []terraformResource
+	if parsed.Values != nil {
+		resources = collectResources(parsed.Values.RootModule)
+	}
+
+	passed := true
+	lines := make([]string, 0, len(assertions))
+	for _, a := range assertions {
+		ok, detail := evaluateAssertion(resources, a)
+		if a.Message != "" {
+			detail = a.Message
+		}
+		if ok {
+			lines = append(lines, "✅ "+detail)
+		} else {
+			lines = append(lines, "❌ "+detail)
+			passed = false
+		}
+	}
+	return lines, passed
+}
+
+func collectResources(m terraformModule) []terraformResource {
+	var resources []terraformResource
+	for _, r := range m.Resources {
+		if r.Mode == "" || r.Mode == "managed" {
+			resources = append(resources, r)
+		}
+	}
+	for _, child := range m.ChildModules {
+		resources = append(resources, collectResources(child)...)
+	}
+	return resources
+}
+
+func evaluateAssertion(resources []terraformResource, a terraformAssertion) (bool, string) {
+	if a.Resource == "" {
+		found := 0
+		for _, r := range resources {
+			if r.Type == a.Type {
+				found++
+			}
+		}
+		if a.Count == nil {
+			return found > 0, fmt.Sprintf("recursos do tipo %s: %d", a.Type, found)
+		}
+		if found != *a.Count {
+			return false, fmt.Sprintf("esperado(s) %d recurso(s) do tipo %s, encontrado(s) %d", *a.Count, a.Type, found)
+		}
+		return true, fmt.Sprintf("%d recurso(s) do tipo %s", found, a.Type)
+	}
+
+	var resource *terraformResource
+	for i := range resources {
+		if resources[i].Address == a.Resource {
+			resource = &resources[i]
+			break
+		}
+	}
+	if resource == nil {
+		return false, fmt.Sprintf("recurso %s não encontrado", a.Resource)
+	}
+	if a.Attribute == "" {
+		return true, fmt.Sprintf("recurso %s existe", a.Resource)
+	}
+
+	name := a.Resource + "." + a.Attribute
+	got, ok := lookupAttribute(resource.Values, a.Attribute)
+	if !ok || got == nil {
+		return false, fmt.Sprintf("%s não está definido", name)
+	}
+	if len(a.Equals) == 0 {
+		return true, fmt.Sprintf("%s está definido", name)
+	}
+
+	var want any
+	if err := json.Unmarshal(a.Equals, &want); err != nil {
+		return false, fmt.Sprintf("%s: valor esperado inválido: %v", name, err)
+	}
+	gotJSON, _ := json.Marshal(got)
+	if !reflect.DeepEqual(got, want) {
+		return false, fmt.Sprintf("%s: esperado %s, obtido %s", name, a.Equals, gotJSON)
+	}
+	return true, fmt.Sprintf("%s = %s", name, gotJSON)
+}
+
+// lookupAttribute percorre um caminho com pontos (ex: "tags.Env",
+// "ingress.0.from_port") nos valores de um recurso.
+func lookupAttribute(values map[string]any, attrPath string) (any, bool) {
+	var current any = values
+	for _, part := range strings.Split(attrPath, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			next, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,70 @@
+package executor
+
+import "testing"
+
+const sampleShow = `{
+  "values": {
+    "root_module": {
+      "resources": [
+        {"address": "aws_s3_bucket.data", "mode": "managed", "type": "aws_s3_bucket",
+         "values": {"bucket": "lab-data", "tags": {"Env": "dev"}, "versioning": [{"enabled": true}]}},
+        {"address": "data.aws_region.current", "mode": "data", "type": "aws_region", "values": {}}
+      ],
+      "child_modules": [
+        {"resources": [
+          {"address": "module.queues.aws_sqs_queue.q[0]", "mode": "managed", "type": "aws_sqs_queue", "values": {}},
+          {"address": "module.queues.aws_sqs_queue.q[1]", "mode": "managed", "type": "aws_sqs_queue", "values": {}}
+        ]}
+      ]
+    }
+  }
+}`
+
+func TestEvaluateTerraformAssertions(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"recurso existe", `[{"resource": "aws_s3_bucket.data"}]`, true},
+		{"recurso em falta", `[{"resource": "aws_s3_bucket.logs"}]`, false},
+		{"atributo igual", `[{"resource": "aws_s3_bucket.data", "attribute": "tags.Env", "equals": "dev"}]`, true},
+		{"atributo diferente", `[{"resource": "aws_s3_bucket.data", "attribute": "bucket", "equals": "outro"}]`, false},
+		{"atributo em lista", `[{"resource": "aws_s3_bucket.data", "attribute": "versioning.0.enabled", "equals": true}]`, true},
+		{"atributo indefinido", `[{"resource": "aws_s3_bucket.data", "attribute": "acl"}]`, false},
+		{"contagem em módulos", `[{"type": "aws_sqs_queue", "count": 2}]`, true},
+		{"data sources ignorados", `[{"type": "aws_region"}]`, false},
+		{"uma falha reprova tudo", `[{"resource": "aws_s3_bucket.data"}, {"type": "aws_sqs_queue", "count": 3}]`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertions, err := parseTerraformAssertions(tt.code)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			lines, got := evaluateTerraformAssertions([]byte(sampleShow), assertions)
+			if got != tt.want {
+				t.Errorf("resultado = %v, esperado %v (%v)", got, tt.want, lines)
+			}
+			if len(lines) != len(assertions) {
+				t.Errorf("esperada uma linha por asserção, obtidas %d", len(lines))
+			}
+		})
+	}
+}
+
+func TestEvaluateTerraformAssertionsEmptyState(t *testing.T) {
+	assertions, _ := parseTerraformAssertions(`[{"type": "aws_s3_bucket", "count": 0}]`)
+	if _, ok := evaluateTerraformAssertions([]byte(`{"format_version": "1.0"}`), assertions); !ok {
+		t.Error("estado vazio deveria satisfazer count 0")
+	}
+}
+
+func TestParseTerraformAssertionsRejectsInvalid(t *testing.T) {
+	for _, code := range []string{`[]`, `[{"attribute": "x"}]`, `[{`} {
+		if _, err := parseTerraformAssertions(code); err == nil {
+			t.Errorf("esperado erro para %s", code)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package gitsource lê os lab packs de um repositório Git local (clone
+// normal ou bare) com o binário git.
+package gitsource
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"lab-devops/internal/labpack"
+	"lab-devops/internal/service"
+	"os/exec"
+	"strings"
+)
+
+// Repository é um repositório Git com lab packs. Subdir é a pasta do
+// repositório onde estão os packs (vazio para a raiz).
+type Repository struct {
+	path   string
+	subdir string
+}
+
+func New(path, subdir string) *Repository {
+	return &Repository{path: path, subdir: strings.Trim(subdir, "/")}
+}
+
+// String identifica o repositório nos registos de sincronização.
+func (r *Repository) String() string {
+	if r.subdir == "" {
+		return r.path
+	}
+	return r.path + "//" + r.subdir
+}
+
+// Checkout resolve ref para um commit e extrai em dir os ficheiros da pasta
+// dos packs nesse commit, sem tocar na working tree do repositório.
+func (r *Repository) Checkout(ctx context.Context, ref, dir string) (string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("%w: %q", service.ErrCatalogRefNotFound, ref)
+	}
+
+	out, err := r.git(ctx, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", service.ErrCatalogRefNotFound, ref)
+	}
+	commit := strings.TrimSpace(string(out))
+
+	// commit:pasta põe o conteúdo da pasta na raiz do arquivo
+	treeish := commit
+	if r.subdir != "" {
+		treeish += ":" + r.subdir
+	}
+	cmd := r.git(ctx, "archive", "--format=tar", treeish)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("falha ao executar o git: %w", err)
+	}
+	extractErr := labpack.ExtractTar(stdout, dir)
+	if extractErr != nil {
+		// Sem leitor, o git ficaria bloqueado a escrever o resto do arquivo
+		cmd.Process.Kill()
+	}
+	if err := cmd.Wait(); err != nil && extractErr == nil {
+		return "", fmt.Errorf("falha no git archive de %s: %v: %s", treeish, err, strings.TrimSpace(stderr.String()))
+	}
+	if extractErr != nil {
+		return "", extractErr
+	}
+	return commit, nil
+}
+
+func (r *Repository) git(ctx context.Context, args ...string) *exec.Cmd {
+	// O volume do repositório pode pertencer a outro utilizador do host
+	base := []string{"-c", "safe.directory=" + r.path, "-C", r.path}
+	return exec.CommandContext(ctx, "git", append(base, args...)...)
+}
@@ -0,0 +1,61 @@
+package gitsource
+
+import (
+	"context"
+	"errors"
+	"lab-devops/internal/service"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git não está instalado")
+	}
+
+	repoDir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t", "-C", repoDir}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q")
+	if err := os.MkdirAll(filepath.Join(repoDir, "packs", "lab"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "packs", "lab", "lab.yaml"), []byte("title: v1\ntype: linux\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "-A")
+	run("commit", "-q", "-m", "v1")
+	run("tag", "v1")
+	want := run("rev-parse", "HEAD")
+
+	// Alterações por commitar não entram no checkout
+	os.WriteFile(filepath.Join(repoDir, "packs", "lab", "lab.yaml"), []byte("title: sujo\ntype: linux\n"), 0644)
+
+	dir := t.TempDir()
+	commit, err := New(repoDir, "/packs/").Checkout(context.Background(), "v1", dir)
+	if err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	if commit != want {
+		t.Errorf("commit = %s, esperado %s", commit, want)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "lab", "lab.yaml"))
+	if err != nil || !strings.Contains(string(content), "v1") {
+		t.Errorf("lab.yaml extraído: %q, %v", content, err)
+	}
+
+	_, err = New(repoDir, "").Checkout(context.Background(), "nao-existe", t.TempDir())
+	if !errors.Is(err, service.ErrCatalogRefNotFound) {
+		t.Errorf("esperava ErrCatalogRefNotFound, obteve %v", err)
+	}
+}
@@ -0,0 +1,342 @@
+// Package labpack lê e escreve os lab packs: o conteúdo das trilhas e dos
+// labs numa árvore de ficheiros, para ser guardado em controlo de versões.
+//
+//	<pack>/
+//	  track.yaml          slug, título, descrição e se a trilha é sequencial
+//	  <lab>/
+//	    lab.yaml          slug, título, tipo, ordem, pré-requisitos, limites, imagem,
+//	                      verificações e dicas
+//	    instructions.md   enunciado em markdown
+//	    main.tf           código inicial (playbook.yml, run.sh ou main.yml conforme o tipo)
+//	    validation.sh     script de validação (validation.yml no Ansible, validation.json
+//	                      nas asserções Terraform)
+//
+// O slug é o ID da trilha ou do lab na base, o que torna a importação
+// idempotente. Um pack sem track.yaml tem labs que não pertencem a nenhuma
+// trilha.
+package labpack
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"lab-devops/internal/domain"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	trackFileName = "track.yaml"
+	labFileName   = "lab.yaml"
+
+	defaultInstructions = "instructions.md"
+
+	// untrackedDir é a pasta do export com os labs sem trilha.
+	untrackedDir = "sem-trilha"
+)
+
+var slugPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Pack é uma trilha e os seus labs. Track é nil num pack de labs sem trilha.
+type Pack struct {
+	Track *domain.Track
+	Labs  []*domain.Lab
+}
+
+// trackFile é o conteúdo do track.yaml.
+type trackFile struct {
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Sequential  bool   `json:"sequential,omitempty"`
+}
+
+// labFile é o conteúdo do lab.yaml. Instructions, InitialCode e Validation
+// são nomes de ficheiros da pasta do lab; omitidos, valem os nomes padrão do
+// tipo do lab.
+type labFile struct {
+	Slug          string                   `json:"slug"`
+	Title         string                   `json:"title"`
+	Type          string                   `json:"type"`
+	Order         int                      `json:"order,omitempty"`
+	Prerequisites []string                 `json:"prerequisites,omitempty"`
+	Instructions  string                   `json:"instructions,omitempty"`
+	InitialCode   string                   `json:"initial_code,omitempty"`
+	Validation    string                   `json:"validation,omitempty"`
+	Limits        *domain.ResourceLimits   `json:"limits,omitempty"`
+	Image         *domain.LabImage         `json:"image,omitempty"`
+	Checks        []domain.ValidationCheck `json:"checks,omitempty"`
+	Hints         []domain.Hint            `json:"hints,omitempty"`
+}
+
+// codeFileName é o nome do ficheiro de código inicial de um tipo de lab, o
+// mesmo que o executor usa no container.
+func codeFileName(labType string) string {
+	switch domain.ExecutionType(labType) {
+	case domain.TypeTerraform:
+		return "main.tf"
+	case domain.TypeAnsible:
+		return "playbook.yml"
+	case domain.TypeGithubActions:
+		return "main.yml"
+	default:
+		return "run.sh"
+	}
+}
+
+// validationFileName é o nome do ficheiro de validação de um lab.
+func validationFileName(labType, code string) string {
+	switch {
+	case domain.ExecutionType(labType) == domain.TypeAnsible:
+		return "validation.yml"
+	case domain.ExecutionType(labType) == domain.TypeTerraform && strings.HasPrefix(strings.TrimSpace(code), "["):
+		return "validation.json"
+	default:
+		return "validation.sh"
+	}
+}
+
+// Load lê os packs de fsys. A raiz é um pack se tiver track.yaml ou pastas
+// de labs; senão, cada subpasta que o seja é um pack. Como o slug é o ID na
+// base, dois labs ou duas trilhas com o mesmo slug são recusados.
+func Load(fsys fs.FS) ([]Pack, error) {
+	isPack, err := isPackDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if isPack {
+		pack, err := loadPack(fsys, ".")
+		if err != nil {
+			return nil, err
+		}
+		packs := []Pack{pack}
+		return packs, checkUniqueSlugs(packs)
+	}
+
+	dirs, err := subdirs(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var packs []Pack
+	for _, dir := range dirs {
+		ok, err := isPackDir(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		pack, err := loadPack(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	if len(packs) == 0 {
+		return nil, fmt.Errorf("nenhum lab pack encontrado (falta %s ou pastas com %s)", trackFileName, labFileName)
+	}
+	if err := checkUniqueSlugs(packs); err != nil {
+		return nil, err
+	}
+	return packs, nil
+}
+
+// checkUniqueSlugs recusa slugs repetidos: a importação gravaria o segundo
+// lab (ou trilha) por cima do primeiro.
+func checkUniqueSlugs(packs []Pack) error {
+	tracks := make(map[string]bool)
+	labs := make(map[string]bool)
+	for _, pack := range packs {
+		if pack.Track != nil {
+			if tracks[pack.Track.ID] {
+				return fmt.Errorf("slug de trilha repetido: %q", pack.Track.ID)
+			}
+			tracks[pack.Track.ID] = true
+		}
+		for _, lab := range pack.Labs {
+			if labs[lab.ID] {
+				return fmt.Errorf("slug de lab repetido: %q", lab.ID)
+			}
+			labs[lab.ID] = true
+		}
+	}
+	return nil
+}
+
+func isPackDir(fsys fs.FS, dir string) (bool, error) {
+	if exists(fsys, path.Join(dir, trackFileName)) {
+		return true, nil
+	}
+	dirs, err := subdirs(fsys, dir)
+	if err != nil {
+		return false, err
+	}
+	for _, sub := range dirs {
+		if exists(fsys, path.Join(sub, labFileName)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func loadPack(fsys fs.FS, dir string) (Pack, error) {
+	var pack Pack
+
+	trackPath := path.Join(dir, trackFileName)
+	if exists(fsys, trackPath) {
+		var tf trackFile
+		if err := readYAML(fsys, trackPath, &tf); err != nil {
+			return pack, err
+		}
+		if tf.Slug == "" {
+			tf.Slug = path.Base(dir)
+		}
+		if !slugPattern.MatchString(tf.Slug) {
+			return pack, fmt.Errorf("%s: slug inválido %q", trackPath, tf.Slug)
+		}
+		if tf.Title == "" {
+			return pack, fmt.Errorf("%s: título é obrigatório", trackPath)
+		}
+		pack.Track = &domain.Track{ID: tf.Slug, Title: tf.Title, Description: tf.Description, Sequential: tf.Sequential}
+	}
+
+	dirs, err := subdirs(fsys, dir)
+	if err != nil {
+		return pack, err
+	}
+	for _, sub := range dirs {
+		if !exists(fsys, path.Join(sub, labFileName)) {
+			continue
+		}
+		lab, err := loadLab(fsys, sub)
+		if err != nil {
+			return pack, err
+		}
+		if pack.Track != nil {
+			lab.TrackID = pack.Track.ID
+		}
+		// Sem ordem explícita, os labs seguem a ordem das pastas
+		if lab.LabOrder == 0 {
+			lab.LabOrder = len(pack.Labs) + 1
+		}
+		pack.Labs = append(pack.Labs, lab)
+	}
+	sort.SliceStable(pack.Labs, func(i, j int) bool { return pack.Labs[i].LabOrder < pack.Labs[j].LabOrder })
+	return pack, nil
+}
+
+func loadLab(fsys fs.FS, dir string) (*domain.Lab, error) {
+	labPath := path.Join(dir, labFileName)
+	var lf labFile
+	if err := readYAML(fsys, labPath, &lf); err != nil {
+		return nil, err
+	}
+	if lf.Slug == "" {
+		lf.Slug = path.Base(dir)
+	}
+	if !slugPattern.MatchString(lf.Slug) {
+		return nil, fmt.Errorf("%s: slug inválido %q", labPath, lf.Slug)
+	}
+	if lf.Title == "" || lf.Type == "" {
+		return nil, fmt.Errorf("%s: título e tipo são obrigatórios", labPath)
+	}
+
+	instructions, err := readOptional(fsys, dir, lf.Instructions, defaultInstructions)
+	if err != nil {
+		return nil, err
+	}
+	initialCode, err := readOptional(fsys, dir, lf.InitialCode, codeFileName(lf.Type))
+	if err != nil {
+		return nil, err
+	}
+	validationName := lf.Validation
+	if validationName == "" {
+		// Sem nome explícito, usa o primeiro nome padrão do tipo que exista
+		for _, name := range []string{validationFileName(lf.Type, ""), validationFileName(lf.Type, "[")} {
+			if exists(fsys, path.Join(dir, name)) {
+				validationName = name
+				break
+			}
+		}
+	}
+	validation := ""
+	if validationName != "" {
+		if validation, err = readOptional(fsys, dir, validationName, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	lab := &domain.Lab{
+		ID:             lf.Slug,
+		Title:          lf.Title,
+		Type:           lf.Type,
+		Instructions:   instructions,
+		InitialCode:    initialCode,
+		LabOrder:       lf.Order,
+		Prerequisites:  lf.Prerequisites,
+		ValidationCode: validation,
+		Image:          lf.Image,
+		Checks:         lf.Checks,
+		Hints:          lf.Hints,
+	}
+	if lf.Limits != nil {
+		lab.Limits = *lf.Limits
+	}
+	return lab, nil
+}
+
+// readOptional lê o ficheiro name da pasta dir. Com name vazio lê fallback,
+// que pode não existir.
+func readOptional(fsys fs.FS, dir, name, fallback string) (string, error) {
+	required := name != ""
+	if !required {
+		name = fallback
+	}
+	if !fs.ValidPath(name) || strings.Contains(name, "/") {
+		return "", fmt.Errorf("%s: nome de ficheiro inválido %q", path.Join(dir, labFileName), name)
+	}
+
+	content, err := fs.ReadFile(fsys, path.Join(dir, name))
+	if err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("falha ao ler %s: %w", path.Join(dir, name), err)
+	}
+	return strings.ReplaceAll(string(content), "\r\n", "\n"), nil
+}
+
+func readYAML(fsys fs.FS, name string, v any) error {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("falha ao ler %s: %w", name, err)
+	}
+	if err := yaml.UnmarshalStrict(content, v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func subdirs(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler a pasta %s: %w", dir, err)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			dirs = append(dirs, path.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+func exists(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && !info.IsDir()
+}
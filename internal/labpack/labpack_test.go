@@ -0,0 +1,109 @@
+package labpack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadAndWriteRoundTrip(t *testing.T) {
+	fsys := fstest.MapFS{
+		"terraform/track.yaml":            {Data: []byte("slug: terraform\ntitle: Terraform\n")},
+		"terraform/02-s3/lab.yaml":        {Data: []byte("title: Bucket S3\ntype: terraform\nlimits:\n  timeout_seconds: 60\n")},
+		"terraform/02-s3/main.tf":         {Data: []byte("resource \"x\" \"y\" {}\r\n")},
+		"terraform/02-s3/validation.json": {Data: []byte(`[{"resource":"x.y"}]`)},
+		"terraform/01-intro/lab.yaml":     {Data: []byte("slug: tf-intro\ntitle: Introdução\ntype: terraform\ninstructions: LEIA.md\n")},
+		"terraform/01-intro/LEIA.md":      {Data: []byte("# Olá")},
+		"soltos/ansible/lab.yaml":         {Data: []byte("title: Playbook\ntype: ansible\norder: 5\nchecks:\n  - name: ok\n    command: \"true\"\n")},
+		"soltos/ansible/playbook.yml":     {Data: []byte("- hosts: all\n")},
+		"soltos/ansible/validation.yml":   {Data: []byte("- hosts: all\n")},
+		"soltos/notas/README.md":          {Data: []byte("não é um lab")},
+		"ignorada/README.md":              {Data: []byte("não é um pack")},
+	}
+
+	packs, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(packs) != 2 {
+		t.Fatalf("esperava 2 packs, obteve %d", len(packs))
+	}
+
+	untracked, tf := packs[0], packs[1]
+	if untracked.Track != nil || len(untracked.Labs) != 1 {
+		t.Fatalf("pack sem trilha inesperado: %+v", untracked)
+	}
+	if lab := untracked.Labs[0]; lab.ID != "ansible" || lab.LabOrder != 5 || lab.TrackID != "" || lab.ValidationCode == "" || len(lab.Checks) != 1 {
+		t.Errorf("lab sem trilha inesperado: %+v", lab)
+	}
+
+	if tf.Track == nil || tf.Track.ID != "terraform" || len(tf.Labs) != 2 {
+		t.Fatalf("pack terraform inesperado: %+v", tf)
+	}
+	intro, s3 := tf.Labs[0], tf.Labs[1]
+	if intro.ID != "tf-intro" || intro.Instructions != "# Olá" || intro.LabOrder != 1 || intro.TrackID != "terraform" {
+		t.Errorf("lab tf-intro inesperado: %+v", intro)
+	}
+	if s3.ID != "02-s3" || s3.InitialCode != "resource \"x\" \"y\" {}\n" || s3.ValidationCode == "" || s3.Limits.TimeoutSeconds != 60 || s3.LabOrder != 2 {
+		t.Errorf("lab 02-s3 inesperado: %+v", s3)
+	}
+
+	dir := t.TempDir()
+	if err := WriteDir(dir, packs); err != nil {
+		t.Fatalf("WriteDir: %v", err)
+	}
+	reloaded, err := Load(os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("Load do export: %v", err)
+	}
+	if !reflect.DeepEqual(packs, reloaded) {
+		t.Errorf("o export não reproduz os packs:\nantes: %+v\ndepois: %+v", packs, reloaded)
+	}
+}
+
+func TestLoadRejectsDuplicateSlugs(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"labs no mesmo pack", fstest.MapFS{
+			"a/lab.yaml": {Data: []byte("slug: intro\ntitle: A\ntype: linux\n")},
+			"b/lab.yaml": {Data: []byte("slug: intro\ntitle: B\ntype: linux\n")},
+		}},
+		{"labs em packs diferentes", fstest.MapFS{
+			"um/intro/lab.yaml":   {Data: []byte("title: A\ntype: linux\n")},
+			"dois/intro/lab.yaml": {Data: []byte("title: B\ntype: linux\n")},
+		}},
+		{"trilhas", fstest.MapFS{
+			"um/track.yaml":   {Data: []byte("slug: devops\ntitle: Um\n")},
+			"dois/track.yaml": {Data: []byte("slug: devops\ntitle: Dois\n")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.fsys); err == nil {
+				t.Fatal("esperava erro com slugs repetidos")
+			}
+		})
+	}
+}
+
+func TestExtractTarGzRejectsEscapingPaths(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "../fora.txt", Mode: 0644, Size: 2, Typeflag: tar.TypeReg})
+	tw.Write([]byte("ok"))
+	tw.Close()
+	gz.Close()
+
+	dir := t.TempDir()
+	if err := ExtractTarGz(&buf, dir); err == nil {
+		t.Fatal("esperava erro num caminho fora da pasta")
+	}
+}
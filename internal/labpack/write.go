@@ -0,0 +1,192 @@
+package labpack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// MaxArchiveSize limita o tamanho descomprimido de um arquivo de packs.
+const MaxArchiveSize = 32 << 20
+
+// WriteDir escreve os packs em dir, uma pasta por trilha (com o slug como
+// nome) e uma pasta sem-trilha para os labs sem trilha. Os ficheiros
+// existentes são substituídos; os que já não correspondem a nada ficam.
+func WriteDir(dir string, packs []Pack) error {
+	return write(packs, func(name string, data []byte, mode os.FileMode) error {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, mode)
+	})
+}
+
+// WriteTarGz escreve os packs num arquivo .tar.gz com a mesma estrutura do
+// WriteDir.
+func WriteTarGz(w io.Writer, packs []Pack) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	err := write(packs, func(name string, data []byte, mode os.FileMode) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(mode),
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ExtractTarGz extrai em dir um arquivo .tar.gz de packs (ver ExtractTar).
+func ExtractTarGz(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("arquivo inválido: %w", err)
+	}
+	defer gz.Close()
+	return ExtractTar(gz, dir)
+}
+
+// ExtractTar extrai em dir um arquivo .tar de packs. Só aceita pastas e
+// ficheiros regulares com caminhos relativos dentro de dir; links e o
+// cabeçalho global do git archive são ignorados.
+func ExtractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("arquivo inválido: %w", err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if name == "." {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("caminho inválido no arquivo: %q", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeXGlobalHeader, tar.TypeSymlink, tar.TypeLink:
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			total += hdr.Size
+			if total > MaxArchiveSize {
+				return fmt.Errorf("arquivo excede o limite de %d MB", MaxArchiveSize>>20)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			data, err := io.ReadAll(io.LimitReader(tr, hdr.Size))
+			if err != nil {
+				return fmt.Errorf("arquivo inválido: %w", err)
+			}
+			if err := os.WriteFile(target, data, 0644); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("tipo de entrada não suportado no arquivo: %q", hdr.Name)
+		}
+	}
+}
+
+// write gera os ficheiros dos packs e entrega cada um a emit.
+func write(packs []Pack, emit func(name string, data []byte, mode os.FileMode) error) error {
+	for _, pack := range packs {
+		dir := untrackedDir
+		if pack.Track != nil {
+			dir = pack.Track.ID
+			data, err := yaml.Marshal(trackFile{
+				Slug:        pack.Track.ID,
+				Title:       pack.Track.Title,
+				Description: pack.Track.Description,
+				Sequential:  pack.Track.Sequential,
+			})
+			if err != nil {
+				return err
+			}
+			if err := emit(path.Join(dir, trackFileName), data, 0644); err != nil {
+				return err
+			}
+		}
+
+		for _, lab := range pack.Labs {
+			labDir := path.Join(dir, lab.ID)
+			lf := labFile{
+				Slug:          lab.ID,
+				Title:         lab.Title,
+				Type:          lab.Type,
+				Order:         lab.LabOrder,
+				Prerequisites: lab.Prerequisites,
+				Image:         lab.Image,
+				Checks:        lab.Checks,
+				Hints:         lab.Hints,
+			}
+			if !lab.Limits.IsZero() {
+				limits := lab.Limits
+				lf.Limits = &limits
+			}
+
+			files := []struct {
+				name    string
+				content string
+				mode    os.FileMode
+			}{
+				{defaultInstructions, lab.Instructions, 0644},
+				{codeFileName(lab.Type), lab.InitialCode, 0644},
+				{validationFileName(lab.Type, lab.ValidationCode), lab.ValidationCode, 0644},
+			}
+			for _, f := range files {
+				if f.content == "" {
+					continue
+				}
+				if strings.HasSuffix(f.name, ".sh") {
+					f.mode = 0755
+				}
+				if err := emit(path.Join(labDir, f.name), []byte(f.content), f.mode); err != nil {
+					return err
+				}
+			}
+
+			data, err := yaml.Marshal(lf)
+			if err != nil {
+				return err
+			}
+			if err := emit(path.Join(labDir, labFileName), data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Driver identifica a base de dados do repositório.
+type Driver string
+
+const (
+	// DriverSQLite guarda tudo num ficheiro local.
+	DriverSQLite Driver = "sqlite3"
+	// DriverPostgres usa um servidor PostgreSQL externo, partilhável por
+	// várias réplicas da API.
+	DriverPostgres Driver = "postgres"
+)
+
+// sqlDB adapta as consultas do repositório, escritas com ? como no SQLite,
+// ao dialeto da base.
+type sqlDB struct {
+	*sql.DB
+	driver Driver
+}
+
+func (d *sqlDB) rebind(query string) string {
+	return rebind(d.driver, query)
+}
+
+func (d *sqlDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.DB.ExecContext(ctx, d.rebind(query), args...)
+}
+
+func (d *sqlDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.DB.QueryContext(ctx, d.rebind(query), args...)
+}
+
+func (d *sqlDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.DB.QueryRowContext(ctx, d.rebind(query), args...)
+}
+
+func (d *sqlDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sqlTx, error) {
+	tx, err := d.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, driver: d.driver}, nil
+}
+
+// sqlTx é o equivalente do sqlDB numa transação.
+type sqlTx struct {
+	*sql.Tx
+	driver Driver
+}
+
+func (t *sqlTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.Tx.ExecContext(ctx, rebind(t.driver, query), args...)
+}
+
+func (t *sqlTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.Tx.QueryContext(ctx, rebind(t.driver, query), args...)
+}
+
+func (t *sqlTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.Tx.QueryRowContext(ctx, rebind(t.driver, query), args...)
+}
+
+// execer é o que as escritas partilhadas entre sqlDB e sqlTx precisam.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// rebind troca os ? por $1, $2, ... no PostgreSQL. Os ? dentro de literais
+// entre plicas ficam como estão.
+func rebind(driver Driver, query string) string {
+	if driver != DriverPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	quoted := false
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '?' && !quoted:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// openDB abre a base do driver e confirma a ligação.
+func openDB(driver Driver, dsn string) (*sqlDB, error) {
+	switch driver {
+	case DriverSQLite, DriverPostgres:
+	default:
+		return nil, fmt.Errorf("driver de base de dados desconhecido: %q", driver)
+	}
+
+	database, err := sql.Open(string(driver), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, err
+	}
+	return &sqlDB{DB: database, driver: driver}, nil
+}
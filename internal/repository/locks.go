@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// Lock obtém o advisory lock name no PostgreSQL, partilhado por todas as
+// réplicas da API que usam a base. Como o lock das migrações, pertence à
+// sessão e fica numa ligação dedicada até ao unlock; se a réplica morrer, o
+// PostgreSQL liberta-o ao fechar a ligação. No SQLite só há uma réplica e a
+// função devolvida não faz nada.
+func (r *sqlRepository) Lock(ctx context.Context, name string) (unlock func(), err error) {
+	if r.db.driver != DriverPostgres {
+		return func() {}, nil
+	}
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := lockKey(name)
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("falha ao obter o lock %s: %w", name, err)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key)
+		conn.Close()
+	}, nil
+}
+
+// TryLock é o Lock sem espera: ok é falso se outra réplica tiver o lock.
+func (r *sqlRepository) TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error) {
+	if r.db.driver != DriverPostgres {
+		return func() {}, true, nil
+	}
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	key := lockKey(name)
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("falha ao obter o lock %s: %w", name, err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key)
+		conn.Close()
+	}, true, nil
+}
+
+// lockKey converte o nome do lock na chave numérica do advisory lock.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("lab-devops:" + name))
+	return int64(h.Sum64())
+}
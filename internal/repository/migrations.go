@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"lab-devops/db"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrIrreversibleMigration indica uma migração sem ficheiro .down.sql.
+var ErrIrreversibleMigration = errors.New("migração sem script de reversão")
+
+// Migration é um ficheiro numerado de db/migrations. O script de subida está
+// em <versão>_<nome>.sql (ou .up.sql) e o de reversão, opcional, em
+// <versão>_<nome>.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Reversible diz se a migração pode ser revertida.
+func (m Migration) Reversible() bool {
+	return m.down != ""
+}
+
+// MigrationStatus descreve uma migração e se já foi aplicada na base.
+type MigrationStatus struct {
+	Version    int
+	Name       string
+	Reversible bool
+	AppliedAt  *time.Time
+}
+
+// postgresMigrationLock é a chave do advisory lock que impede dois processos
+// (ex: o servidor a arrancar e "lab-api migrate") de aplicarem migrações ao
+// mesmo tempo.
+const postgresMigrationLock = 7245019
+
+// Migrator aplica as migrações por ordem de versão, cada uma numa transação,
+// e regista as aplicadas na tabela schema_migrations.
+type Migrator struct {
+	db         *sqlDB
+	migrations []Migration
+}
+
+// OpenDatabase abre a base do driver. No SQLite dsn é o caminho do ficheiro,
+// cuja pasta é criada se preciso; no PostgreSQL é a connection string.
+func OpenDatabase(driver Driver, dsn string) (*sql.DB, error) {
+	if driver == DriverSQLite {
+		if err := os.MkdirAll(filepath.Dir(dsn), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	database, err := openDB(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return database.DB, nil
+}
+
+// MigrationsFS devolve a origem das migrações do driver: as embutidas no
+// binário (db/migrations no SQLite, db/migrations/postgres no PostgreSQL)
+// quando migrationsPath é vazio, senão a pasta indicada. Um caminho para um
+// ficheiro (configuração antiga) usa a pasta onde ele está.
+func MigrationsFS(driver Driver, migrationsPath string) (fs.FS, error) {
+	if migrationsPath == "" {
+		if driver == DriverPostgres {
+			return fs.Sub(db.Migrations, "migrations/postgres")
+		}
+		return fs.Sub(db.Migrations, "migrations")
+	}
+
+	info, err := os.Stat(migrationsPath)
+	if err != nil {
+		return nil, fmt.Errorf("pasta de migrações inválida: %w", err)
+	}
+	if !info.IsDir() {
+		dir := filepath.Dir(migrationsPath)
+		log.Printf("AVISO [Repository]: MIGRATIONS_PATH aponta para um ficheiro; a usar as migrações da pasta %s", dir)
+		migrationsPath = dir
+	}
+	return os.DirFS(migrationsPath), nil
+}
+
+// NewMigrator lê as migrações de fsys. Ficheiros que não seguem o padrão
+// <versão>_<nome>.sql são ignorados.
+func NewMigrator(database *sql.DB, driver Driver, fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler as migrações: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		down := strings.HasSuffix(base, ".down")
+		base = strings.TrimSuffix(strings.TrimSuffix(base, ".down"), ".up")
+
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler a migração %s: %w", entry.Name(), err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("versão %d repetida nas migrações (%s e %s)", version, m.Name, name)
+		}
+		script := &m.up
+		if down {
+			script = &m.down
+		}
+		if *script != "" {
+			return nil, fmt.Errorf("migração %03d_%s definida mais de uma vez", version, name)
+		}
+		*script = string(content)
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migração %03d_%s só tem o script de reversão", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return &Migrator{db: &sqlDB{DB: database, driver: driver}, migrations: migrations}, nil
+}
+
+// Status lista todas as migrações conhecidas, aplicadas ou não.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := MigrationStatus{Version: mig.Version, Name: mig.Name, Reversible: mig.Reversible()}
+		if at, ok := applied[mig.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up aplica as migrações pendentes e devolve as que foram aplicadas. Numa
+// base criada antes de haver schema_migrations, as colunas acrescentadas
+// nessa altura são repostas antes das migrações.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		err := m.inTx(ctx, func(tx *sqlTx) error {
+			// O script segue tal como está escrito, sem rebind
+			if _, err := tx.Tx.ExecContext(ctx, mig.up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				mig.Version, mig.Name, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("falha na migração %03d_%s: %w", mig.Version, mig.Name, err)
+		}
+		log.Printf("INFO [Repository]: Migração %03d_%s aplicada", mig.Version, mig.Name)
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down reverte as últimas steps migrações aplicadas, da mais recente para a
+// mais antiga, e devolve as que foram revertidas.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if !mig.Reversible() {
+			return done, fmt.Errorf("%w: %03d_%s", ErrIrreversibleMigration, mig.Version, mig.Name)
+		}
+		err := m.inTx(ctx, func(tx *sqlTx) error {
+			if _, err := tx.Tx.ExecContext(ctx, mig.down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("falha ao reverter a migração %03d_%s: %w", mig.Version, mig.Name, err)
+		}
+		log.Printf("INFO [Repository]: Migração %03d_%s revertida", mig.Version, mig.Name)
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// ensureTable cria a tabela schema_migrations. Numa base criada antes de
+// haver migrações versionadas, acrescenta primeiro as colunas que o 001
+// (CREATE TABLE IF NOT EXISTS) não cria em tabelas já existentes.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	hasMigrations, err := m.tableExists(ctx, "schema_migrations")
+	if err != nil || hasMigrations {
+		return err
+	}
+	hasLabs, err := m.tableExists(ctx, "labs")
+	if err != nil {
+		return err
+	}
+
+	return m.inTx(ctx, func(tx *sqlTx) error {
+		// As bases PostgreSQL já nasceram com migrações versionadas
+		var covered []int
+		if hasLabs && m.db.driver == DriverSQLite {
+			if covered, err = upgradeLegacySchema(tx); err != nil {
+				return fmt.Errorf("falha ao atualizar o esquema anterior às migrações: %w", err)
+			}
+			log.Printf("INFO [Repository]: Esquema anterior às migrações atualizado")
+		}
+		_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`)
+		if err != nil {
+			return err
+		}
+
+		// As migrações cujas colunas a atualização já criou ficam aplicadas
+		for _, mig := range m.migrations {
+			if !slices.Contains(covered, mig.Version) {
+				continue
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				mig.Version, mig.Name, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) tableExists(ctx context.Context, table string) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	if m.db.driver == DriverPostgres {
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`
+	}
+
+	var count int
+	err := m.db.QueryRowContext(ctx, query, table).Scan(&count)
+	return count > 0, err
+}
+
+// lock impede, no PostgreSQL, que outro processo migre a base em simultâneo.
+// No SQLite a função devolvida não faz nada.
+func (m *Migrator) lock(ctx context.Context) (unlock func(), err error) {
+	if m.db.driver != DriverPostgres {
+		return func() {}, nil
+	}
+
+	// O advisory lock pertence à sessão: fica numa ligação dedicada
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, postgresMigrationLock); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("falha ao obter o lock das migrações: %w", err)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, postgresMigrationLock)
+		conn.Close()
+	}, nil
+}
+
+// applied devolve a data de aplicação de cada versão já aplicada.
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+	if exists, err := m.tableExists(ctx, "schema_migrations"); err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version int
+			at      time.Time
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sqlTx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigratorUpDown(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDatabase(DriverSQLite, filepath.Join(t.TempDir(), "lab.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"001_init.sql":        {Data: []byte("CREATE TABLE a (id TEXT);")},
+		"001_init.down.sql":   {Data: []byte("DROP TABLE a;")},
+		"002_add_b.up.sql":    {Data: []byte("ALTER TABLE a ADD COLUMN b TEXT;")},
+		"003_seed.sql":        {Data: []byte("INSERT INTO a (id, b) VALUES ('x', 'y');")},
+		"README.md":           {Data: []byte("ignorado")},
+		"notes_unversion.sql": {Data: []byte("ignorado")},
+	}
+	m, err := NewMigrator(db, DriverSQLite, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil || len(done) != 3 {
+		t.Fatalf("Up aplicou %d migrações (err %v), esperadas 3", len(done), err)
+	}
+	// Uma segunda passagem não volta a aplicar nada
+	if done, err := m.Up(ctx); err != nil || len(done) != 0 {
+		t.Fatalf("Up repetido aplicou %d migrações (err %v)", len(done), err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Fatalf("migração %d deveria estar aplicada", s.Version)
+		}
+	}
+
+	// A 003 não tem script de reversão: o down pára nela
+	if _, err := m.Down(ctx, 1); !errors.Is(err, ErrIrreversibleMigration) {
+		t.Fatalf("esperado ErrIrreversibleMigration, obtido %v", err)
+	}
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDatabase(DriverSQLite, filepath.Join(t.TempDir(), "lab.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := NewMigrator(db, DriverSQLite, fstest.MapFS{
+		"001_init.sql":   {Data: []byte("CREATE TABLE a (id TEXT);")},
+		"002_broken.sql": {Data: []byte("CREATE TABLE b (id TEXT); INSERT INTO missing VALUES (1);")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(ctx); err == nil {
+		t.Fatal("a migração 002 deveria falhar")
+	}
+	statuses, _ := m.Status(ctx)
+	if statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil {
+		t.Fatal("só a 001 deveria ficar registada")
+	}
+	var tables int
+	db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'b'`).Scan(&tables)
+	if tables != 0 {
+		t.Fatal("a tabela da migração falhada deveria ter sido revertida")
+	}
+}
+
+func TestMigratorUpgradesLegacySchema(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDatabase(DriverSQLite, filepath.Join(t.TempDir(), "lab.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Base criada antes de haver migrações versionadas
+	_, err = db.Exec(`
+		CREATE TABLE tracks (id TEXT PRIMARY KEY, title TEXT NOT NULL, description TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+		CREATE TABLE labs (id TEXT PRIMARY KEY, title TEXT NOT NULL, type TEXT NOT NULL, instructions TEXT NOT NULL,
+			initial_code TEXT NOT NULL, validation_code TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			track_id TEXT, lab_order INTEGER);
+		CREATE TABLE workspaces (id TEXT PRIMARY KEY, lab_id TEXT NOT NULL, user_code TEXT, state BLOB,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, status TEXT DEFAULT 'in_progress');
+		INSERT INTO workspaces (id, lab_id, user_code, updated_at) VALUES
+			('antigo', 'lab-1', 'a', '2024-01-01 10:00:00'), ('novo', 'lab-1', 'b', '2024-06-01 10:00:00');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := MigrationsFS(DriverSQLite, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMigrator(db, DriverSQLite, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As colunas das migrações posteriores ao 001 já foram criadas pela
+	// atualização, que dá essas migrações como aplicadas
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up numa base anterior às migrações: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE tracks SET sequential = 1; UPDATE labs SET prerequisites = '[]'`); err != nil {
+		t.Fatalf("colunas da 003 em falta: %v", err)
+	}
+	var kept string
+	if err := db.QueryRow(`SELECT group_concat(id) FROM workspaces`).Scan(&kept); err != nil || kept != "novo" {
+		t.Fatalf("workspaces depois da atualização: %q, %v", kept, err)
+	}
+}
+
+func TestMigrationRemovesDuplicateWorkspaces(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDatabase(DriverSQLite, filepath.Join(t.TempDir(), "lab.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fsys, err := MigrationsFS(DriverSQLite, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMigrator(db, DriverSQLite, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sem o índice único, como nas bases anteriores à 004
+	if _, err := m.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO workspaces (id, lab_id, user_id, user_code, updated_at) VALUES
+			('antigo', 'lab-1', 'u1', 'a', '2024-01-01 10:00:00'),
+			('novo', 'lab-1', 'u1', 'b', '2024-06-01 10:00:00'),
+			('outro', 'lab-1', 'u2', 'c', '2024-01-01 10:00:00');
+		INSERT INTO workspace_files (workspace_id, path, content) VALUES ('antigo', 'a.tf', '');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up com workspaces repetidos: %v", err)
+	}
+	var ids string
+	db.QueryRow(`SELECT group_concat(id) FROM (SELECT id FROM workspaces ORDER BY id)`).Scan(&ids)
+	if ids != "novo,outro" {
+		t.Fatalf("workspaces restantes: %q", ids)
+	}
+	var files int
+	db.QueryRow(`SELECT COUNT(*) FROM workspace_files`).Scan(&files)
+	if files != 0 {
+		t.Fatalf("ficheiros do workspace apagado: %d", files)
+	}
+	if _, err := db.Exec(`INSERT INTO workspaces (id, lab_id, user_id, user_code) VALUES ('x', 'lab-1', 'u1', '')`); err == nil {
+		t.Fatal("o índice único deveria recusar um segundo workspace")
+	}
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"lab-devops/internal/service"
+
+	_ "github.com/lib/pq"
+)
+
+// NewPostgresRepository liga-se ao PostgreSQL em dsn (ex.:
+// postgres://lab:secret@db:5432/lab?sslmode=disable) e aplica as migrações
+// pendentes. Várias réplicas da API podem partilhar a base: coordenam-se
+// com advisory locks (ver Lock).
+func NewPostgresRepository(dsn string, migrationsPath string) (service.WorkspaceRepository, error) {
+	return newSQLRepository(DriverPostgres, dsn, migrationsPath)
+}
@@ -3,61 +3,190 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"lab-devops/internal/domain"
 	"lab-devops/internal/service"
 	"log"
-	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// sqlRepository implementa o WorkspaceRepository sobre SQLite ou PostgreSQL.
+// As consultas são escritas para o SQLite; o sqlDB adapta-as ao PostgreSQL.
 type sqlRepository struct {
-	db *sql.DB
+	db *sqlDB
 }
 
-func NewSQLiteRepository(dbPath string, migrationScriptPath string) (service.WorkspaceRepository, error) {
-	dbDir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
+// NewSQLiteRepository abre a base em dbPath e aplica as migrações pendentes
+// (ver MigrationsFS para o significado de migrationsPath).
+func NewSQLiteRepository(dbPath string, migrationsPath string) (service.WorkspaceRepository, error) {
+	return newSQLRepository(DriverSQLite, dbPath, migrationsPath)
+}
+
+func newSQLRepository(driver Driver, dsn, migrationsPath string) (service.WorkspaceRepository, error) {
+	database, err := OpenDatabase(driver, dsn)
+	if err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	fsys, err := MigrationsFS(driver, migrationsPath)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := NewMigrator(database, driver, fsys)
 	if err != nil {
 		return nil, err
 	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		return nil, err
+	}
 
-	if err := db.Ping(); err != nil {
+	log.Printf("✅ Base de dados %s conectada e migrações aplicadas.", driver)
+	return &sqlRepository{db: &sqlDB{DB: database, driver: driver}}, nil
+}
+
+// upgradeLegacySchema acrescenta as colunas introduzidas antes de haver
+// controlo de versões das migrações, que o 001 (CREATE TABLE IF NOT EXISTS)
+// não cria em bases já existentes, e as que migrações posteriores
+// acrescentam às tabelas do 001. Devolve essas migrações, que já não podem
+// correr (o SQLite não tem ADD COLUMN IF NOT EXISTS).
+func upgradeLegacySchema(tx *sqlTx) (covered []int, err error) {
+	columns := []struct {
+		table, column, definition string
+		// version é a migração que acrescenta a coluna nas bases versionadas
+		version int
+	}{
+		{"workspaces", "user_id", "TEXT NOT NULL DEFAULT ''", 0},
+		{"users", "role", "TEXT NOT NULL DEFAULT 'learner'", 0},
+		{"labs", "resource_limits", "TEXT", 0},
+		{"labs", "image", "TEXT", 0},
+		{"labs", "validation_checks", "TEXT", 0},
+		{"labs", "hints", "TEXT", 0},
+		{"workspaces", "score", "INTEGER NOT NULL DEFAULT 0", 0},
+		{"workspaces", "max_score", "INTEGER NOT NULL DEFAULT 0", 0},
+		{"workspaces", "validation_report", "TEXT", 0},
+		{"tracks", "sequential", "BOOLEAN NOT NULL DEFAULT 0", 3},
+		{"labs", "prerequisites", "TEXT", 3},
+	}
+	seen := make(map[int]bool)
+	for _, c := range columns {
+		if err := ensureColumn(tx, c.table, c.column, c.definition); err != nil {
+			return nil, err
+		}
+		if c.version > 0 && !seen[c.version] {
+			seen[c.version] = true
+			covered = append(covered, c.version)
+		}
+	}
+	// O índice único do 001 falharia com os workspaces repetidos destas bases
+	if err := removeDuplicateWorkspaces(tx); err != nil {
 		return nil, err
 	}
+	return covered, nil
+}
+
+// removeDuplicateWorkspaces deixa um workspace por utilizador e por lab, o
+// atualizado mais recentemente, e apaga o que pertencia aos restantes (o
+// mesmo que a migração 004 faz nas bases versionadas).
+func removeDuplicateWorkspaces(tx *sqlTx) error {
+	tables := []string{"workspaces", "workspace_files", "executions", "workspace_revisions", "workspace_state_versions", "workspace_hints"}
+	existing := make(map[string]bool)
+	for _, table := range tables {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count); err != nil {
+			return err
+		}
+		existing[table] = count > 0
+	}
+	if !existing["workspaces"] {
+		return nil
+	}
+
+	duplicates := `SELECT w.id FROM workspaces w
+		WHERE EXISTS (
+			SELECT 1 FROM workspaces o
+			WHERE o.user_id = w.user_id AND o.lab_id = w.lab_id AND o.id <> w.id
+			  AND (o.updated_at > w.updated_at
+			       OR (o.updated_at = w.updated_at AND o.id > w.id)
+			       OR (w.updated_at IS NULL AND (o.updated_at IS NOT NULL OR o.id > w.id))))`
+	if _, err := tx.Exec(`CREATE TEMPORARY TABLE duplicate_workspaces AS ` + duplicates); err != nil {
+		return err
+	}
+	for _, table := range tables[1:] {
+		if !existing[table] {
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM ` + table + ` WHERE workspace_id IN (SELECT id FROM duplicate_workspaces)`); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM workspaces WHERE id IN (SELECT id FROM duplicate_workspaces)`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DROP TABLE duplicate_workspaces`)
+	return err
+}
 
-	script, err := os.ReadFile(migrationScriptPath)
+// ensureColumn adiciona a coluna à tabela caso ela ainda não exista.
+// Tabelas inexistentes são ignoradas.
+func ensureColumn(db *sqlTx, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer rows.Close()
 
-	if _, err = db.Exec(string(script)); err != nil {
-		return nil, err
+	tableExists := false
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		tableExists = true
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !tableExists {
+		return nil
 	}
 
-	log.Println("✅ Base de dados SQLite conectada e migrações aplicadas.")
-	return &sqlRepository{db: db}, nil
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
 }
 
 func (r *sqlRepository) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
 }
 
-func (r *sqlRepository) GetLabByID(ctx context.Context, labID string) (*domain.Lab, error) {
-	query := `SELECT id, title, type, instructions, initial_code, created_at, 
-	                 track_id, lab_order, COALESCE(validation_code, '')
-	          FROM labs WHERE id = ?`
+// labColumns é a lista de colunas lida por scanLab.
+const labColumns = `id, title, type, instructions, initial_code, created_at,
+	                 track_id, lab_order, COALESCE(validation_code, ''),
+	                 COALESCE(resource_limits, ''), COALESCE(image, ''),
+	                 COALESCE(validation_checks, ''), COALESCE(hints, ''),
+	                 COALESCE(prerequisites, '')`
 
-	row := r.db.QueryRowContext(ctx, query, labID)
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+func scanLab(row rowScanner) (*domain.Lab, error) {
 	var lab domain.Lab
-	err := row.Scan(
+	var limits, image, checks, hints, prerequisites string
+	if err := row.Scan(
 		&lab.ID,
 		&lab.Title,
 		&lab.Type,
@@ -67,240 +196,862 @@ func (r *sqlRepository) GetLabByID(ctx context.Context, labID string) (*domain.L
 		&lab.TrackID,
 		&lab.LabOrder,
 		&lab.ValidationCode,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
+		&limits,
+		&image,
+		&checks,
+		&hints,
+		&prerequisites,
+	); err != nil {
 		return nil, err
 	}
+	if limits != "" {
+		if err := json.Unmarshal([]byte(limits), &lab.Limits); err != nil {
+			return nil, fmt.Errorf("resource_limits inválido no lab %s: %w", lab.ID, err)
+		}
+	}
+	if image != "" {
+		if err := json.Unmarshal([]byte(image), &lab.Image); err != nil {
+			return nil, fmt.Errorf("image inválido no lab %s: %w", lab.ID, err)
+		}
+	}
+	if checks != "" {
+		if err := json.Unmarshal([]byte(checks), &lab.Checks); err != nil {
+			return nil, fmt.Errorf("validation_checks inválido no lab %s: %w", lab.ID, err)
+		}
+	}
+	if hints != "" {
+		if err := json.Unmarshal([]byte(hints), &lab.Hints); err != nil {
+			return nil, fmt.Errorf("hints inválido no lab %s: %w", lab.ID, err)
+		}
+	}
+	if prerequisites != "" {
+		if err := json.Unmarshal([]byte(prerequisites), &lab.Prerequisites); err != nil {
+			return nil, fmt.Errorf("prerequisites inválido no lab %s: %w", lab.ID, err)
+		}
+	}
 	return &lab, nil
 }
 
-func (r *sqlRepository) GetWorkspaceByLabID(ctx context.Context, labID string) (*domain.Workspace, error) {
-	// CORREÇÃO: Adicionado 'status' no SELECT
-	query := `SELECT id, lab_id, user_code, state, updated_at, status 
-	          FROM workspaces WHERE lab_id = ?`
-
-	row := r.db.QueryRowContext(ctx, query, labID)
+// workspaceColumns é a lista de colunas lida por scanWorkspace.
+const workspaceColumns = `id, lab_id, user_id, user_code, state, updated_at, status,
+	                       score, max_score, COALESCE(validation_report, ''),
+	                       (SELECT COUNT(*) FROM workspace_hints h WHERE h.workspace_id = workspaces.id)`
 
+func scanWorkspace(row rowScanner) (*domain.Workspace, error) {
 	var ws domain.Workspace
-	err := row.Scan(
+	var report string
+	if err := row.Scan(
 		&ws.ID,
 		&ws.LabID,
+		&ws.UserID,
 		&ws.UserCode,
 		&ws.State,
 		&ws.UpdatedAt,
 		&ws.Status,
-	)
+		&ws.Score,
+		&ws.MaxScore,
+		&report,
+		&ws.HintsUsed,
+	); err != nil {
+		return nil, err
+	}
+	if report != "" {
+		if err := json.Unmarshal([]byte(report), &ws.Report); err != nil {
+			return nil, fmt.Errorf("validation_report inválido no workspace %s: %w", ws.ID, err)
+		}
+	}
+	return &ws, nil
+}
+
+func encodeLimits(limits domain.ResourceLimits) (any, error) {
+	if limits.IsZero() {
+		return nil, nil
+	}
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func encodeImage(image *domain.LabImage) (any, error) {
+	if image == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(image)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func encodeChecks(checks []domain.ValidationCheck) (any, error) {
+	if len(checks) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(checks)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func encodeFiles(files map[string]string) (any, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(files)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func encodeHints(hints []domain.Hint) (any, error) {
+	if len(hints) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(hints)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func encodePrerequisites(ids []string) (any, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (r *sqlRepository) GetLabByID(ctx context.Context, labID string) (*domain.Lab, error) {
+	query := `SELECT ` + labColumns + ` FROM labs WHERE id = ?`
+
+	row := r.db.QueryRowContext(ctx, query, labID)
+
+	lab, err := scanLab(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return lab, nil
+}
+
+func (r *sqlRepository) GetWorkspaceByUserAndLab(ctx context.Context, userID, labID string) (*domain.Workspace, error) {
+	query := `SELECT ` + workspaceColumns + `
+	          FROM workspaces WHERE user_id = ? AND lab_id = ?`
+
+	row := r.db.QueryRowContext(ctx, query, userID, labID)
+
+	ws, err := scanWorkspace(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Nenhum workspace encontrado
 		}
 		return nil, err
 	}
-	return &ws, nil
+	return ws, nil
+}
+
+func (r *sqlRepository) GetWorkspaceByID(ctx context.Context, workspaceID string) (*domain.Workspace, error) {
+	query := `SELECT ` + workspaceColumns + ` FROM workspaces WHERE id = ?`
+
+	ws, err := scanWorkspace(r.db.QueryRowContext(ctx, query, workspaceID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ws, nil
 }
 
-// UpdateWorkspaceState atualiza o ficheiro .tfstate (blob).
+// UpdateWorkspaceState atualiza o ficheiro .tfstate (blob) e acrescenta-o ao
+// histórico, a menos que seja igual à última versão (mesmo serial e lineage).
 func (r *sqlRepository) UpdateWorkspaceState(ctx context.Context, workspaceID string, state []byte) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockWorkspaceRow(ctx, tx, workspaceID); err != nil {
+		return err
+	}
 	query := `UPDATE workspaces SET state = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, state, workspaceID); err != nil {
+		return err
+	}
+
+	if len(state) > 0 {
+		serial, lineage := domain.StateMetadata(state)
+		var lastSerial int64
+		var lastLineage string
+		err := tx.QueryRowContext(ctx,
+			`SELECT serial, lineage FROM workspace_state_versions WHERE workspace_id = ? ORDER BY version DESC LIMIT 1`,
+			workspaceID).Scan(&lastSerial, &lastLineage)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		unchanged := err == nil && lineage != "" && serial == lastSerial && lineage == lastLineage
+		if !unchanged {
+			if _, err := insertStateVersion(ctx, tx, workspaceID, state, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
 
-	_, err := r.db.ExecContext(ctx, query, state, workspaceID)
+// lockWorkspaceRow bloqueia, no PostgreSQL, a linha do workspace até ao fim
+// da transação: duas réplicas a gravar o estado do mesmo workspace leriam o
+// mesmo MAX(version) e a segunda falharia na chave primária (workspace_id,
+// version). No SQLite a escrita já é exclusiva.
+func lockWorkspaceRow(ctx context.Context, tx *sqlTx, workspaceID string) error {
+	if tx.driver != DriverPostgres {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `SELECT id FROM workspaces WHERE id = ? FOR UPDATE`, workspaceID)
 	return err
 }
 
-// GetWorkspaceState obtém o .tfstate atual.
-func (r *sqlRepository) GetWorkspaceState(ctx context.Context, workspaceID string) ([]byte, error) {
-	query := `SELECT state FROM workspaces WHERE id = ?`
+// insertStateVersion grava o estado como a próxima versão do workspace. A
+// transação tem de ter chamado lockWorkspaceRow.
+func insertStateVersion(ctx context.Context, tx *sqlTx, workspaceID string, state []byte, restoredFrom *int) (*domain.StateVersion, error) {
+	v := &domain.StateVersion{WorkspaceID: workspaceID, Size: len(state), RestoredFrom: restoredFrom, State: state}
+	v.Serial, v.Lineage = domain.StateMetadata(state)
 
-	row := r.db.QueryRowContext(ctx, query, workspaceID)
+	err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) + 1 FROM workspace_state_versions WHERE workspace_id = ?`,
+		workspaceID).Scan(&v.Version)
+	if err != nil {
+		return nil, err
+	}
 
-	var state []byte
-	if err := row.Scan(&state); err != nil {
+	query := `INSERT INTO workspace_state_versions (workspace_id, version, serial, lineage, state, restored_from)
+	          VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, workspaceID, v.Version, v.Serial, v.Lineage, state, restoredFrom); err != nil {
 		return nil, err
 	}
-	return state, nil
+	v.CreatedAt = time.Now()
+	return v, nil
 }
 
-// --- Métodos por implementar (para completar a interface) ---
-
-func (r *sqlRepository) ListLabs(ctx context.Context) ([]*domain.Lab, error) {
-	query := `SELECT id, title, type, instructions, initial_code, created_at,
-	                 track_id, lab_order, COALESCE(validation_code, '')
-	          FROM labs ORDER BY lab_order ASC`
-	rows, err := r.db.QueryContext(ctx, query)
+// ListStateVersions devolve o histórico do estado do workspace, da versão
+// mais recente para a mais antiga, sem o conteúdo.
+func (r *sqlRepository) ListStateVersions(ctx context.Context, workspaceID string) ([]*domain.StateVersion, error) {
+	query := `SELECT version, serial, lineage, length(state), restored_from, created_at
+	          FROM workspace_state_versions WHERE workspace_id = ? ORDER BY version DESC`
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var labs []*domain.Lab
+
+	var versions []*domain.StateVersion
 	for rows.Next() {
-		var lab domain.Lab
-		if err := rows.Scan(
-			&lab.ID,
-			&lab.Title,
-			&lab.Type,
-			&lab.Instructions,
-			&lab.InitialCode,
-			&lab.CreatedAt,
-			&lab.TrackID,  // Novo
-			&lab.LabOrder, // Novo
-			&lab.ValidationCode,
-		); err != nil {
+		v := domain.StateVersion{WorkspaceID: workspaceID}
+		var restoredFrom sql.NullInt64
+		if err := rows.Scan(&v.Version, &v.Serial, &v.Lineage, &v.Size, &restoredFrom, &v.CreatedAt); err != nil {
 			return nil, err
 		}
-		labs = append(labs, &lab)
+		if restoredFrom.Valid {
+			from := int(restoredFrom.Int64)
+			v.RestoredFrom = &from
+		}
+		versions = append(versions, &v)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return labs, nil
+	return versions, nil
 }
 
-func (r *sqlRepository) UpdateWorkspaceCode(ctx context.Context, workspaceID string, code string) error {
-	query := `UPDATE workspaces SET user_code = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err := r.db.ExecContext(ctx, query, code, workspaceID)
-	return err
-}
-func (r *sqlRepository) CreateWorkspace(ctx context.Context, labID string) (*domain.Workspace, error) {
-	lab, err := r.GetLabByID(ctx, labID)
+// GetStateVersion devolve uma versão do estado, com o conteúdo. Devolve
+// (nil, nil) se a versão não existir.
+func (r *sqlRepository) GetStateVersion(ctx context.Context, workspaceID string, version int) (*domain.StateVersion, error) {
+	query := `SELECT version, serial, lineage, state, restored_from, created_at
+	          FROM workspace_state_versions WHERE workspace_id = ? AND version = ?`
+
+	v := domain.StateVersion{WorkspaceID: workspaceID}
+	var restoredFrom sql.NullInt64
+	err := r.db.QueryRowContext(ctx, query, workspaceID, version).Scan(
+		&v.Version, &v.Serial, &v.Lineage, &v.State, &restoredFrom, &v.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	if lab == nil {
-		return nil, sql.ErrNoRows // Ou um erro customizado "lab not found"
+	if restoredFrom.Valid {
+		from := int(restoredFrom.Int64)
+		v.RestoredFrom = &from
 	}
+	v.Size = len(v.State)
+	return &v, nil
+}
 
-	newWorkspaceID := uuid.New().String()
-	insertQuery := `INSERT INTO workspaces (id, lab_id, user_code) VALUES (?, ?, ?)`
-
-	_, err = r.db.ExecContext(ctx, insertQuery, newWorkspaceID, labID, lab.InitialCode)
+// RestoreStateVersion repõe uma versão antiga como estado atual do
+// workspace, registando-a como uma nova versão. Devolve (nil, nil) se a
+// versão não existir.
+func (r *sqlRepository) RestoreStateVersion(ctx context.Context, workspaceID string, version int) (*domain.StateVersion, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	selectQuery := `SELECT id, lab_id, user_code, state, updated_at, status FROM workspaces WHERE id = ?`
-	row := r.db.QueryRowContext(ctx, selectQuery, newWorkspaceID)
+	if err := lockWorkspaceRow(ctx, tx, workspaceID); err != nil {
+		return nil, err
+	}
+	var state []byte
+	err = tx.QueryRowContext(ctx,
+		`SELECT state FROM workspace_state_versions WHERE workspace_id = ? AND version = ?`,
+		workspaceID, version).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	var ws domain.Workspace
-	if err := row.Scan(
-		&ws.ID,
-		&ws.LabID,
-		&ws.UserCode,
-		&ws.State,
-		&ws.UpdatedAt,
-		&ws.Status,
-	); err != nil {
+	query := `UPDATE workspaces SET state = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, state, workspaceID); err != nil {
 		return nil, err
 	}
 
-	return &ws, nil
+	restored, err := insertStateVersion(ctx, tx, workspaceID, state, &version)
+	if err != nil {
+		return nil, err
+	}
+	return restored, tx.Commit()
 }
 
-func (r *sqlRepository) CreateLab(ctx context.Context, lab *domain.Lab) error {
-	query := `
-        INSERT INTO labs (id, title, type, instructions, initial_code, track_id, lab_order, validation_code)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := r.db.ExecContext(ctx, query,
-		lab.ID,
-		lab.Title,
-		lab.Type,
-		lab.Instructions,
-		lab.InitialCode,
-		lab.TrackID,
-		lab.LabOrder,
-		lab.ValidationCode,
-	)
-	return err
-}
+// GetWorkspaceState obtém o .tfstate atual.
+func (r *sqlRepository) GetWorkspaceState(ctx context.Context, workspaceID string) ([]byte, error) {
+	query := `SELECT state FROM workspaces WHERE id = ?`
 
-func (r *sqlRepository) CleanLab(ctx context.Context, labId string) error {
-	query := `
-		DELETE FROM labs WHERE id = ?
-	`
+	row := r.db.QueryRowContext(ctx, query, workspaceID)
 
-	_, err := r.db.ExecContext(ctx, query, labId)
-	return err
+	var state []byte
+	if err := row.Scan(&state); err != nil {
+		return nil, err
+	}
+	return state, nil
 }
 
-func (r *sqlRepository) UpdateWorkspaceStatus(ctx context.Context, workspaceId string, status string) error {
-	query := `
-		UPDATE workspaces SET status = ? WHERE id = ?
-	`
-	_, err := r.db.ExecContext(ctx, query, status, workspaceId)
-	return err
+// --- Métodos por implementar (para completar a interface) ---
+
+func (r *sqlRepository) ListLabs(ctx context.Context) ([]*domain.Lab, error) {
+	query := `SELECT ` + labColumns + ` FROM labs ORDER BY lab_order ASC`
+	return r.queryLabs(ctx, query)
 }
 
-func (r *sqlRepository) ListTracks(ctx context.Context) ([]*domain.Track, error) {
-	query := `SELECT id, title, description, created_at FROM tracks ORDER BY created_at ASC`
-	rows, err := r.db.QueryContext(ctx, query)
+func (r *sqlRepository) queryLabs(ctx context.Context, query string, args ...any) ([]*domain.Lab, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
-	var tracks []*domain.Track
+	var labs []*domain.Lab
 	for rows.Next() {
-		var track domain.Track
-		if err := rows.Scan(
-			&track.ID,
-			&track.Title,
-			&track.Description,
-			&track.CreatedAt,
-		); err != nil {
+		lab, err := scanLab(rows)
+		if err != nil {
 			return nil, err
 		}
-		tracks = append(tracks, &track)
+		labs = append(labs, lab)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return tracks, nil
+	return labs, nil
 }
 
-func (r *sqlRepository) ListLabsByTrackID(ctx context.Context, trackID string) ([]*domain.Lab, error) {
-	query := `SELECT id, title, type, instructions, initial_code, created_at,
-	                 track_id, lab_order, COALESCE(validation_code, '')
-	          FROM labs WHERE track_id = ? ORDER BY lab_order ASC`
-	rows, err := r.db.QueryContext(ctx, query, trackID)
+func (r *sqlRepository) UpdateWorkspaceCode(ctx context.Context, workspaceID string, code string) error {
+	query := `UPDATE workspaces SET user_code = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, code, workspaceID)
+	return err
+}
+// ListWorkspaceFiles devolve os ficheiros adicionais do workspace, com conteúdo.
+func (r *sqlRepository) ListWorkspaceFiles(ctx context.Context, workspaceID string) ([]*domain.WorkspaceFile, error) {
+	query := `SELECT path, content, updated_at FROM workspace_files WHERE workspace_id = ? ORDER BY path ASC`
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var labs []*domain.Lab
+	var files []*domain.WorkspaceFile
 	for rows.Next() {
-		var lab domain.Lab
-		if err := rows.Scan(
-			&lab.ID,
-			&lab.Title,
-			&lab.Type,
-			&lab.Instructions,
-			&lab.InitialCode,
-			&lab.CreatedAt,
-			&lab.TrackID,
-			&lab.LabOrder,
-			&lab.ValidationCode,
+		var f domain.WorkspaceFile
+		if err := rows.Scan(&f.Path, &f.Content, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		f.Size = len(f.Content)
+		files = append(files, &f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (r *sqlRepository) UpsertWorkspaceFile(ctx context.Context, workspaceID, path, content string) error {
+	query := `
+		INSERT INTO workspace_files (workspace_id, path, content, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (workspace_id, path) DO UPDATE SET
+			content = excluded.content,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := r.db.ExecContext(ctx, query, workspaceID, path, content)
+	return err
+}
+
+func (r *sqlRepository) DeleteWorkspaceFile(ctx context.Context, workspaceID, path string) error {
+	query := `DELETE FROM workspace_files WHERE workspace_id = ? AND path = ?`
+	_, err := r.db.ExecContext(ctx, query, workspaceID, path)
+	return err
+}
+
+// ReplaceWorkspaceFiles substitui, numa transação, todos os ficheiros
+// adicionais do workspace.
+func (r *sqlRepository) ReplaceWorkspaceFiles(ctx context.Context, workspaceID string, files map[string]string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM workspace_files WHERE workspace_id = ?`, workspaceID); err != nil {
+		return err
+	}
+	for path, content := range files {
+		query := `INSERT INTO workspace_files (workspace_id, path, content) VALUES (?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, workspaceID, path, content); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *sqlRepository) CreateWorkspace(ctx context.Context, userID, labID string) (*domain.Workspace, error) {
+	lab, err := r.GetLabByID(ctx, labID)
+	if err != nil {
+		return nil, err
+	}
+	if lab == nil {
+		return nil, sql.ErrNoRows // Ou um erro customizado "lab not found"
+	}
+
+	// Dois pedidos em simultâneo (ou duas réplicas) podem tentar criar o
+	// mesmo workspace: o segundo fica com o que o primeiro criou
+	newWorkspaceID := uuid.New().String()
+	insertQuery := `INSERT INTO workspaces (id, lab_id, user_id, user_code) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, lab_id) DO NOTHING`
+
+	_, err = r.db.ExecContext(ctx, insertQuery, newWorkspaceID, labID, userID, lab.InitialCode)
+	if err != nil {
+		return nil, err
+	}
+
+	selectQuery := `SELECT ` + workspaceColumns + ` FROM workspaces WHERE user_id = ? AND lab_id = ?`
+	row := r.db.QueryRowContext(ctx, selectQuery, userID, labID)
+
+	return scanWorkspace(row)
+}
+
+func (r *sqlRepository) CreateLab(ctx context.Context, lab *domain.Lab) error {
+	return insertLab(ctx, r.db, lab)
+}
+
+func insertLab(ctx context.Context, db execer, lab *domain.Lab) error {
+	limits, err := encodeLimits(lab.Limits)
+	if err != nil {
+		return err
+	}
+	image, err := encodeImage(lab.Image)
+	if err != nil {
+		return err
+	}
+	checks, err := encodeChecks(lab.Checks)
+	if err != nil {
+		return err
+	}
+	hints, err := encodeHints(lab.Hints)
+	if err != nil {
+		return err
+	}
+	prerequisites, err := encodePrerequisites(lab.Prerequisites)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO labs (id, title, type, instructions, initial_code, track_id, lab_order, validation_code, resource_limits, image, validation_checks, hints, prerequisites)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = db.ExecContext(ctx, query,
+		lab.ID,
+		lab.Title,
+		lab.Type,
+		lab.Instructions,
+		lab.InitialCode,
+		lab.TrackID,
+		lab.LabOrder,
+		lab.ValidationCode,
+		limits,
+		image,
+		checks,
+		hints,
+		prerequisites,
+	)
+	return err
+}
+
+func (r *sqlRepository) CleanLab(ctx context.Context, labId string) error {
+	query := `
+		DELETE FROM labs WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(ctx, query, labId)
+	return err
+}
+
+// UpdateWorkspaceReport guarda o relatório da última validação e a sua pontuação.
+func (r *sqlRepository) UpdateWorkspaceReport(ctx context.Context, workspaceID string, report *domain.ValidationReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE workspaces SET score = ?, max_score = ?, validation_report = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, report.Score, report.MaxScore, string(data), workspaceID)
+	return err
+}
+
+// ResetWorkspace repõe o workspace no estado inicial do lab, numa transação:
+// user_code volta a initialCode e o estado, a pontuação e os ficheiros
+// adicionais são apagados.
+func (r *sqlRepository) ResetWorkspace(ctx context.Context, workspaceID string, initialCode string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE workspaces SET user_code = ?, state = NULL, status = ?, score = 0, max_score = 0,
+	                 validation_report = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, initialCode, domain.WorkspaceStatusInProgress, workspaceID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM workspace_files WHERE workspace_id = ?`, workspaceID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const executionColumns = `id, workspace_id, user_id, action, outcome, exit_code, validation_exit_code,
+	                       validation_passed, error, started_at, finished_at`
+
+func scanExecution(row rowScanner, extra ...any) (*domain.ExecutionRecord, error) {
+	var rec domain.ExecutionRecord
+	var exitCode, validationExitCode sql.NullInt64
+	var validationPassed sql.NullBool
+	var finishedAt sql.NullTime
+	dest := []any{
+		&rec.ID,
+		&rec.WorkspaceID,
+		&rec.UserID,
+		&rec.Action,
+		&rec.Outcome,
+		&exitCode,
+		&validationExitCode,
+		&validationPassed,
+		&rec.Error,
+		&rec.StartedAt,
+		&finishedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	if exitCode.Valid {
+		code := int(exitCode.Int64)
+		rec.ExitCode = &code
+	}
+	if validationExitCode.Valid {
+		code := int(validationExitCode.Int64)
+		rec.ValidationExitCode = &code
+	}
+	if validationPassed.Valid {
+		rec.ValidationPassed = &validationPassed.Bool
+	}
+	if finishedAt.Valid {
+		rec.FinishedAt = &finishedAt.Time
+	}
+	return &rec, nil
+}
+
+func (r *sqlRepository) CreateExecution(ctx context.Context, rec *domain.ExecutionRecord) error {
+	query := `INSERT INTO executions (id, workspace_id, user_id, action, started_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, rec.ID, rec.WorkspaceID, rec.UserID, rec.Action, rec.StartedAt)
+	return err
+}
+
+// FinishExecution grava o desfecho e o output de uma execução terminada.
+func (r *sqlRepository) FinishExecution(ctx context.Context, rec *domain.ExecutionRecord) error {
+	query := `UPDATE executions SET outcome = ?, exit_code = ?, validation_exit_code = ?, validation_passed = ?,
+	                 error = ?, output = ?, finished_at = ? WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query,
+		rec.Outcome,
+		rec.ExitCode,
+		rec.ValidationExitCode,
+		rec.ValidationPassed,
+		rec.Error,
+		rec.Output,
+		rec.FinishedAt,
+		rec.ID,
+	)
+	return err
+}
+
+// ListExecutions devolve as execuções do workspace, da mais recente para a
+// mais antiga, sem o output.
+func (r *sqlRepository) ListExecutions(ctx context.Context, workspaceID string) ([]*domain.ExecutionRecord, error) {
+	query := `SELECT ` + executionColumns + ` FROM executions WHERE workspace_id = ? ORDER BY started_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*domain.ExecutionRecord
+	for rows.Next() {
+		rec, err := scanExecution(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// GetExecution devolve uma execução com o output. Devolve (nil, nil) se ela
+// não existir.
+func (r *sqlRepository) GetExecution(ctx context.Context, id string) (*domain.ExecutionRecord, error) {
+	query := `SELECT ` + executionColumns + `, output FROM executions WHERE id = ?`
+
+	var output string
+	rec, err := scanExecution(r.db.QueryRowContext(ctx, query, id), &output)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	rec.Output = output
+	return rec, nil
+}
+
+// CreateWorkspaceRevision grava o código submetido como a próxima revisão do
+// workspace, preenchendo rev.Revision.
+func (r *sqlRepository) CreateWorkspaceRevision(ctx context.Context, rev *domain.CodeRevision) error {
+	files, err := encodeFiles(rev.Files)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(revision), 0) + 1 FROM workspace_revisions WHERE workspace_id = ?`,
+		rev.WorkspaceID).Scan(&rev.Revision)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO workspace_revisions (workspace_id, revision, mode, outcome, code, files) VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, rev.WorkspaceID, rev.Revision, rev.Mode, rev.Outcome, rev.Code, files); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlRepository) UpdateRevisionOutcome(ctx context.Context, workspaceID string, revision int, outcome string) error {
+	query := `UPDATE workspace_revisions SET outcome = ? WHERE workspace_id = ? AND revision = ?`
+	_, err := r.db.ExecContext(ctx, query, outcome, workspaceID, revision)
+	return err
+}
+
+// ListWorkspaceRevisions devolve as revisões do workspace, da mais recente
+// para a mais antiga, sem o código.
+func (r *sqlRepository) ListWorkspaceRevisions(ctx context.Context, workspaceID string) ([]*domain.CodeRevision, error) {
+	query := `SELECT revision, mode, outcome, created_at FROM workspace_revisions WHERE workspace_id = ? ORDER BY revision DESC`
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*domain.CodeRevision
+	for rows.Next() {
+		rev := domain.CodeRevision{WorkspaceID: workspaceID}
+		if err := rows.Scan(&rev.Revision, &rev.Mode, &rev.Outcome, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetWorkspaceRevision devolve uma revisão com o código. Devolve (nil, nil)
+// se a revisão não existir.
+func (r *sqlRepository) GetWorkspaceRevision(ctx context.Context, workspaceID string, revision int) (*domain.CodeRevision, error) {
+	query := `SELECT revision, mode, outcome, code, COALESCE(files, ''), created_at
+	          FROM workspace_revisions WHERE workspace_id = ? AND revision = ?`
+
+	rev := domain.CodeRevision{WorkspaceID: workspaceID}
+	var files string
+	err := r.db.QueryRowContext(ctx, query, workspaceID, revision).Scan(
+		&rev.Revision, &rev.Mode, &rev.Outcome, &rev.Code, &files, &rev.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if files != "" {
+		if err := json.Unmarshal([]byte(files), &rev.Files); err != nil {
+			return nil, fmt.Errorf("files inválido na revisão %d do workspace %s: %w", revision, workspaceID, err)
+		}
+	}
+	return &rev, nil
+}
+
+// ListWorkspaceHints devolve as dicas reveladas no workspace, pela ordem de revelação.
+func (r *sqlRepository) ListWorkspaceHints(ctx context.Context, workspaceID string) ([]*domain.RevealedHint, error) {
+	query := `SELECT hint_index, penalty, revealed_at FROM workspace_hints WHERE workspace_id = ? ORDER BY revealed_at ASC, hint_index ASC`
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hints []*domain.RevealedHint
+	for rows.Next() {
+		var h domain.RevealedHint
+		if err := rows.Scan(&h.Index, &h.Penalty, &h.RevealedAt); err != nil {
+			return nil, err
+		}
+		hints = append(hints, &h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hints, nil
+}
+
+func (r *sqlRepository) CreateWorkspaceHint(ctx context.Context, workspaceID string, hint *domain.RevealedHint) error {
+	query := `INSERT INTO workspace_hints (workspace_id, hint_index, penalty) VALUES (?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, workspaceID, hint.Index, hint.Penalty)
+	return err
+}
+
+// ListCompletedLabIDs devolve os IDs dos labs que o utilizador já concluiu.
+func (r *sqlRepository) ListCompletedLabIDs(ctx context.Context, userID string) ([]string, error) {
+	query := `SELECT lab_id FROM workspaces WHERE user_id = ? AND status = ?`
+	rows, err := r.db.QueryContext(ctx, query, userID, domain.WorkspaceStatusCompleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *sqlRepository) UpdateWorkspaceStatus(ctx context.Context, workspaceId string, status string) error {
+	query := `
+		UPDATE workspaces SET status = ? WHERE id = ?
+	`
+	_, err := r.db.ExecContext(ctx, query, status, workspaceId)
+	return err
+}
+
+func (r *sqlRepository) ListTracks(ctx context.Context) ([]*domain.Track, error) {
+	query := `SELECT id, title, description, created_at, sequential FROM tracks ORDER BY created_at ASC`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []*domain.Track
+	for rows.Next() {
+		var track domain.Track
+		if err := rows.Scan(
+			&track.ID,
+			&track.Title,
+			&track.Description,
+			&track.CreatedAt,
+			&track.Sequential,
 		); err != nil {
 			return nil, err
 		}
-		labs = append(labs, &lab)
+		tracks = append(tracks, &track)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return labs, nil
+	return tracks, nil
+}
+
+func (r *sqlRepository) ListLabsByTrackID(ctx context.Context, trackID string) ([]*domain.Lab, error) {
+	query := `SELECT ` + labColumns + ` FROM labs WHERE track_id = ? ORDER BY lab_order ASC`
+	return r.queryLabs(ctx, query, trackID)
 }
 
 func (r *sqlRepository) GetTrackByID(ctx context.Context, id string) (*domain.Track, error) {
-	query := `SELECT id, title, description, created_at FROM tracks WHERE id = ?`
+	query := `SELECT id, title, description, created_at, sequential FROM tracks WHERE id = ?`
 	row := r.db.QueryRowContext(ctx, query, id)
 	var track domain.Track
 	if err := row.Scan(
@@ -308,31 +1059,65 @@ func (r *sqlRepository) GetTrackByID(ctx context.Context, id string) (*domain.Tr
 		&track.Title,
 		&track.Description,
 		&track.CreatedAt,
+		&track.Sequential,
 	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		return nil, err
 	}
 	return &track, nil
 }
 
 func (r *sqlRepository) CreateTrack(ctx context.Context, track *domain.Track) error {
+	return insertTrack(ctx, r.db, track)
+}
+
+func insertTrack(ctx context.Context, db execer, track *domain.Track) error {
 	query := `
-	INSERT INTO tracks (id, title, description)
-		VALUES (?, ?, ?)
+	INSERT INTO tracks (id, title, description, sequential)
+		VALUES (?, ?, ?, ?)
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := db.ExecContext(ctx, query,
 		track.ID,
 		track.Title,
 		track.Description,
+		track.Sequential,
 	)
 
 	return err
 }
 
 func (r *sqlRepository) UpdateLab(ctx context.Context, lab *domain.Lab) error {
+	return updateLab(ctx, r.db, lab)
+}
+
+func updateLab(ctx context.Context, db execer, lab *domain.Lab) error {
+	limits, err := encodeLimits(lab.Limits)
+	if err != nil {
+		return err
+	}
+	image, err := encodeImage(lab.Image)
+	if err != nil {
+		return err
+	}
+	checks, err := encodeChecks(lab.Checks)
+	if err != nil {
+		return err
+	}
+	hints, err := encodeHints(lab.Hints)
+	if err != nil {
+		return err
+	}
+	prerequisites, err := encodePrerequisites(lab.Prerequisites)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		UPDATE labs SET title = ?, type = ?, instructions = ?, initial_code = ?, track_id = ?, lab_order = ?, validation_code = ? WHERE id = ?
+		UPDATE labs SET title = ?, type = ?, instructions = ?, initial_code = ?, track_id = ?, lab_order = ?, validation_code = ?, resource_limits = ?, image = ?, validation_checks = ?, hints = ?, prerequisites = ? WHERE id = ?
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = db.ExecContext(ctx, query,
 		lab.Title,
 		lab.Type,
 		lab.Instructions,
@@ -340,6 +1125,11 @@ func (r *sqlRepository) UpdateLab(ctx context.Context, lab *domain.Lab) error {
 		lab.TrackID,
 		lab.LabOrder,
 		lab.ValidationCode,
+		limits,
+		image,
+		checks,
+		hints,
+		prerequisites,
 		lab.ID,
 	)
 	return err
@@ -352,12 +1142,17 @@ func (r *sqlRepository) DeleteLab(ctx context.Context, labID string) error {
 }
 
 func (r *sqlRepository) UpdateTrack(ctx context.Context, track *domain.Track) error {
+	return updateTrack(ctx, r.db, track)
+}
+
+func updateTrack(ctx context.Context, db execer, track *domain.Track) error {
 	query := `
-		UPDATE tracks SET title = ?, description = ? WHERE id = ?
+		UPDATE tracks SET title = ?, description = ?, sequential = ? WHERE id = ?
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := db.ExecContext(ctx, query,
 		track.Title,
 		track.Description,
+		track.Sequential,
 		track.ID,
 	)
 	return err
@@ -368,3 +1163,334 @@ func (r *sqlRepository) DeleteTrack(ctx context.Context, trackID string) error {
 	_, err := r.db.ExecContext(ctx, query, trackID)
 	return err
 }
+
+func (r *sqlRepository) CreateUser(ctx context.Context, user *domain.User) error {
+	query := `INSERT INTO users (id, name, email, role) VALUES (?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, user.ID, user.Name, user.Email, user.Role)
+	return err
+}
+
+func (r *sqlRepository) GetUserByID(ctx context.Context, userID string) (*domain.User, error) {
+	query := `SELECT id, name, email, role, created_at FROM users WHERE id = ?`
+	row := r.db.QueryRowContext(ctx, query, userID)
+
+	var user domain.User
+	if err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *sqlRepository) ListUsers(ctx context.Context) ([]*domain.User, error) {
+	query := `SELECT id, name, email, role, created_at FROM users ORDER BY created_at ASC`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *sqlRepository) UpdateUserRole(ctx context.Context, userID string, role string) error {
+	query := `UPDATE users SET role = ? WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, role, userID)
+	return err
+}
+
+func (r *sqlRepository) CreateAPIToken(ctx context.Context, token *domain.APIToken) error {
+	query := `INSERT INTO api_tokens (id, user_id, name, token_hash) VALUES (?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, token.ID, token.UserID, token.Name, token.TokenHash)
+	return err
+}
+
+// GetUserByTokenHash devolve o dono do token e regista a data de último uso.
+func (r *sqlRepository) GetUserByTokenHash(ctx context.Context, tokenHash string) (*domain.User, error) {
+	query := `SELECT u.id, u.name, u.email, u.role, u.created_at
+	          FROM api_tokens t JOIN users u ON u.id = t.user_id
+	          WHERE t.token_hash = ?`
+	row := r.db.QueryRowContext(ctx, query, tokenHash)
+
+	var user domain.User
+	if err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	touchQuery := `UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE token_hash = ?`
+	if _, err := r.db.ExecContext(ctx, touchQuery, tokenHash); err != nil {
+		log.Printf("AVISO [Repository]: Falha ao atualizar last_used_at do token: %v", err)
+	}
+
+	return &user, nil
+}
+
+func (r *sqlRepository) ListAPITokens(ctx context.Context) ([]*domain.APIToken, error) {
+	query := `SELECT id, user_id, name, token_hash, created_at, last_used_at FROM api_tokens ORDER BY created_at ASC`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*domain.APIToken
+	for rows.Next() {
+		var token domain.APIToken
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, &token.CreatedAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			token.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, &token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (r *sqlRepository) DeleteAPIToken(ctx context.Context, tokenID string) error {
+	query := `DELETE FROM api_tokens WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, tokenID)
+	return err
+}
+
+func (r *sqlRepository) ListImages(ctx context.Context) ([]*domain.ImageCatalogEntry, error) {
+	query := `SELECT exec_type, reference, digest, status, error, pulled_at, updated_at FROM images ORDER BY exec_type ASC`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []*domain.ImageCatalogEntry
+	for rows.Next() {
+		var img domain.ImageCatalogEntry
+		var pulledAt sql.NullTime
+		if err := rows.Scan(&img.Type, &img.Reference, &img.Digest, &img.Status, &img.Error, &pulledAt, &img.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if pulledAt.Valid {
+			img.PulledAt = &pulledAt.Time
+		}
+		images = append(images, &img)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (r *sqlRepository) UpsertImage(ctx context.Context, img *domain.ImageCatalogEntry) error {
+	query := `
+		INSERT INTO images (exec_type, reference, digest, status, error, pulled_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (exec_type) DO UPDATE SET
+			reference = excluded.reference,
+			digest = excluded.digest,
+			status = excluded.status,
+			error = excluded.error,
+			pulled_at = excluded.pulled_at,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		img.Type,
+		img.Reference,
+		img.Digest,
+		img.Status,
+		img.Error,
+		img.PulledAt,
+	)
+	return err
+}
+
+func (r *sqlRepository) ListAllowedImages(ctx context.Context) ([]*domain.AllowedImage, error) {
+	query := `SELECT id, pattern, created_at FROM allowed_images ORDER BY pattern ASC`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allowed []*domain.AllowedImage
+	for rows.Next() {
+		var a domain.AllowedImage
+		if err := rows.Scan(&a.ID, &a.Pattern, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		allowed = append(allowed, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return allowed, nil
+}
+
+func (r *sqlRepository) CreateAllowedImage(ctx context.Context, allowed *domain.AllowedImage) error {
+	query := `INSERT INTO allowed_images (id, pattern) VALUES (?, ?)`
+	_, err := r.db.ExecContext(ctx, query, allowed.ID, allowed.Pattern)
+	return err
+}
+
+func (r *sqlRepository) DeleteAllowedImage(ctx context.Context, id string) error {
+	query := `DELETE FROM allowed_images WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// catalogSyncColumns é a lista de colunas lida por scanCatalogSync.
+const catalogSyncColumns = `id, source, ref, commit_sha, track_ids, lab_ids, changes, synced_at`
+
+func scanCatalogSync(row rowScanner) (*domain.CatalogSync, error) {
+	var s domain.CatalogSync
+	var trackIDs, labIDs, changes string
+	if err := row.Scan(&s.ID, &s.Source, &s.Ref, &s.Commit, &trackIDs, &labIDs, &changes, &s.SyncedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(trackIDs), &s.TrackIDs); err != nil {
+		return nil, fmt.Errorf("track_ids inválido na sincronização %s: %w", s.ID, err)
+	}
+	if err := json.Unmarshal([]byte(labIDs), &s.LabIDs); err != nil {
+		return nil, fmt.Errorf("lab_ids inválido na sincronização %s: %w", s.ID, err)
+	}
+	if err := json.Unmarshal([]byte(changes), &s.Changes); err != nil {
+		return nil, fmt.Errorf("changes inválido na sincronização %s: %w", s.ID, err)
+	}
+	return &s, nil
+}
+
+// CreateCatalogSync regista uma sincronização do catálogo com o Git.
+func (r *sqlRepository) CreateCatalogSync(ctx context.Context, s *domain.CatalogSync) error {
+	return insertCatalogSync(ctx, r.db, s)
+}
+
+// ApplyCatalogWrites grava as trilhas e os labs de uma importação ou
+// sincronização e, se sync não for nil, o seu registo, tudo ou nada.
+func (r *sqlRepository) ApplyCatalogWrites(ctx context.Context, writes *domain.CatalogWrites, sync *domain.CatalogSync) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, track := range writes.CreateTracks {
+		if err := insertTrack(ctx, tx, track); err != nil {
+			return fmt.Errorf("falha ao criar a trilha %s: %w", track.ID, err)
+		}
+	}
+	for _, track := range writes.UpdateTracks {
+		if err := updateTrack(ctx, tx, track); err != nil {
+			return fmt.Errorf("falha ao atualizar a trilha %s: %w", track.ID, err)
+		}
+	}
+	for _, lab := range writes.CreateLabs {
+		if err := insertLab(ctx, tx, lab); err != nil {
+			return fmt.Errorf("falha ao criar o lab %s: %w", lab.ID, err)
+		}
+	}
+	for _, lab := range writes.UpdateLabs {
+		if err := updateLab(ctx, tx, lab); err != nil {
+			return fmt.Errorf("falha ao atualizar o lab %s: %w", lab.ID, err)
+		}
+	}
+	for _, id := range writes.DeleteLabIDs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM labs WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("falha ao apagar o lab %s: %w", id, err)
+		}
+	}
+	for _, id := range writes.DeleteTrackIDs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM tracks WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("falha ao apagar a trilha %s: %w", id, err)
+		}
+	}
+	if sync != nil {
+		if err := insertCatalogSync(ctx, tx, sync); err != nil {
+			return fmt.Errorf("falha ao registar a sincronização: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func insertCatalogSync(ctx context.Context, db execer, s *domain.CatalogSync) error {
+	trackIDs, err := json.Marshal(emptyIfNil(s.TrackIDs))
+	if err != nil {
+		return err
+	}
+	labIDs, err := json.Marshal(emptyIfNil(s.LabIDs))
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(emptyIfNil(s.Changes))
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO catalog_syncs (` + catalogSyncColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = db.ExecContext(ctx, query,
+		s.ID, s.Source, s.Ref, s.Commit, string(trackIDs), string(labIDs), string(changes), s.SyncedAt)
+	return err
+}
+
+// GetLastCatalogSync devolve a sincronização mais recente, ou nil se não houver.
+func (r *sqlRepository) GetLastCatalogSync(ctx context.Context) (*domain.CatalogSync, error) {
+	query := `SELECT ` + catalogSyncColumns + ` FROM catalog_syncs ORDER BY synced_at DESC LIMIT 1`
+	s, err := scanCatalogSync(r.db.QueryRowContext(ctx, query))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListCatalogSyncs lista as últimas limit sincronizações, da mais recente
+// para a mais antiga.
+func (r *sqlRepository) ListCatalogSyncs(ctx context.Context, limit int) ([]*domain.CatalogSync, error) {
+	query := `SELECT ` + catalogSyncColumns + ` FROM catalog_syncs ORDER BY synced_at DESC LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	syncs := []*domain.CatalogSync{}
+	for rows.Next() {
+		s, err := scanCatalogSync(rows)
+		if err != nil {
+			return nil, err
+		}
+		syncs = append(syncs, s)
+	}
+	return syncs, rows.Err()
+}
+
+func emptyIfNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidToken = errors.New("token inválido")
+
+// bootstrapTokenName é o nome do token criado a partir do ADMIN_TOKEN.
+const bootstrapTokenName = "bootstrap"
+
+type AuthService struct {
+	repo WorkspaceRepository
+}
+
+func NewAuthService(repo WorkspaceRepository) *AuthService {
+	return &AuthService{
+		repo: repo,
+	}
+}
+
+// Authenticate resolve o utilizador dono do token apresentado.
+func (s *AuthService) Authenticate(ctx context.Context, token string) (*domain.User, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.repo.GetUserByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("falha ao validar token: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+	return user, nil
+}
+
+// IssueToken emite um novo token para o utilizador. O valor em claro é
+// devolvido apenas aqui; a base de dados guarda somente o hash.
+func (s *AuthService) IssueToken(ctx context.Context, userID, name string) (string, *domain.APIToken, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", nil, fmt.Errorf("falha ao buscar utilizador %s: %w", userID, err)
+	}
+	if user == nil {
+		return "", nil, fmt.Errorf("utilizador com ID %s não encontrado", userID)
+	}
+
+	plain, err := generateToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("falha ao gerar token: %w", err)
+	}
+
+	if name == "" {
+		name = "default"
+	}
+
+	token := &domain.APIToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(plain),
+	}
+	if err := s.repo.CreateAPIToken(ctx, token); err != nil {
+		return "", nil, fmt.Errorf("falha ao guardar token: %w", err)
+	}
+
+	return plain, token, nil
+}
+
+func (s *AuthService) ListTokens(ctx context.Context) ([]*domain.APIToken, error) {
+	tokens, err := s.repo.ListAPITokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *AuthService) RevokeToken(ctx context.Context, tokenID string) error {
+	if err := s.repo.DeleteAPIToken(ctx, tokenID); err != nil {
+		return fmt.Errorf("falha ao revogar token %s: %w", tokenID, err)
+	}
+	return nil
+}
+
+// BootstrapAdmin garante que o token informado (ex: ADMIN_TOKEN) pertence a
+// um administrador, criando o utilizador admin na primeira inicialização.
+func (s *AuthService) BootstrapAdmin(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+
+	user, err := s.repo.GetUserByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("falha ao validar token de bootstrap: %w", err)
+	}
+	if user != nil {
+		if user.Role != domain.RoleAdmin {
+			return fmt.Errorf("token de bootstrap pertence ao utilizador %s sem papel de admin", user.ID)
+		}
+		return nil
+	}
+
+	// Reaproveita o primeiro administrador existente (ex: ADMIN_TOKEN foi rodado)
+	users, err := s.repo.ListUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao listar utilizadores: %w", err)
+	}
+	var admin *domain.User
+	for _, u := range users {
+		if u.Role == domain.RoleAdmin {
+			admin = u
+			break
+		}
+	}
+
+	if admin == nil {
+		admin = &domain.User{
+			ID:    uuid.New().String(),
+			Name:  "Administrador",
+			Email: "admin@lab-devops.local",
+			Role:  domain.RoleAdmin,
+		}
+		if err := s.repo.CreateUser(ctx, admin); err != nil {
+			return fmt.Errorf("falha ao criar administrador: %w", err)
+		}
+	}
+
+	// O ADMIN_TOKEN anterior deixa de ser válido quando é rodado
+	tokens, err := s.repo.ListAPITokens(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao listar tokens: %w", err)
+	}
+	for _, old := range tokens {
+		if old.UserID != admin.ID || old.Name != bootstrapTokenName {
+			continue
+		}
+		if err := s.repo.DeleteAPIToken(ctx, old.ID); err != nil {
+			return fmt.Errorf("falha ao revogar token de bootstrap %s: %w", old.ID, err)
+		}
+		log.Printf("INFO [Auth]: Token de bootstrap anterior %s revogado", old.ID)
+	}
+
+	if err := s.repo.CreateAPIToken(ctx, &domain.APIToken{
+		ID:        uuid.New().String(),
+		UserID:    admin.ID,
+		Name:      bootstrapTokenName,
+		TokenHash: hashToken(token),
+	}); err != nil {
+		return fmt.Errorf("falha ao guardar token de bootstrap: %w", err)
+	}
+
+	log.Printf("INFO [Auth]: Token de bootstrap registado para o administrador %s", admin.ID)
+	return nil
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"lab-devops/internal/service"
+)
+
+func TestBootstrapAdminRotatesToken(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	auth := service.NewAuthService(repo)
+
+	if err := auth.BootstrapAdmin(ctx, "antigo"); err != nil {
+		t.Fatal(err)
+	}
+	admin, err := auth.Authenticate(ctx, "antigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reiniciar com o mesmo token não o altera
+	if err := auth.BootstrapAdmin(ctx, "antigo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.BootstrapAdmin(ctx, "novo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := auth.Authenticate(ctx, "antigo"); !errors.Is(err, service.ErrInvalidToken) {
+		t.Errorf("token antigo: %v, esperava ErrInvalidToken", err)
+	}
+	user, err := auth.Authenticate(ctx, "novo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != admin.ID {
+		t.Errorf("token novo pertence a %s, esperava o administrador %s", user.ID, admin.ID)
+	}
+
+	tokens, err := auth.ListTokens(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 {
+		t.Errorf("%d tokens, esperava só o de bootstrap atual", len(tokens))
+	}
+}
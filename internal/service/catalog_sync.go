@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/labpack"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrCatalogSyncUnavailable indica que não há repositório Git configurado.
+	ErrCatalogSyncUnavailable = errors.New("sincronização do catálogo não configurada")
+	// ErrCatalogSyncBusy indica que já há uma sincronização em curso.
+	ErrCatalogSyncBusy = errors.New("já há uma sincronização do catálogo em curso")
+	// ErrCatalogRefNotFound indica um ref que não existe no repositório.
+	ErrCatalogRefNotFound = errors.New("ref não encontrado no repositório do catálogo")
+)
+
+// catalogSyncLock é o lock, partilhado pelas réplicas da API, da
+// sincronização do catálogo.
+const catalogSyncLock = "catalog-sync"
+
+// CatalogPlan é o que uma sincronização mudaria no catálogo.
+type CatalogPlan struct {
+	Source         string                 `json:"source"`
+	Ref            string                 `json:"ref"`
+	Commit         string                 `json:"commit"`
+	PreviousCommit string                 `json:"previous_commit,omitempty"`
+	Changes        []domain.CatalogChange `json:"changes"`
+}
+
+// CatalogService sincroniza as trilhas e os labs com os lab packs de um
+// repositório Git. O repositório é a fonte de verdade dos itens que define:
+// são criados, atualizados e, quando desaparecem dele, apagados. Trilhas e
+// labs criados pela API que nunca vieram do Git não são tocados.
+type CatalogService struct {
+	repo       WorkspaceRepository
+	labs       *LabService
+	source     CatalogSource
+	defaultRef string
+
+	// mu impede duas sincronizações em simultâneo nesta instância; as
+	// outras réplicas são travadas pelo lock catalogSyncLock na base
+	mu sync.Mutex
+}
+
+func NewCatalogService(repo WorkspaceRepository, labs *LabService, source CatalogSource, defaultRef string) *CatalogService {
+	return &CatalogService{repo: repo, labs: labs, source: source, defaultRef: defaultRef}
+}
+
+// catalogDiff é o plano de uma sincronização: as criações e atualizações
+// dos packs e os itens da sincronização anterior que já não existem no Git.
+type catalogDiff struct {
+	plan         *CatalogPlan
+	packs        *packPlan
+	trackIDs     []string
+	labIDs       []string
+	deleteTracks []*domain.Track
+	deleteLabs   []*domain.Lab
+}
+
+// Plan devolve o que a sincronização com ref mudaria, sem gravar nada. Um
+// ref vazio usa o ref padrão.
+func (s *CatalogService) Plan(ctx context.Context, ref string) (*CatalogPlan, error) {
+	diff, err := s.diff(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return diff.plan, nil
+}
+
+// Sync aplica ao catálogo o conteúdo do commit de ref e regista a
+// sincronização com o commit aplicado, numa única transação: se alguma
+// escrita falhar o catálogo fica como estava.
+func (s *CatalogService) Sync(ctx context.Context, ref string) (*domain.CatalogSync, error) {
+	if !s.mu.TryLock() {
+		return nil, ErrCatalogSyncBusy
+	}
+	defer s.mu.Unlock()
+
+	unlock, ok, err := s.repo.TryLock(ctx, catalogSyncLock)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCatalogSyncBusy
+	}
+	defer unlock()
+
+	diff, err := s.diff(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	writes := diff.packs.writes()
+	for _, lab := range diff.deleteLabs {
+		writes.DeleteLabIDs = append(writes.DeleteLabIDs, lab.ID)
+	}
+	for _, track := range diff.deleteTracks {
+		writes.DeleteTrackIDs = append(writes.DeleteTrackIDs, track.ID)
+	}
+
+	record := &domain.CatalogSync{
+		ID:       uuid.New().String(),
+		Source:   diff.plan.Source,
+		Ref:      diff.plan.Ref,
+		Commit:   diff.plan.Commit,
+		TrackIDs: diff.trackIDs,
+		LabIDs:   diff.labIDs,
+		Changes:  diff.plan.Changes,
+		SyncedAt: time.Now().UTC(),
+	}
+	if err := s.repo.ApplyCatalogWrites(ctx, writes, record); err != nil {
+		return nil, fmt.Errorf("falha ao aplicar a sincronização: %w", err)
+	}
+
+	log.Printf("INFO [Catalog]: Catálogo sincronizado com %s@%s (%s): %d alteração(ões)",
+		record.Source, record.Ref, shortCommit(record.Commit), len(record.Changes))
+	return record, nil
+}
+
+// ListSyncs lista as últimas sincronizações, da mais recente para a mais antiga.
+func (s *CatalogService) ListSyncs(ctx context.Context, limit int) ([]*domain.CatalogSync, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	return s.repo.ListCatalogSyncs(ctx, limit)
+}
+
+func (s *CatalogService) diff(ctx context.Context, ref string) (*catalogDiff, error) {
+	if s.source == nil {
+		return nil, ErrCatalogSyncUnavailable
+	}
+	if ref == "" {
+		ref = s.defaultRef
+	}
+
+	dir, err := os.MkdirTemp("", "catalog-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	commit, err := s.source.Checkout(ctx, ref, dir)
+	if err != nil {
+		return nil, err
+	}
+	packs, err := labpack.Load(os.DirFS(dir))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidLabPack, err)
+	}
+	previous, err := s.repo.GetLastCatalogSync(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler a última sincronização: %w", err)
+	}
+
+	// Os labs que desaparecem do Git já não servem de pré-requisito
+	var removedLabs []string
+	if previous != nil {
+		inPacks := make(map[string]bool)
+		for _, pack := range packs {
+			for _, lab := range pack.Labs {
+				inPacks[lab.ID] = true
+			}
+		}
+		for _, id := range previous.LabIDs {
+			if !inPacks[id] {
+				removedLabs = append(removedLabs, id)
+			}
+		}
+	}
+	plan, err := s.labs.planLabPacks(ctx, packs, removedLabs)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &catalogDiff{
+		plan: &CatalogPlan{
+			Source:  s.source.String(),
+			Ref:     ref,
+			Commit:  commit,
+			Changes: []domain.CatalogChange{},
+		},
+		packs:    plan,
+		trackIDs: []string{},
+		labIDs:   []string{},
+	}
+
+	inGit := make(map[string]bool)
+	for _, p := range plan.tracks {
+		diff.trackIDs = append(diff.trackIDs, p.track.ID)
+		inGit["track/"+p.track.ID] = true
+		if p.action != PackItemUnchanged {
+			diff.plan.Changes = append(diff.plan.Changes, domain.CatalogChange{Kind: "track", ID: p.track.ID, Title: p.track.Title, Action: p.action})
+		}
+	}
+	for _, p := range plan.labs {
+		diff.labIDs = append(diff.labIDs, p.lab.ID)
+		inGit["lab/"+p.lab.ID] = true
+		if p.action != PackItemUnchanged {
+			diff.plan.Changes = append(diff.plan.Changes, domain.CatalogChange{Kind: "lab", ID: p.lab.ID, Title: p.lab.Title, Action: p.action})
+		}
+	}
+
+	// Só se apaga o que veio do Git numa sincronização anterior
+	if previous != nil {
+		diff.plan.PreviousCommit = previous.Commit
+		for _, id := range previous.LabIDs {
+			if lab := plan.existingLabs[id]; lab != nil && !inGit["lab/"+id] {
+				diff.deleteLabs = append(diff.deleteLabs, lab)
+				diff.plan.Changes = append(diff.plan.Changes, domain.CatalogChange{Kind: "lab", ID: id, Title: lab.Title, Action: domain.CatalogChangeDeleted})
+			}
+		}
+		for _, id := range previous.TrackIDs {
+			if track := plan.existingTracks[id]; track != nil && !inGit["track/"+id] {
+				diff.deleteTracks = append(diff.deleteTracks, track)
+				diff.plan.Changes = append(diff.plan.Changes, domain.CatalogChange{Kind: "track", ID: id, Title: track.Title, Action: domain.CatalogChangeDeleted})
+			}
+		}
+	}
+	return diff, nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
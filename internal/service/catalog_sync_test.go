@@ -0,0 +1,66 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+func TestSyncRejectsDeletedPrerequisites(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	labs := service.NewLabService(repo, fakeExecutor{}, service.NewExecutionScheduler(0, 0, 0))
+	source := &fakeCatalog{commit: "c1", files: map[string]string{
+		"base/lab.yaml":     "title: Base\ntype: linux\n",
+		"avancado/lab.yaml": "title: Avançado\ntype: linux\nprerequisites: [base]\n",
+	}}
+	catalog := service.NewCatalogService(repo, labs, source, "main")
+
+	if _, err := catalog.Sync(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// O lab que continua no Git depende do que saiu
+	source.commit = "c2"
+	delete(source.files, "base/lab.yaml")
+	if _, err := catalog.Plan(ctx, ""); !errors.Is(err, service.ErrInvalidPrerequisites) {
+		t.Fatalf("Plan: %v, esperava ErrInvalidPrerequisites", err)
+	}
+
+	// Um lab criado pela API também conta
+	source.files["avancado/lab.yaml"] = "title: Avançado\ntype: linux\n"
+	if _, err := labs.CreateLab(ctx, "Extra", "linux", "", "", "", 0, "", domain.ResourceLimits{}, nil, nil, nil, []string{"base"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := catalog.Sync(ctx, ""); !errors.Is(err, service.ErrInvalidPrerequisites) {
+		t.Fatalf("Sync: %v, esperava ErrInvalidPrerequisites", err)
+	}
+
+	if base, err := repo.GetLabByID(ctx, "base"); err != nil || base == nil {
+		t.Fatalf("o lab base devia continuar no catálogo: %v, %v", base, err)
+	}
+	if last, err := repo.GetLastCatalogSync(ctx); err != nil || last == nil || last.Commit != "c1" {
+		t.Fatalf("última sincronização: %+v, %v", last, err)
+	}
+}
+
+func TestSyncBusyOnAnotherReplica(t *testing.T) {
+	ctx := context.Background()
+	locks := &sharedLocks{WorkspaceRepository: newTestRepo(t)}
+	labs := service.NewLabService(locks, fakeExecutor{}, service.NewExecutionScheduler(0, 0, 0))
+	source := &fakeCatalog{commit: "c1", files: map[string]string{"base/lab.yaml": "title: Base\ntype: linux\n"}}
+	catalog := service.NewCatalogService(locks, labs, source, "main")
+
+	// Outra réplica está a sincronizar
+	unlock, _, _ := locks.TryLock(ctx, "catalog-sync")
+	if _, err := catalog.Sync(ctx, ""); !errors.Is(err, service.ErrCatalogSyncBusy) {
+		t.Fatalf("Sync: %v, esperava ErrCatalogSyncBusy", err)
+	}
+	unlock()
+	if _, err := catalog.Sync(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+}
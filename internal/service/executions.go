@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrExecutionNotFound indica que a execução ou o workspace pedido não existe.
+	ErrExecutionNotFound = errors.New("execução não encontrada")
+	// ErrForbidden indica que o utilizador não pode consultar o workspace de outro.
+	ErrForbidden = errors.New("permissão insuficiente")
+)
+
+// outputBuffer acumula o output de uma execução até domain.MaxExecutionOutput.
+type outputBuffer struct {
+	b         strings.Builder
+	truncated bool
+}
+
+func (o *outputBuffer) add(line string) {
+	if o.truncated {
+		return
+	}
+	if o.b.Len()+len(line)+1 > domain.MaxExecutionOutput {
+		o.truncated = true
+		o.b.WriteString("... (output truncado)\n")
+		return
+	}
+	o.b.WriteString(line)
+	o.b.WriteByte('\n')
+}
+
+func (o *outputBuffer) String() string {
+	return o.b.String()
+}
+
+// startExecution regista o início de uma execução. Uma falha ao gravar não
+// impede a execução: devolve nil e o registo é ignorado.
+func (s *LabService) startExecution(ctx context.Context, id, userID, action string, config domain.ExecutionConfig) *domain.ExecutionRecord {
+	rec := &domain.ExecutionRecord{
+		ID:          id,
+		WorkspaceID: config.WorkspaceID,
+		UserID:      userID,
+		Action:      action,
+		StartedAt:   time.Now(),
+	}
+	if err := s.repo.CreateExecution(ctx, rec); err != nil {
+		log.Printf("ERRO [LabService]: Falha ao registar execução do workspace %s: %v", config.WorkspaceID, err)
+		return nil
+	}
+	return rec
+}
+
+// finishExecution grava o desfecho, os códigos de saída e o output.
+func (s *LabService) finishExecution(ctx context.Context, rec *domain.ExecutionRecord, state ExecutionFinalState, output string) {
+	if rec == nil {
+		return
+	}
+
+	now := time.Now()
+	exitCode := state.ExecutionResult.ExitCode
+	rec.Outcome = state.Outcome
+	rec.ExitCode = &exitCode
+	rec.Output = output
+	rec.FinishedAt = &now
+	if state.Error != nil {
+		rec.Error = state.Error.Error()
+	}
+	if state.Validated {
+		validationExitCode := state.ValidationResult.ExitCode
+		passed := state.ValidationPassed()
+		rec.ValidationExitCode = &validationExitCode
+		rec.ValidationPassed = &passed
+	}
+
+	// O registo é gravado mesmo que o cliente já tenha saído
+	if err := s.repo.FinishExecution(context.WithoutCancel(ctx), rec); err != nil {
+		log.Printf("ERRO [LabService]: Falha ao gravar execução %s: %v", rec.ID, err)
+	}
+}
+
+// ListExecutions devolve o histórico de execuções de um workspace. Só o dono
+// do workspace, autores e administradores o podem consultar.
+func (s *LabService) ListExecutions(ctx context.Context, viewer *domain.User, workspaceID string) ([]*domain.ExecutionRecord, error) {
+	if err := s.canView(ctx, viewer, workspaceID); err != nil {
+		return nil, err
+	}
+
+	records, err := s.repo.ListExecutions(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar execuções do workspace %s: %w", workspaceID, err)
+	}
+	if records == nil {
+		records = []*domain.ExecutionRecord{}
+	}
+	return records, nil
+}
+
+// GetExecution devolve uma execução com o output completo.
+func (s *LabService) GetExecution(ctx context.Context, viewer *domain.User, id string) (*domain.ExecutionRecord, error) {
+	rec, err := s.repo.GetExecution(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar execução %s: %w", id, err)
+	}
+	if rec == nil {
+		return nil, ErrExecutionNotFound
+	}
+	if err := s.canView(ctx, viewer, rec.WorkspaceID); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *LabService) canView(ctx context.Context, viewer *domain.User, workspaceID string) error {
+	ws, err := s.repo.GetWorkspaceByID(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("falha ao buscar workspace %s: %w", workspaceID, err)
+	}
+	if ws == nil {
+		return ErrExecutionNotFound
+	}
+	if ws.UserID != viewer.ID && !viewer.HasRole(domain.RoleAdmin, domain.RoleAuthor) {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+func TestExecutionHistory(t *testing.T) {
+	ctx := context.Background()
+	executor := logExecutor{lines: []string{"a iniciar", "concluído"}}
+	repo, labs, owner := newTestLabs(t, executor, service.NewExecutionScheduler(4, 1, 0))
+	lab := createTestLab(t, labs, "Linux", "linux")
+
+	run, err := labs.ExecuteLab(ctx, owner.ID, lab.ID, "echo ok", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFinal(t, run)
+
+	other := &domain.User{ID: "u2", Name: "Outro", Email: "outro@lab.local", Role: domain.RoleLearner}
+	author := &domain.User{ID: "u3", Name: "Autor", Email: "autor@lab.local", Role: domain.RoleAuthor}
+	for _, u := range []*domain.User{other, author} {
+		if err := repo.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := repo.GetWorkspaceByUserAndLab(ctx, owner.ID, lab.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		viewer      *domain.User
+		workspaceID string
+		wantErr     error
+	}{
+		{"dono do workspace", owner, ws.ID, nil},
+		{"autor", author, ws.ID, nil},
+		{"outro aprendiz", other, ws.ID, service.ErrForbidden},
+		{"workspace inexistente", owner, "nao-existe", service.ErrExecutionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := labs.ListExecutions(ctx, tt.viewer, tt.workspaceID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ListExecutions: erro %v, esperava %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if len(records) != 1 || records[0].ID != run.ID || records[0].Outcome != domain.OutcomeSuccess {
+				t.Fatalf("histórico: %+v", records)
+			}
+
+			rec, err := labs.GetExecution(ctx, tt.viewer, run.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec.Output != "a iniciar\nconcluído\n" || rec.Action != domain.ActionExecute || rec.FinishedAt == nil {
+				t.Fatalf("execução gravada: %+v, output %q", rec, rec.Output)
+			}
+		})
+	}
+}
+
+func TestAttachExecution(t *testing.T) {
+	executor := &stateExecutor{gate: make(chan struct{})}
+	repo, labs, owner := newTestLabs(t, executor, service.NewExecutionScheduler(4, 1, 0))
+	lab := createTestLab(t, labs, "Rede", "terraform")
+	other := &domain.User{ID: "u2", Name: "Outro", Email: "outro@lab.local", Role: domain.RoleLearner}
+	if err := repo.CreateUser(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+
+	// A execução não depende da conexão que a pediu
+	reqCtx, disconnect := context.WithCancel(context.Background())
+	run, err := labs.ExecuteLab(reqCtx, owner.ID, lab.ID, "", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	disconnect()
+
+	tests := []struct {
+		name    string
+		viewer  *domain.User
+		id      string
+		wantErr error
+	}{
+		{"dono reconecta", owner, run.ID, nil},
+		{"outro aprendiz", other, run.ID, service.ErrForbidden},
+		{"execução desconhecida", owner, "nao-existe", service.ErrExecutionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := labs.AttachExecution(context.Background(), tt.viewer, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AttachExecution: erro %v, esperava %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (got != run || !got.Running()) {
+				t.Fatalf("AttachExecution devolveu %+v, esperava a execução em curso", got)
+			}
+		})
+	}
+
+	close(executor.gate)
+	if final := waitFinal(t, run); final.Outcome != domain.OutcomeSuccess {
+		t.Fatalf("execução terminou com %q depois de o cliente sair", final.Outcome)
+	}
+}
+
+func TestLiveExecutionSince(t *testing.T) {
+	_, labs, user := newTestLabs(t, logExecutor{lines: []string{"um", "dois", "três"}}, service.NewExecutionScheduler(4, 1, 0))
+	lab := createTestLab(t, labs, "Linux", "linux")
+	run, err := labs.ExecuteLab(context.Background(), user.ID, lab.ID, "echo ok", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFinal(t, run)
+
+	tests := []struct {
+		offset int
+		want   []string
+	}{
+		{0, []string{"um", "dois", "três"}},
+		{2, []string{"três"}},
+		{3, nil},
+		{10, nil},
+	}
+
+	for _, tt := range tests {
+		lines, _, final := run.Since(tt.offset)
+		var got []string
+		for _, l := range lines {
+			if l.Line != "" {
+				got = append(got, l.Line)
+			}
+		}
+		if !slices.Equal(got, tt.want) || final == nil {
+			t.Errorf("Since(%d) = %q (final %v), esperava %q", tt.offset, got, final, tt.want)
+		}
+	}
+}
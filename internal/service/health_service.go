@@ -17,16 +17,20 @@ const (
 type HealthCheckResponse struct {
 	Status    HealthStatus      `json:"status"`
 	Checks    map[string]string `json:"checks"`
+	Pools     []PoolStats       `json:"pools,omitempty"`
 	Timestamp time.Time         `json:"timestamp"`
 }
 
 type HealthService struct {
-	repo WorkspaceRepository
+	repo  WorkspaceRepository
+	pools PoolStatsProvider
 }
 
-func NewHealthService(repo WorkspaceRepository) *HealthService {
+// NewHealthService cria o serviço de health check. pools é opcional.
+func NewHealthService(repo WorkspaceRepository, pools PoolStatsProvider) *HealthService {
 	return &HealthService{
-		repo: repo,
+		repo:  repo,
+		pools: pools,
 	}
 }
 
@@ -55,9 +59,15 @@ func (s *HealthService) CheckHealth(ctx context.Context) HealthCheckResponse {
 		checks["disk"] = "ok"
 	}
 
+	var pools []PoolStats
+	if s.pools != nil {
+		pools = s.pools.PoolStats()
+	}
+
 	return HealthCheckResponse{
 		Status:    aggregatedStatus,
 		Checks:    checks,
+		Pools:     pools,
 		Timestamp: time.Now(),
 	}
 }
@@ -0,0 +1,316 @@
+package service_test
+
+// Dobles e fixtures partilhados pelos testes do serviço. Vivem no pacote
+// externo porque o repositório SQLite importa o pacote service.
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/repository"
+	"lab-devops/internal/service"
+)
+
+// newTestRepo abre um repositório SQLite vazio num diretório temporário.
+func newTestRepo(t *testing.T) service.WorkspaceRepository {
+	t.Helper()
+	repo, err := repository.NewSQLiteRepository(filepath.Join(t.TempDir(), "lab.db"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+// newTestLabs devolve um LabService sobre um repositório novo, com um
+// aprendiz já registado.
+func newTestLabs(t *testing.T, executor service.Executor, scheduler *service.ExecutionScheduler) (service.WorkspaceRepository, *service.LabService, *domain.User) {
+	t.Helper()
+	repo := newTestRepo(t)
+	user := &domain.User{ID: "u1", Name: "Aluno", Email: "aluno@lab.local", Role: domain.RoleLearner}
+	if err := repo.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return repo, service.NewLabService(repo, executor, scheduler), user
+}
+
+// createTestLab cria um lab sem código inicial nem validação.
+func createTestLab(t *testing.T, labs *service.LabService, title, labType string) *domain.Lab {
+	t.Helper()
+	lab, err := labs.CreateLab(context.Background(), title, labType, "", "", "", 0, "", domain.ResourceLimits{}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lab
+}
+
+// waitFinal aguarda o fim da execução e devolve o seu estado final.
+func waitFinal(t *testing.T, run *service.LiveExecution) service.ExecutionFinalState {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		_, changed, final := run.Since(0)
+		if final != nil {
+			return *final
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatal("a execução não terminou")
+		}
+	}
+}
+
+// fakeExecutor termina cada execução de imediato e abre terminais que
+// ficam à espera do utilizador.
+type fakeExecutor struct{}
+
+func (fakeExecutor) Execute(ctx context.Context, config domain.ExecutionConfig) (<-chan service.ExecutionResult, <-chan service.ExecutionFinalState, error) {
+	logs := make(chan service.ExecutionResult)
+	final := make(chan service.ExecutionFinalState, 1)
+	final <- service.ExecutionFinalState{WorkspaceID: config.WorkspaceID, Outcome: domain.OutcomeSuccess}
+	close(logs)
+	close(final)
+	return logs, final, nil
+}
+
+func (fakeExecutor) OpenTerminal(ctx context.Context, config domain.ExecutionConfig) (service.Terminal, error) {
+	return &fakeTerminal{}, nil
+}
+
+type fakeTerminal struct {
+	fakeExecutor
+	closed atomic.Bool
+}
+
+func (*fakeTerminal) Read(p []byte) (int, error)   { return 0, io.EOF }
+func (*fakeTerminal) Write(p []byte) (int, error)  { return len(p), nil }
+func (*fakeTerminal) Resize(cols, rows uint) error { return nil }
+func (f *fakeTerminal) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+// logExecutor publica as linhas dadas e termina com sucesso.
+type logExecutor struct {
+	fakeExecutor
+	lines []string
+}
+
+func (e logExecutor) Execute(ctx context.Context, config domain.ExecutionConfig) (<-chan service.ExecutionResult, <-chan service.ExecutionFinalState, error) {
+	logs := make(chan service.ExecutionResult)
+	final := make(chan service.ExecutionFinalState, 1)
+	go func() {
+		defer close(logs)
+		defer close(final)
+		for _, line := range e.lines {
+			logs <- service.ExecutionResult{Line: line}
+		}
+		final <- service.ExecutionFinalState{WorkspaceID: config.WorkspaceID, Outcome: domain.OutcomeSuccess}
+	}()
+	return logs, final, nil
+}
+
+// blockingExecutor corre cada execução até ao cancelamento, como o executor
+// Docker quando o contexto é cancelado a meio de um passo.
+type blockingExecutor struct{ fakeExecutor }
+
+func (blockingExecutor) Execute(ctx context.Context, config domain.ExecutionConfig) (<-chan service.ExecutionResult, <-chan service.ExecutionFinalState, error) {
+	logs := make(chan service.ExecutionResult)
+	final := make(chan service.ExecutionFinalState, 1)
+	go func() {
+		defer close(logs)
+		defer close(final)
+		<-ctx.Done()
+		final <- service.ExecutionFinalState{WorkspaceID: config.WorkspaceID, Error: ctx.Err(), Outcome: domain.OutcomeCancelled}
+	}()
+	return logs, final, nil
+}
+
+// fakeTerminals guarda os terminais abertos, para os testes os inspecionarem.
+type fakeTerminals struct {
+	fakeExecutor
+	mu     sync.Mutex
+	opened []*fakeTerminal
+}
+
+func (f *fakeTerminals) OpenTerminal(ctx context.Context, config domain.ExecutionConfig) (service.Terminal, error) {
+	term := &fakeTerminal{}
+	f.mu.Lock()
+	f.opened = append(f.opened, term)
+	f.mu.Unlock()
+	return term, nil
+}
+
+func (f *fakeTerminals) last() *fakeTerminal {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.opened[len(f.opened)-1]
+}
+
+// fakeSessions arranca sessões devagar, para que os pedidos se sobreponham,
+// e guarda a configuração de cada passo corrido nelas.
+type fakeSessions struct {
+	fakeExecutor
+	started atomic.Int32
+
+	mu    sync.Mutex
+	steps []domain.ExecutionConfig
+}
+
+func (f *fakeSessions) StartSession(ctx context.Context, config domain.ExecutionConfig) (service.Session, error) {
+	f.started.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return fakeSession{provider: f}, nil
+}
+
+func (f *fakeSessions) lastStep() domain.ExecutionConfig {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.steps[len(f.steps)-1]
+}
+
+type fakeSession struct {
+	fakeExecutor
+	provider *fakeSessions
+}
+
+func (s fakeSession) Execute(ctx context.Context, config domain.ExecutionConfig) (<-chan service.ExecutionResult, <-chan service.ExecutionFinalState, error) {
+	s.provider.mu.Lock()
+	s.provider.steps = append(s.provider.steps, config)
+	s.provider.mu.Unlock()
+	return s.fakeExecutor.Execute(ctx, config)
+}
+
+func (fakeSession) Close() error { return nil }
+func (fakeSession) OpenShell(ctx context.Context, config domain.ExecutionConfig) (service.Terminal, error) {
+	return &fakeTerminal{}, nil
+}
+
+// fakeCatalog é um repositório Git cujo único commit tem os ficheiros de files.
+type fakeCatalog struct {
+	commit string
+	files  map[string]string
+}
+
+func (f *fakeCatalog) Checkout(ctx context.Context, ref, dir string) (string, error) {
+	for name, content := range f.files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return "", err
+		}
+	}
+	return f.commit, nil
+}
+
+func (f *fakeCatalog) String() string { return "fake" }
+
+// stateExecutor acrescenta um "+" ao estado recebido em cada execução e
+// guarda a configuração com que foi chamado. Enquanto gate estiver aberto,
+// as execuções aguardam que seja fechado.
+type stateExecutor struct {
+	fakeExecutor
+	gate chan struct{}
+
+	mu      sync.Mutex
+	configs []domain.ExecutionConfig
+}
+
+func (e *stateExecutor) Execute(ctx context.Context, config domain.ExecutionConfig) (<-chan service.ExecutionResult, <-chan service.ExecutionFinalState, error) {
+	e.mu.Lock()
+	e.configs = append(e.configs, config)
+	e.mu.Unlock()
+
+	logs := make(chan service.ExecutionResult)
+	final := make(chan service.ExecutionFinalState, 1)
+	go func() {
+		defer close(logs)
+		defer close(final)
+		if e.gate != nil {
+			<-e.gate
+		}
+		final <- service.ExecutionFinalState{
+			WorkspaceID: config.WorkspaceID,
+			NewState:    append(config.State, '+'),
+			Outcome:     domain.OutcomeSuccess,
+		}
+	}()
+	return logs, final, nil
+}
+
+// received devolve os estados com que o executor foi chamado.
+func (e *stateExecutor) received() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var states []string
+	for _, config := range e.configs {
+		states = append(states, string(config.State))
+	}
+	return states
+}
+
+func (e *stateExecutor) last() domain.ExecutionConfig {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.configs[len(e.configs)-1]
+}
+
+// sharedLocks dá ao repositório SQLite locks que excluem de facto, como os
+// do PostgreSQL, para testar várias réplicas da API sobre a mesma base.
+type sharedLocks struct {
+	service.WorkspaceRepository
+
+	mu   sync.Mutex
+	held map[string]chan struct{}
+}
+
+func (l *sharedLocks) Lock(ctx context.Context, name string) (func(), error) {
+	for {
+		unlock, ok, busy := l.tryLock(name)
+		if ok {
+			return unlock, nil
+		}
+		select {
+		case <-busy:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *sharedLocks) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	unlock, ok, _ := l.tryLock(name)
+	return unlock, ok, nil
+}
+
+// tryLock obtém o lock ou devolve um canal fechado quando ele for libertado.
+func (l *sharedLocks) tryLock(name string) (unlock func(), ok bool, busy <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ch, held := l.held[name]; held {
+		return nil, false, ch
+	}
+	if l.held == nil {
+		l.held = make(map[string]chan struct{})
+	}
+	ch := make(chan struct{})
+	l.held[name] = ch
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.held, name)
+			l.mu.Unlock()
+			close(ch)
+		})
+	}, true, nil
+}
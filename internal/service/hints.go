@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"time"
+)
+
+// ErrNoMoreHints indica que não há mais dicas disponíveis para o workspace.
+var ErrNoMoreHints = errors.New("não há mais dicas disponíveis")
+
+// HintsSummary resume as dicas de um workspace: as já reveladas (com texto),
+// o total do lab e quantas ainda estão por revelar.
+type HintsSummary struct {
+	Revealed  []*domain.RevealedHint `json:"revealed"`
+	Total     int                    `json:"total"`
+	Remaining int                    `json:"remaining"`
+	Penalty   int                    `json:"penalty"`
+}
+
+// RevealNextHint revela a próxima dica do lab para o utilizador. As dicas
+// são percorridas pela ordem do lab; uma dica ligada a uma verificação só
+// fica disponível quando essa verificação falhou na última validação.
+func (s *LabService) RevealNextHint(ctx context.Context, userID, labID string) (*domain.RevealedHint, error) {
+	lab, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+
+	revealed, err := s.repo.ListWorkspaceHints(ctx, ws.ID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar dicas do workspace %s: %w", ws.ID, err)
+	}
+	seen := make(map[int]bool, len(revealed))
+	for _, h := range revealed {
+		seen[h.Index] = true
+	}
+
+	var failed map[string]bool
+	if ws.Report != nil {
+		failed = ws.Report.FailedChecks()
+	}
+
+	for i, h := range lab.Hints {
+		if seen[i] || (h.Check != "" && !failed[h.Check]) {
+			continue
+		}
+		hint := &domain.RevealedHint{Index: i, Penalty: h.Penalty, RevealedAt: time.Now()}
+		if err := s.repo.CreateWorkspaceHint(ctx, ws.ID, hint); err != nil {
+			return nil, fmt.Errorf("falha ao registar dica do workspace %s: %w", ws.ID, err)
+		}
+		fillHint(hint, lab)
+		return hint, nil
+	}
+
+	return nil, ErrNoMoreHints
+}
+
+// ListRevealedHints devolve as dicas já reveladas no workspace do utilizador.
+func (s *LabService) ListRevealedHints(ctx context.Context, userID, labID string) (*HintsSummary, error) {
+	lab, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+
+	revealed, err := s.repo.ListWorkspaceHints(ctx, ws.ID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar dicas do workspace %s: %w", ws.ID, err)
+	}
+
+	summary := &HintsSummary{Revealed: []*domain.RevealedHint{}, Total: len(lab.Hints)}
+	for _, h := range revealed {
+		fillHint(h, lab)
+		summary.Revealed = append(summary.Revealed, h)
+		summary.Penalty += h.Penalty
+	}
+	summary.Remaining = summary.Total - len(revealed)
+	if summary.Remaining < 0 {
+		summary.Remaining = 0
+	}
+	return summary, nil
+}
+
+// hintPenalty soma a penalização das dicas reveladas no workspace.
+func (s *LabService) hintPenalty(ctx context.Context, workspaceID string) (int, error) {
+	revealed, err := s.repo.ListWorkspaceHints(ctx, workspaceID)
+	if err != nil {
+		return 0, err
+	}
+	penalty := 0
+	for _, h := range revealed {
+		penalty += h.Penalty
+	}
+	return penalty, nil
+}
+
+func (s *LabService) labAndWorkspace(ctx context.Context, userID, labID string) (*domain.Lab, *domain.Workspace, error) {
+	lab, err := s.repo.GetLabByID(ctx, labID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lab == nil {
+		return nil, nil, fmt.Errorf("lab não encontrado")
+	}
+
+	ws, err := s.workspaceFor(ctx, userID, labID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lab, ws, nil
+}
+
+// fillHint completa uma dica revelada com o texto atual do lab. Dicas
+// entretanto removidas pelo autor ficam sem texto.
+func fillHint(h *domain.RevealedHint, lab *domain.Lab) {
+	if h.Index < 0 || h.Index >= len(lab.Hints) {
+		return
+	}
+	h.Text = lab.Hints[h.Index].Text
+	h.Check = lab.Hints[h.Index].Check
+}
@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+func TestHintPenaltyOnValidation(t *testing.T) {
+	checks := []domain.ValidationCheck{
+		{Name: "bucket", Command: "true", Weight: 3},
+		{Name: "fila", Command: "true"},
+	}
+	hints := []domain.Hint{
+		{Text: "Comece pelo bucket", Penalty: 1},
+		{Text: "Falta a fila", Check: "fila", Penalty: 2},
+		{Text: "Veja o nome do bucket", Check: "bucket", Penalty: 2},
+	}
+
+	tests := []struct {
+		name        string
+		failed      string // verificação que falhou na primeira validação
+		reveal      int
+		wantScore   int
+		wantPenalty int
+	}{
+		{"sem dicas", "fila", 0, 4, 0},
+		{"dica geral", "fila", 1, 3, 1},
+		{"dica da verificação que falhou", "fila", 2, 1, 3},
+		{"dica de verificação que passou fica escondida", "fila", 3, 1, 3},
+		{"todas as dicas", "bucket", 2, 1, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo, labs, user := newTestLabs(t, fakeExecutor{}, service.NewExecutionScheduler(4, 1, 0))
+			lab, err := labs.CreateLab(ctx, "Fila", "terraform", "", "", "", 0, "", domain.ResourceLimits{}, nil, checks, hints, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ws, err := repo.CreateWorkspace(ctx, user.ID, lab.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			first := domain.NewValidationReport([]domain.CheckResult{
+				{Name: "bucket", Passed: tt.failed != "bucket", Weight: 3},
+				{Name: "fila", Passed: tt.failed != "fila", Weight: 1},
+			})
+			if err := labs.SaveValidationReport(ctx, ws.ID, first); err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.reveal {
+				_, err := labs.RevealNextHint(ctx, user.ID, lab.ID)
+				if i < 2 && err != nil {
+					t.Fatalf("dica %d: %v", i+1, err)
+				}
+				if i == 2 && !errors.Is(err, service.ErrNoMoreHints) {
+					t.Fatalf("dica ligada a uma verificação que passou: erro %v, esperava ErrNoMoreHints", err)
+				}
+			}
+
+			passing := domain.NewValidationReport([]domain.CheckResult{
+				{Name: "bucket", Passed: true, Weight: 3},
+				{Name: "fila", Passed: true, Weight: 1},
+			})
+			if err := labs.SaveValidationReport(ctx, ws.ID, passing); err != nil {
+				t.Fatal(err)
+			}
+			if passing.Score != tt.wantScore || passing.HintPenalty != tt.wantPenalty {
+				t.Errorf("pontuação %d com penalização %d, esperava %d e %d", passing.Score, passing.HintPenalty, tt.wantScore, tt.wantPenalty)
+			}
+		})
+	}
+}
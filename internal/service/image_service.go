@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"lab-devops/internal/domain"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImageService mantém o catálogo de imagens (tipo de lab → referência
+// fixada por digest), persistido na base de dados, e sincroniza-o com o
+// executor. Os pulls correm em segundo plano; o progresso fica em memória.
+type ImageService struct {
+	repo   WorkspaceRepository
+	images ImageManager
+
+	mu       sync.Mutex
+	progress map[domain.ExecutionType]string
+	pulling  map[domain.ExecutionType]bool
+}
+
+func NewImageService(repo WorkspaceRepository, images ImageManager) *ImageService {
+	return &ImageService{
+		repo:     repo,
+		images:   images,
+		progress: make(map[domain.ExecutionType]string),
+		pulling:  make(map[domain.ExecutionType]bool),
+	}
+}
+
+// LoadCatalog semeia os tipos ausentes com as imagens padrão e aplica o
+// catálogo ao executor.
+func (s *ImageService) LoadCatalog(ctx context.Context) error {
+	entries, err := s.catalog(ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.images.UseImage(entry.Type, entry.PinnedReference())
+	}
+	return nil
+}
+
+func (s *ImageService) catalog(ctx context.Context) (map[domain.ExecutionType]*domain.ImageCatalogEntry, error) {
+	stored, err := s.repo.ListImages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar imagens: %w", err)
+	}
+
+	entries := make(map[domain.ExecutionType]*domain.ImageCatalogEntry)
+	for _, entry := range stored {
+		entries[entry.Type] = entry
+	}
+
+	for execType, reference := range domain.DefaultImages {
+		if _, ok := entries[execType]; ok {
+			continue
+		}
+		entry := &domain.ImageCatalogEntry{
+			Type:      execType,
+			Reference: reference,
+			Status:    domain.ImageStatusPending,
+		}
+		if err := s.save(ctx, entry); err != nil {
+			return nil, fmt.Errorf("falha ao registar imagem padrão de %s: %w", execType, err)
+		}
+		entries[execType] = entry
+	}
+	return entries, nil
+}
+
+func (s *ImageService) ListImages(ctx context.Context) ([]*domain.ImageCatalogEntry, error) {
+	entries, err := s.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*domain.ImageCatalogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if s.pulling[entry.Type] {
+			entry.Status = domain.ImageStatusPulling
+			entry.Progress = s.progress[entry.Type]
+		}
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type < list[j].Type })
+	return list, nil
+}
+
+// SetImage altera a imagem de um tipo de lab e inicia o seu pull. O executor
+// só passa a usar a nova imagem quando o pull termina.
+func (s *ImageService) SetImage(ctx context.Context, execType domain.ExecutionType, reference string) (*domain.ImageCatalogEntry, error) {
+	reference = strings.TrimSpace(reference)
+	if reference == "" {
+		return nil, fmt.Errorf("referência da imagem é obrigatória")
+	}
+	if _, ok := domain.DefaultImages[execType]; !ok {
+		return nil, fmt.Errorf("tipo não suportado: %s", execType)
+	}
+
+	entry := &domain.ImageCatalogEntry{
+		Type:      execType,
+		Reference: reference,
+		Status:    domain.ImageStatusPending,
+	}
+	if err := s.save(ctx, entry); err != nil {
+		return nil, fmt.Errorf("falha ao atualizar imagem de %s: %w", execType, err)
+	}
+
+	s.startPull(entry, entry.Reference)
+	return entry, nil
+}
+
+// PullImage (re)baixa a imagem de um tipo, atualizando o digest fixado.
+func (s *ImageService) PullImage(ctx context.Context, execType domain.ExecutionType) (*domain.ImageCatalogEntry, error) {
+	entries, err := s.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := entries[execType]
+	if !ok {
+		return nil, fmt.Errorf("tipo não suportado: %s", execType)
+	}
+
+	s.startPull(entry, entry.Reference)
+	return entry, nil
+}
+
+// RefreshAll (re)baixa todas as imagens do catálogo.
+func (s *ImageService) RefreshAll(ctx context.Context) error {
+	entries, err := s.catalog(ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.startPull(entry, entry.Reference)
+	}
+	return nil
+}
+
+// Prefetch baixa as imagens do catálogo que ainda não existem localmente,
+// para que a primeira execução de um workshop não fique à espera do pull.
+func (s *ImageService) Prefetch(ctx context.Context) {
+	entries, err := s.catalog(ctx)
+	if err != nil {
+		log.Printf("AVISO [Images]: Prefetch abortado: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		digest, present, err := s.images.ImageDigest(ctx, entry.PinnedReference())
+		if err != nil {
+			log.Printf("AVISO [Images]: Falha ao inspecionar %s: %v", entry.Reference, err)
+		}
+		if present && entry.Digest != "" {
+			continue
+		}
+		if present && digest != "" {
+			// Imagem já existente antes do catálogo: apenas fixa o digest
+			s.recordPulled(ctx, entry, digest)
+			continue
+		}
+		// Com digest registado, baixa exatamente a versão fixada
+		s.startPull(entry, entry.PinnedReference())
+	}
+}
+
+// startPull baixa reference em segundo plano e regista o digest obtido na
+// entrada (se não houver outro pull em curso para o mesmo tipo).
+func (s *ImageService) startPull(entry *domain.ImageCatalogEntry, reference string) {
+	s.mu.Lock()
+	if s.pulling[entry.Type] {
+		s.mu.Unlock()
+		return
+	}
+	s.pulling[entry.Type] = true
+	s.progress[entry.Type] = ""
+	s.mu.Unlock()
+
+	go func(entry domain.ImageCatalogEntry) {
+		defer func() {
+			s.mu.Lock()
+			delete(s.pulling, entry.Type)
+			delete(s.progress, entry.Type)
+			s.mu.Unlock()
+		}()
+
+		ctx := context.Background()
+		log.Printf("INFO [Images]: A baixar %s (%s)", reference, entry.Type)
+
+		digest, err := s.images.PullImage(ctx, reference, func(p ImagePullProgress) {
+			s.mu.Lock()
+			s.progress[entry.Type] = formatPullProgress(p)
+			s.mu.Unlock()
+		})
+		if err != nil {
+			log.Printf("ERRO [Images]: Falha ao baixar %s: %v", reference, err)
+			entry.Status = domain.ImageStatusError
+			entry.Error = err.Error()
+			if err := s.save(ctx, &entry); err != nil {
+				log.Printf("ERRO [Images]: Falha ao registar erro de %s: %v", entry.Reference, err)
+			}
+			return
+		}
+
+		s.recordPulled(ctx, &entry, digest)
+	}(*entry)
+}
+
+func (s *ImageService) recordPulled(ctx context.Context, entry *domain.ImageCatalogEntry, digest string) {
+	now := time.Now()
+	entry.Digest = digest
+	entry.Status = domain.ImageStatusReady
+	entry.Error = ""
+	entry.PulledAt = &now
+
+	if err := s.save(ctx, entry); err != nil {
+		log.Printf("ERRO [Images]: Falha ao registar digest de %s: %v", entry.Reference, err)
+		return
+	}
+	s.images.UseImage(entry.Type, entry.PinnedReference())
+	log.Printf("INFO [Images]: %s pronto (%s)", entry.Reference, digest)
+}
+
+func formatPullProgress(p ImagePullProgress) string {
+	if p.Total > 0 {
+		return fmt.Sprintf("%s %d%% (%d/%d MB)", p.Status, p.Current*100/p.Total, p.Current>>20, p.Total>>20)
+	}
+	return p.Status
+}
+
+func (s *ImageService) save(ctx context.Context, entry *domain.ImageCatalogEntry) error {
+	entry.UpdatedAt = time.Now()
+	return s.repo.UpsertImage(ctx, entry)
+}
+
+func (s *ImageService) ListAllowedImages(ctx context.Context) ([]*domain.AllowedImage, error) {
+	allowed, err := s.repo.ListAllowedImages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar imagens permitidas: %w", err)
+	}
+	return allowed, nil
+}
+
+// AllowImage acrescenta um padrão à lista de imagens que os autores podem
+// usar nos labs.
+func (s *ImageService) AllowImage(ctx context.Context, pattern string) (*domain.AllowedImage, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || pattern == "*" {
+		return nil, fmt.Errorf("padrão de imagem inválido: %q", pattern)
+	}
+
+	allowed := &domain.AllowedImage{
+		ID:        uuid.New().String(),
+		Pattern:   pattern,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateAllowedImage(ctx, allowed); err != nil {
+		return nil, fmt.Errorf("falha ao registar imagem permitida: %w", err)
+	}
+	return allowed, nil
+}
+
+func (s *ImageService) DisallowImage(ctx context.Context, id string) error {
+	if err := s.repo.DeleteAllowedImage(ctx, id); err != nil {
+		return fmt.Errorf("falha ao remover imagem permitida: %w", err)
+	}
+	return nil
+}
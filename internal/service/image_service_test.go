@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+// fakeImages simula as imagens locais do Docker. Um pull devolve o digest
+// da referência, se fixada, ou "sha256:novo".
+type fakeImages struct {
+	local map[string]string
+
+	mu     sync.Mutex
+	pulled []string
+	used   map[domain.ExecutionType]string
+}
+
+func (f *fakeImages) PullImage(ctx context.Context, reference string, onProgress func(service.ImagePullProgress)) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pulled = append(f.pulled, reference)
+	if _, digest, ok := strings.Cut(reference, "@"); ok {
+		return digest, nil
+	}
+	return "sha256:novo", nil
+}
+
+func (f *fakeImages) ImageDigest(ctx context.Context, reference string) (string, bool, error) {
+	digest, ok := f.local[reference]
+	return digest, ok, nil
+}
+
+func (f *fakeImages) UseImage(execType domain.ExecutionType, reference string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.used[execType] = reference
+}
+
+func (f *fakeImages) pulledFor(reference string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, p := range f.pulled {
+		if strings.HasPrefix(p, reference) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPrefetch(t *testing.T) {
+	const reference = "alpine:latest"
+	tests := []struct {
+		name       string
+		digest     string // digest já registado no catálogo
+		local      map[string]string
+		wantPull   bool
+		wantDigest string
+	}{
+		{"fixada e presente", "sha256:a", map[string]string{reference + "@sha256:a": "sha256:a"}, false, "sha256:a"},
+		{"presente sem digest registado", "", map[string]string{reference: "sha256:b"}, false, "sha256:b"},
+		{"fixada mas ausente", "sha256:a", nil, true, "sha256:a"},
+		{"ausente", "", nil, true, "sha256:novo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newTestRepo(t)
+			if tt.digest != "" {
+				entry := &domain.ImageCatalogEntry{Type: domain.TypeLinux, Reference: reference, Digest: tt.digest, Status: domain.ImageStatusReady}
+				if err := repo.UpsertImage(ctx, entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			images := &fakeImages{local: tt.local, used: make(map[domain.ExecutionType]string)}
+			svc := service.NewImageService(repo, images)
+
+			svc.Prefetch(ctx)
+			entry := waitImageReady(t, svc, domain.TypeLinux)
+
+			if got := images.pulledFor(reference); got != tt.wantPull {
+				t.Errorf("pull de %s = %v, esperava %v", reference, got, tt.wantPull)
+			}
+			if entry.Digest != tt.wantDigest {
+				t.Errorf("digest registado = %q, esperava %q", entry.Digest, tt.wantDigest)
+			}
+			if tt.digest == "" || tt.wantPull {
+				images.mu.Lock()
+				used := images.used[domain.TypeLinux]
+				images.mu.Unlock()
+				if used != reference+"@"+tt.wantDigest {
+					t.Errorf("o executor usa %q, esperava a imagem fixada", used)
+				}
+			}
+		})
+	}
+}
+
+// waitImageReady aguarda que todas as imagens do catálogo fiquem prontas,
+// para que nenhum pull grave depois do fim do teste, e devolve a do tipo.
+func waitImageReady(t *testing.T, svc *service.ImageService, execType domain.ExecutionType) *domain.ImageCatalogEntry {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		entries, err := svc.ListImages(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found *domain.ImageCatalogEntry
+		ready := true
+		for _, entry := range entries {
+			ready = ready && entry.Status == domain.ImageStatusReady
+			if entry.Type == execType {
+				found = entry
+			}
+		}
+		if ready && found != nil {
+			return found
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("as imagens do catálogo não ficaram prontas: %+v", entries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"lab-devops/internal/labpack"
+	"log"
+	"reflect"
+	"sort"
+)
+
+// ErrInvalidLabPack indica um lab pack que não passou na validação.
+var ErrInvalidLabPack = errors.New("lab pack inválido")
+
+// Resultado de cada trilha ou lab numa importação de lab packs
+const (
+	PackItemCreated   = domain.CatalogChangeCreated
+	PackItemUpdated   = domain.CatalogChangeUpdated
+	PackItemUnchanged = "unchanged"
+)
+
+// LabPackItem é o resultado da importação de uma trilha ou de um lab.
+type LabPackItem struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Action string `json:"action"`
+}
+
+// LabPackImport resume uma importação de lab packs.
+type LabPackImport struct {
+	DryRun bool          `json:"dry_run"`
+	Tracks []LabPackItem `json:"tracks"`
+	Labs   []LabPackItem `json:"labs"`
+}
+
+// packPlan é o resultado de comparar lab packs com a base: o que cada
+// trilha e lab dos packs exige, e o que já lá estava.
+type packPlan struct {
+	tracks []plannedTrack
+	labs   []plannedLab
+
+	existingTracks map[string]*domain.Track
+	existingLabs   map[string]*domain.Lab
+}
+
+type plannedTrack struct {
+	track  *domain.Track
+	action string
+}
+
+type plannedLab struct {
+	lab    *domain.Lab
+	action string
+}
+
+// ImportLabPacks cria ou atualiza as trilhas e os labs dos packs, usando o
+// slug como ID. Os packs são todos validados antes de qualquer escrita;
+// trilhas e labs que já estão iguais na base não são tocados, e os que não
+// constam dos packs ficam como estão. Com dryRun só devolve o que mudaria.
+func (s *LabService) ImportLabPacks(ctx context.Context, packs []labpack.Pack, dryRun bool) (*LabPackImport, error) {
+	// Cada réplica importa os packs no arranque: com o lock do catálogo, a
+	// que chega depois planeia sobre o que a primeira gravou
+	if !dryRun {
+		unlock, err := s.repo.Lock(ctx, catalogSyncLock)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	plan, err := s.planLabPacks(ctx, packs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LabPackImport{DryRun: dryRun, Tracks: []LabPackItem{}, Labs: []LabPackItem{}}
+	for _, p := range plan.tracks {
+		result.Tracks = append(result.Tracks, LabPackItem{ID: p.track.ID, Title: p.track.Title, Action: p.action})
+	}
+	for _, p := range plan.labs {
+		result.Labs = append(result.Labs, LabPackItem{ID: p.lab.ID, Title: p.lab.Title, Action: p.action})
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	if err := s.applyPackPlan(ctx, plan); err != nil {
+		return result, err
+	}
+	log.Printf("INFO [LabPacks]: %d trilha(s) e %d lab(s) importados", len(result.Tracks), len(result.Labs))
+	return result, nil
+}
+
+// planLabPacks valida os packs e compara-os com as trilhas e labs da base.
+// deletedLabs são os labs que saem do catálogo no mesmo passo (ver
+// checkPrerequisites).
+func (s *LabService) planLabPacks(ctx context.Context, packs []labpack.Pack, deletedLabs []string) (*packPlan, error) {
+	if err := s.validateLabPacks(ctx, packs, deletedLabs); err != nil {
+		return nil, err
+	}
+
+	existingTracks, err := s.repo.ListTracks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar trilhas: %w", err)
+	}
+	existingLabs, err := s.repo.ListLabs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar labs: %w", err)
+	}
+
+	plan := &packPlan{
+		existingTracks: make(map[string]*domain.Track, len(existingTracks)),
+		existingLabs:   make(map[string]*domain.Lab, len(existingLabs)),
+	}
+	for _, t := range existingTracks {
+		plan.existingTracks[t.ID] = t
+	}
+	for _, l := range existingLabs {
+		plan.existingLabs[l.ID] = l
+	}
+
+	for _, pack := range packs {
+		if track := pack.Track; track != nil {
+			action := PackItemUnchanged
+			switch current := plan.existingTracks[track.ID]; {
+			case current == nil:
+				action = PackItemCreated
+			case current.Title != track.Title || current.Description != track.Description || current.Sequential != track.Sequential:
+				action = PackItemUpdated
+			}
+			plan.tracks = append(plan.tracks, plannedTrack{track: track, action: action})
+		}
+		for _, lab := range pack.Labs {
+			action := PackItemUnchanged
+			switch current := plan.existingLabs[lab.ID]; {
+			case current == nil:
+				action = PackItemCreated
+			case !sameLabContent(current, lab):
+				action = PackItemUpdated
+			}
+			plan.labs = append(plan.labs, plannedLab{lab: lab, action: action})
+		}
+	}
+	return plan, nil
+}
+
+// applyPackPlan grava, numa transação, as trilhas e depois os labs que o
+// plano cria ou altera.
+func (s *LabService) applyPackPlan(ctx context.Context, plan *packPlan) error {
+	if err := s.repo.ApplyCatalogWrites(ctx, plan.writes(), nil); err != nil {
+		return fmt.Errorf("falha ao gravar os lab packs: %w", err)
+	}
+	return nil
+}
+
+// writes são as criações e atualizações do plano.
+func (p *packPlan) writes() *domain.CatalogWrites {
+	writes := &domain.CatalogWrites{}
+	for _, t := range p.tracks {
+		switch t.action {
+		case PackItemCreated:
+			writes.CreateTracks = append(writes.CreateTracks, t.track)
+		case PackItemUpdated:
+			writes.UpdateTracks = append(writes.UpdateTracks, t.track)
+		}
+	}
+	for _, l := range p.labs {
+		switch l.action {
+		case PackItemCreated:
+			writes.CreateLabs = append(writes.CreateLabs, l.lab)
+		case PackItemUpdated:
+			writes.UpdateLabs = append(writes.UpdateLabs, l.lab)
+		}
+	}
+	return writes
+}
+
+// validateLabPacks aplica aos packs as mesmas regras da criação de labs e
+// recusa slugs repetidos. Os pré-requisitos podem ser labs dos packs ou já
+// existentes na base, exceto os de deletedLabs.
+func (s *LabService) validateLabPacks(ctx context.Context, packs []labpack.Pack, deletedLabs []string) error {
+	trackIDs := make(map[string]bool)
+	labIDs := make(map[string]bool)
+	var tracks []*domain.Track
+	var labs []*domain.Lab
+	for _, pack := range packs {
+		if pack.Track != nil {
+			if trackIDs[pack.Track.ID] {
+				return fmt.Errorf("%w: trilha %s repetida", ErrInvalidLabPack, pack.Track.ID)
+			}
+			trackIDs[pack.Track.ID] = true
+			tracks = append(tracks, pack.Track)
+		}
+		labs = append(labs, pack.Labs...)
+		for _, lab := range pack.Labs {
+			if labIDs[lab.ID] {
+				return fmt.Errorf("%w: lab %s repetido", ErrInvalidLabPack, lab.ID)
+			}
+			labIDs[lab.ID] = true
+
+			if err := domain.ValidateChecks(lab.Checks); err != nil {
+				return fmt.Errorf("%w: lab %s: %w", ErrInvalidLabPack, lab.ID, err)
+			}
+			if err := domain.ValidateHints(lab.Hints, lab.Checks); err != nil {
+				return fmt.Errorf("%w: lab %s: %w", ErrInvalidLabPack, lab.ID, err)
+			}
+			if err := s.checkImage(ctx, lab.Image); err != nil {
+				return fmt.Errorf("lab %s: %w", lab.ID, err)
+			}
+		}
+	}
+	if err := s.checkPrerequisites(ctx, labs, tracks, deletedLabs); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLabPack, err)
+	}
+	return nil
+}
+
+// sameLabContent compara o conteúdo versionado de dois labs (tudo menos a
+// data de criação).
+func sameLabContent(a, b *domain.Lab) bool {
+	x, y := *a, *b
+	x.CreatedAt = y.CreatedAt
+	return reflect.DeepEqual(x, y)
+}
+
+// ExportLabPacks devolve todas as trilhas e labs da base como packs, com os
+// labs de cada trilha por ordem. Os labs sem trilha (ou cuja trilha já não
+// existe) ficam num pack sem trilha, no fim.
+func (s *LabService) ExportLabPacks(ctx context.Context) ([]labpack.Pack, error) {
+	tracks, err := s.repo.ListTracks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar trilhas: %w", err)
+	}
+	labs, err := s.repo.ListLabs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar labs: %w", err)
+	}
+
+	packs := make([]labpack.Pack, 0, len(tracks)+1)
+	index := make(map[string]int, len(tracks))
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].ID < tracks[j].ID })
+	for i, t := range tracks {
+		t.Labs = nil
+		packs = append(packs, labpack.Pack{Track: t})
+		index[t.ID] = i
+	}
+
+	var untracked labpack.Pack
+	sort.SliceStable(labs, func(i, j int) bool {
+		if labs[i].LabOrder != labs[j].LabOrder {
+			return labs[i].LabOrder < labs[j].LabOrder
+		}
+		return labs[i].ID < labs[j].ID
+	})
+	for _, lab := range labs {
+		if i, ok := index[lab.TrackID]; ok {
+			packs[i].Labs = append(packs[i].Labs, lab)
+		} else {
+			untracked.Labs = append(untracked.Labs, lab)
+		}
+	}
+	if len(untracked.Labs) > 0 {
+		packs = append(packs, untracked)
+	}
+	return packs, nil
+}
@@ -2,50 +2,127 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"lab-devops/internal/domain"
 	"log"
+	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrImageNotAllowed indica que a imagem pedida para um lab não consta da
+	// lista de imagens permitidas.
+	ErrImageNotAllowed = errors.New("imagem não permitida")
+	// ErrTerraformOnly indica um pedido de plan ou destroy num lab que não é Terraform.
+	ErrTerraformOnly = errors.New("ação disponível apenas em labs Terraform")
+)
+
 type LabService struct {
-	repo     WorkspaceRepository
-	executor Executor
+	repo      WorkspaceRepository
+	executor  Executor
+	scheduler *ExecutionScheduler
+
+	// live são as execuções em curso ou terminadas há pouco (ver launch)
+	liveMu sync.Mutex
+	live   map[string]*LiveExecution
+
+	// sessions são as sessões de lab abertas, por workspace (ver StartSession)
+	sessionsMu sync.Mutex
+	sessions   map[string]*liveSession
+	// startingSessions marca os workspaces com uma sessão a arrancar; o
+	// canal fecha quando o arranque termina
+	startingSessions map[string]chan struct{}
 }
 
-func NewLabService(repo WorkspaceRepository, executor Executor) *LabService {
+func NewLabService(repo WorkspaceRepository, executor Executor, scheduler *ExecutionScheduler) *LabService {
 	return &LabService{
-		repo:     repo,
-		executor: executor,
+		repo:      repo,
+		executor:  executor,
+		scheduler: scheduler,
+		live:      make(map[string]*LiveExecution),
+		sessions:  make(map[string]*liveSession),
+
+		startingSessions: make(map[string]chan struct{}),
 	}
 }
 
 func (s *LabService) ExecuteLab(
 	ctx context.Context,
+	userID string,
 	labID string,
 	code string,
-) (<-chan ExecutionResult, <-chan ExecutionFinalState, string, error) {
+	changes domain.FileChanges,
+) (*LiveExecution, error) {
+	return s.runLab(ctx, userID, labID, code, changes, domain.ModeApply)
+}
+
+// PlanLab grava o código do utilizador e corre "terraform plan" contra o
+// estado do workspace, sem aplicar nem validar.
+func (s *LabService) PlanLab(
+	ctx context.Context,
+	userID string,
+	labID string,
+	code string,
+	changes domain.FileChanges,
+) (*LiveExecution, error) {
+	return s.runLab(ctx, userID, labID, code, changes, domain.ModePlan)
+}
+
+func (s *LabService) runLab(
+	ctx context.Context,
+	userID string,
+	labID string,
+	code string,
+	changes domain.FileChanges,
+	mode domain.ExecutionMode,
+) (*LiveExecution, error) {
 	lab, err := s.repo.GetLabByID(ctx, labID)
 	if err != nil {
-		return nil, nil, "", fmt.Errorf("falha ao buscar workspace para o lab %s: %w", labID, err)
+		return nil, fmt.Errorf("falha ao buscar workspace para o lab %s: %w", labID, err)
 	}
 
 	if lab == nil {
-		return nil, nil, "", fmt.Errorf("lab com ID %s não encontrado", labID)
+		return nil, fmt.Errorf("lab com ID %s não encontrado", labID)
+	}
+	if err := s.checkUnlocked(ctx, userID, lab); err != nil {
+		return nil, err
+	}
+
+	if mode == domain.ModePlan && domain.ExecutionType(lab.Type) != domain.TypeTerraform {
+		return nil, ErrTerraformOnly
 	}
 
-	ws, err := s.repo.GetWorkspaceByLabID(ctx, labID)
+	ws, err := s.workspaceFor(ctx, userID, labID)
 	if err != nil {
-		return nil, nil, "", fmt.Errorf("falha ao buscar workspace para o lab %s: %w", labID, err)
+		return nil, fmt.Errorf("falha ao buscar workspace para o lab %s: %w", labID, err)
 	}
-	if ws == nil {
-		return nil, nil, "", fmt.Errorf("workspace para o lab %s não encontrado", labID)
+
+	// A imagem pode ter sido retirada da lista de permitidas após a criação do lab
+	if err := s.checkImage(ctx, lab.Image); err != nil {
+		return nil, err
+	}
+
+	// Com uma sessão aberta o passo corre no seu container, um de cada vez
+	session := s.sessionFor(ws.ID)
+	if session != nil && session.snapshot().Busy {
+		return nil, ErrSessionBusy
 	}
 
-	err = s.repo.UpdateWorkspaceCode(ctx, ws.ID, code)
+	if changes.IsEmpty() {
+		err = s.repo.UpdateWorkspaceCode(ctx, ws.ID, code)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao atualizar workspace para o lab %s: %w", labID, err)
+		}
+	} else if code, err = s.applyFileChanges(ctx, lab, ws, code, changes); err != nil {
+		return nil, err
+	}
+
+	files, err := s.workspaceFiles(ctx, ws.ID)
 	if err != nil {
-		return nil, nil, "", fmt.Errorf("falha ao atualizar workspace para o lab %s: %w", labID, err)
+		return nil, err
 	}
 
 	execConfig := domain.ExecutionConfig{
@@ -53,44 +130,214 @@ func (s *LabService) ExecuteLab(
 		Code:        	code,
 		State:       	ws.State,
 		ValidationCode: lab.ValidationCode,
+		Checks:         lab.Checks,
 		Type:        	domain.ExecutionType(lab.Type),
+		Limits:         lab.Limits,
+		Image:          lab.Image,
+		Files:          files,
+		Mode:           mode,
 	}
 
-	logStream, finalState, err := s.executor.Execute(ctx, execConfig)
+	rev, err := s.snapshotRevision(ctx, ws.ID, mode, code, files)
 	if err != nil {
-		return nil, nil, "", fmt.Errorf("falha ao executar lab %s: %w", labID, err)
+		return nil, err
 	}
 
-	return logStream, finalState, ws.ID, nil
+	action := domain.ActionExecute
+	if mode == domain.ModePlan {
+		action = domain.ActionPlan
+	}
+	if session != nil {
+		return s.runInSession(ctx, session, userID, action, execConfig, rev)
+	}
+	return s.launch(ctx, userID, action, execConfig, rev), nil
 }
 
 func (s *LabService) ValidateLab(
 	ctx context.Context,
+	userID string,
 	labID string,
-) (<-chan ExecutionResult, <-chan ExecutionFinalState, string, error) {
+) (*LiveExecution, error) {
 	lab, err := s.repo.GetLabByID(ctx, labID)
 	if err != nil {
-		return nil, nil, "", fmt.Errorf("falha ao buscar lab para o lab %s: %w", labID, err)
+		return nil, fmt.Errorf("falha ao buscar lab para o lab %s: %w", labID, err)
+	}
+	if lab == nil {
+		return nil, fmt.Errorf("lab com ID %s não encontrado", labID)
+	}
+	if err := s.checkUnlocked(ctx, userID, lab); err != nil {
+		return nil, err
 	}
 
-	ws, err := s.repo.GetWorkspaceByLabID(ctx, labID)
+	ws, err := s.workspaceFor(ctx, userID, labID)
 	if err != nil {
-		return nil, nil, "", fmt.Errorf("falha ao buscar workspace para o lab %s: %w", labID, err)
+		return nil, fmt.Errorf("falha ao buscar workspace para o lab %s: %w", labID, err)
 	}
 
-	if lab.ValidationCode == "" {
-		return nil, nil, "", fmt.Errorf("lab %s não possui código de validação", labID)
+	if lab.ValidationCode == "" && len(lab.Checks) == 0 {
+		return nil, fmt.Errorf("lab %s não possui código de validação", labID)
 	}
 
+	if err := s.checkImage(ctx, lab.Image); err != nil {
+		return nil, err
+	}
+
+	// Numa sessão valida-se o que está no container, sem reaplicar o código,
+	// como na validação a partir de um terminal da sessão
+	if session := s.sessionFor(ws.ID); session != nil {
+		config := session.config
+		config.Mode = domain.ModeValidate
+		return s.runInSession(ctx, session, userID, domain.ActionValidate, config, nil)
+	}
+
+	files, err := s.workspaceFiles(ctx, ws.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Como no runLab: reaplica o código do utilizador e corre a validação e
+	// as verificações sobre o resultado
 	execConfig := domain.ExecutionConfig{
-		WorkspaceID: ws.ID,
-		Code:        lab.ValidationCode,
-		State:       ws.State,
-		Type:        domain.ExecutionType(lab.Type),
+		WorkspaceID:    ws.ID,
+		Code:           ws.UserCode,
+		State:          ws.State,
+		ValidationCode: lab.ValidationCode,
+		Checks:         lab.Checks,
+		Type:           domain.ExecutionType(lab.Type),
+		Limits:         lab.Limits,
+		Image:          lab.Image,
+		Files:          files,
+		Mode:           domain.ModeApply,
 	}
 
-	logStream, finalState, err := s.executor.Execute(ctx, execConfig)
-	return logStream, finalState, ws.ID, err
+	return s.launch(ctx, userID, domain.ActionValidate, execConfig, nil), nil
+}
+
+// scheduleOn aguarda um slot no scheduler e só então entrega a execução ao
+// executor, reencaminhando os seus canais. Enquanto aguarda, publica a
+// posição na fila no logStream (ExecutionResult.QueuePosition). A execução
+// e o seu output ficam registados no histórico com o id dado (ver
+// ListExecutions). Sem scheduler (nil) a execução começa logo: é o caso da
+// validação num terminal ou do destroy de um reset, que já ocupam o slot.
+// Com scheduler, o estado e os ficheiros do workspace são relidos depois de
+// obtido o slot (ver refreshConfig). O estado final é gravado no workspace
+// (ver persistFinalState) antes de o slot ser libertado.
+func (s *LabService) scheduleOn(ctx context.Context, executor Executor, scheduler *ExecutionScheduler, id, userID, action string, config domain.ExecutionConfig) (<-chan ExecutionResult, <-chan ExecutionFinalState) {
+	logStream := make(chan ExecutionResult)
+	finalState := make(chan ExecutionFinalState, 1)
+
+	go func() {
+		defer close(logStream)
+		defer close(finalState)
+
+		rec := s.startExecution(ctx, id, userID, action, config)
+		var output outputBuffer
+		finish := func(state ExecutionFinalState) {
+			s.persistFinalState(context.WithoutCancel(ctx), action, config.WorkspaceID, &state)
+			s.finishExecution(ctx, rec, state, output.String())
+			finalState <- state
+		}
+
+		if scheduler != nil {
+			release, err := scheduler.Acquire(ctx, userID, config.WorkspaceID, func(position int) {
+				select {
+				case logStream <- ExecutionResult{QueuePosition: position}:
+				case <-ctx.Done():
+				}
+			})
+			if err != nil {
+				finish(ExecutionFinalState{
+					WorkspaceID: config.WorkspaceID,
+					Error:       fmt.Errorf("execução cancelada enquanto aguardava na fila"),
+					Outcome:     domain.OutcomeCancelled,
+				})
+				return
+			}
+			if release, err = s.lockWorkspace(ctx, config.WorkspaceID, release); err != nil {
+				outcome := domain.OutcomeFailed
+				if ctx.Err() != nil {
+					outcome = domain.OutcomeCancelled
+				}
+				finish(ExecutionFinalState{WorkspaceID: config.WorkspaceID, Error: err, Outcome: outcome})
+				return
+			}
+			defer release()
+
+			if config, err = s.refreshConfig(ctx, config); err != nil {
+				finish(ExecutionFinalState{
+					WorkspaceID: config.WorkspaceID,
+					Error:       err,
+					Outcome:     domain.OutcomeFailed,
+				})
+				return
+			}
+		}
+
+		execLogs, execFinal, err := executor.Execute(ctx, config)
+		if err != nil {
+			finish(ExecutionFinalState{
+				WorkspaceID: config.WorkspaceID,
+				Error:       fmt.Errorf("falha ao executar workspace %s: %w", config.WorkspaceID, err),
+				Outcome:     domain.OutcomeFailed,
+			})
+			return
+		}
+
+		for execLogs != nil || execFinal != nil {
+			select {
+			case line, ok := <-execLogs:
+				if !ok {
+					execLogs = nil
+					continue
+				}
+				output.add(line.Line)
+				logStream <- line
+			case state, ok := <-execFinal:
+				if !ok {
+					execFinal = nil
+					continue
+				}
+				finish(state)
+			}
+		}
+	}()
+
+	return logStream, finalState
+}
+
+// lockWorkspace junta ao slot do scheduler, que só vale nesta réplica da
+// API, o lock do workspace na base (ver WorkspaceRepository.Lock), para que
+// duas réplicas nunca usem o mesmo workspace ao mesmo tempo. Se o lock
+// falhar, liberta o slot. A função devolvida liberta os dois.
+func (s *LabService) lockWorkspace(ctx context.Context, workspaceID string, release func()) (func(), error) {
+	unlock, err := s.repo.Lock(ctx, "workspace:"+workspaceID)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("falha ao reservar o workspace %s: %w", workspaceID, err)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unlock()
+			release()
+		})
+	}, nil
+}
+
+// refreshConfig relê o estado e os ficheiros do workspace. Enquanto a
+// execução aguardava na fila, outra do mesmo workspace pode tê-los mudado.
+func (s *LabService) refreshConfig(ctx context.Context, config domain.ExecutionConfig) (domain.ExecutionConfig, error) {
+	state, err := s.repo.GetWorkspaceState(ctx, config.WorkspaceID)
+	if err != nil {
+		return config, fmt.Errorf("falha ao ler o estado do workspace %s: %w", config.WorkspaceID, err)
+	}
+	files, err := s.workspaceFiles(ctx, config.WorkspaceID)
+	if err != nil {
+		return config, err
+	}
+	config.State = state
+	config.Files = files
+	return config, nil
 }
 
 func (s *LabService) SaveWorkspaceStatus(ctx context.Context, workspaceId string, status string) error {
@@ -100,6 +347,21 @@ func (s *LabService) SaveWorkspaceStatus(ctx context.Context, workspaceId string
 	return nil
 }
 
+// SaveValidationReport grava o relatório de validação, descontando da
+// pontuação a penalização das dicas já reveladas no workspace.
+func (s *LabService) SaveValidationReport(ctx context.Context, workspaceID string, report *domain.ValidationReport) error {
+	penalty, err := s.hintPenalty(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("falha ao carregar dicas do workspace %s: %w", workspaceID, err)
+	}
+	report.ApplyHintPenalty(penalty)
+
+	if err := s.repo.UpdateWorkspaceReport(ctx, workspaceID, report); err != nil {
+		return fmt.Errorf("falha ao salvar o relatório de validação do workspace %s: %w", workspaceID, err)
+	}
+	return nil
+}
+
 func (s *LabService) SaveWorkspaceState(ctx context.Context, workspaceID string, state []byte) error {
 	if err := s.repo.UpdateWorkspaceState(ctx, workspaceID, state); err != nil {
 		return fmt.Errorf("falha ao salvar o estado final do workspace %s: %w", workspaceID, err)
@@ -107,7 +369,31 @@ func (s *LabService) SaveWorkspaceState(ctx context.Context, workspaceID string,
 	return nil
 }
 
-func (s *LabService) GetLabDetails(ctx context.Context, labID string) (*domain.Lab, *domain.Workspace, error) {
+// GetLabDetails devolve o lab, o workspace do utilizador com a árvore de
+// ficheiros e os pré-requisitos que lhe faltam concluir.
+func (s *LabService) GetLabDetails(ctx context.Context, userID string, labID string) (*LabDetails, error) {
+	lab, ws, err := s.labDetails(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := s.missingPrerequisites(ctx, userID, lab)
+	if err != nil {
+		return nil, err
+	}
+	if missing == nil {
+		missing = []domain.LabRef{}
+	}
+
+	return &LabDetails{
+		Lab:                  lab,
+		Workspace:            ws,
+		Locked:               len(missing) > 0,
+		MissingPrerequisites: missing,
+	}, nil
+}
+
+// labDetails devolve o lab e o workspace do utilizador com a árvore de ficheiros.
+func (s *LabService) labDetails(ctx context.Context, userID string, labID string) (*domain.Lab, *domain.Workspace, error) {
 	lab, err := s.repo.GetLabByID(ctx, labID)
 	if err != nil {
 		return nil, nil, err
@@ -116,18 +402,37 @@ func (s *LabService) GetLabDetails(ctx context.Context, labID string) (*domain.L
 		return nil, nil, fmt.Errorf("lab não encontrado")
 	}
 
-	ws, err := s.repo.GetWorkspaceByLabID(ctx, labID)
+	ws, err := s.workspaceFor(ctx, userID, labID)
 	if err != nil {
 		return nil, nil, err
 	}
+
+	ws.Files, err = s.workspaceTree(ctx, lab, ws)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return lab, ws, nil
+}
+
+// workspaceFor devolve o workspace do utilizador para o lab, criando-o a
+// partir do InitialCode na primeira visita.
+func (s *LabService) workspaceFor(ctx context.Context, userID string, labID string) (*domain.Workspace, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("utilizador não identificado")
+	}
+
+	ws, err := s.repo.GetWorkspaceByUserAndLab(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
 	if ws == nil {
-		ws, err = s.repo.CreateWorkspace(ctx, labID)
+		ws, err = s.repo.CreateWorkspace(ctx, userID, labID)
 		if err != nil {
-			return nil, nil, fmt.Errorf("falha ao criar workspace: %w", err)
+			return nil, fmt.Errorf("falha ao criar workspace: %w", err)
 		}
 	}
-
-	return lab, ws, nil
+	return ws, nil
 }
 
 func (s *LabService) CreateLab(
@@ -136,10 +441,24 @@ func (s *LabService) CreateLab(
 	trackID string,
 	labOrder int,
 	validationCode string, // NOVO PARAMETRO
+	limits domain.ResourceLimits,
+	image *domain.LabImage,
+	checks []domain.ValidationCheck,
+	hints []domain.Hint,
+	prerequisites []string,
 ) (*domain.Lab, error) {
 	if title == "" || labType == "" {
 		return nil, fmt.Errorf("titulo e tipo são obrigatórios")
 	}
+	if err := domain.ValidateChecks(checks); err != nil {
+		return nil, err
+	}
+	if err := domain.ValidateHints(hints, checks); err != nil {
+		return nil, err
+	}
+	if err := s.checkImage(ctx, image); err != nil {
+		return nil, err
+	}
 
 	newLab := &domain.Lab{
 		ID:             uuid.New().String(),
@@ -150,6 +469,14 @@ func (s *LabService) CreateLab(
 		TrackID:        trackID,
 		LabOrder:       labOrder,
 		ValidationCode: validationCode,
+		Limits:         limits,
+		Image:          image,
+		Checks:         checks,
+		Hints:          hints,
+		Prerequisites:  prerequisites,
+	}
+	if err := s.checkPrerequisites(ctx, []*domain.Lab{newLab}, nil, nil); err != nil {
+		return nil, err
 	}
 
 	if err := s.repo.CreateLab(ctx, newLab); err != nil {
@@ -175,7 +502,12 @@ func (s *LabService) GetWorkspaceState(ctx context.Context, workspaceID string)
 	return state, nil
 }
 
+// CleanLab apaga o lab, recusando com
+// ErrInvalidPrerequisites se outros labs dependerem dele.
 func (s *LabService) CleanLab(ctx context.Context, labId string) error {
+	if err := s.checkPrerequisites(ctx, nil, nil, []string{labId}); err != nil {
+		return err
+	}
 	err := s.repo.CleanLab(ctx, labId)
 	if err != nil {
 		return fmt.Errorf("erro ao apagar laboratório: %w", err)
@@ -183,7 +515,7 @@ func (s *LabService) CleanLab(ctx context.Context, labId string) error {
 	return err
 }
 
-func (s *LabService) CreateTrack(ctx context.Context, title, description string) (*domain.Track, error) {
+func (s *LabService) CreateTrack(ctx context.Context, title, description string, sequential bool) (*domain.Track, error) {
 	if title == "" {
 		return nil, fmt.Errorf("titulo é obrigatório")
 	}
@@ -192,6 +524,7 @@ func (s *LabService) CreateTrack(ctx context.Context, title, description string)
 		ID:          uuid.New().String(),
 		Title:       title,
 		Description: description,
+		Sequential:  sequential,
 	}
 
 	if err := s.repo.CreateTrack(ctx, newTrack); err != nil {
@@ -220,7 +553,7 @@ func (s *LabService) ListTracks(ctx context.Context) ([]*domain.Track, error) {
 	return tracks, nil
 }
 
-func (s *LabService) UpdateLab(ctx context.Context, id, title, labType, instructions, initialCode, trackID string, labOrder int, validationCode string) (*domain.Lab, error) {
+func (s *LabService) UpdateLab(ctx context.Context, id, title, labType, instructions, initialCode, trackID string, labOrder int, validationCode string, limits *domain.ResourceLimits, image *domain.LabImage, checks []domain.ValidationCheck, hints []domain.Hint, prerequisites []string) (*domain.Lab, error) {
 	existingLab, err := s.repo.GetLabByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -250,6 +583,46 @@ func (s *LabService) UpdateLab(ctx context.Context, id, title, labType, instruct
 	if labOrder != 0 {
 		existingLab.LabOrder = labOrder
 	}
+	if limits != nil {
+		existingLab.Limits = *limits
+	}
+	// Uma lista vazia (não nula) remove as verificações do lab
+	if checks != nil {
+		if err := domain.ValidateChecks(checks); err != nil {
+			return nil, err
+		}
+		existingLab.Checks = checks
+	}
+	// Tal como as verificações, uma lista vazia remove as dicas do lab
+	if hints != nil {
+		existingLab.Hints = hints
+	}
+	if checks != nil || hints != nil {
+		if err := domain.ValidateHints(existingLab.Hints, existingLab.Checks); err != nil {
+			return nil, err
+		}
+	}
+	// Uma referência vazia remove a imagem própria do lab
+	if image != nil {
+		if image.Reference == "" {
+			existingLab.Image = nil
+		} else {
+			if err := s.checkImage(ctx, image); err != nil {
+				return nil, err
+			}
+			existingLab.Image = image
+		}
+	}
+	// Uma lista vazia remove os pré-requisitos; a trilha e a ordem também
+	// mudam os labs de que este depende
+	if prerequisites != nil {
+		existingLab.Prerequisites = prerequisites
+	}
+	if prerequisites != nil || trackID != "" || labOrder != 0 {
+		if err := s.checkPrerequisites(ctx, []*domain.Lab{existingLab}, nil, nil); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := s.repo.UpdateLab(ctx, existingLab); err != nil {
 		return nil, err
@@ -258,7 +631,7 @@ func (s *LabService) UpdateLab(ctx context.Context, id, title, labType, instruct
 	return existingLab, nil
 }
 
-func (s *LabService) UpdateTrack(ctx context.Context, id, title, description string) (*domain.Track, error) {
+func (s *LabService) UpdateTrack(ctx context.Context, id, title, description string, sequential *bool) (*domain.Track, error) {
 	existingTrack, err := s.repo.GetTrackByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -273,6 +646,13 @@ func (s *LabService) UpdateTrack(ctx context.Context, id, title, description str
 	if description != "" {
 		existingTrack.Description = description
 	}
+	// Tornar a trilha sequencial pode fechar um ciclo com os pré-requisitos
+	if sequential != nil {
+		existingTrack.Sequential = *sequential
+		if err := s.checkPrerequisites(ctx, nil, []*domain.Track{existingTrack}, nil); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := s.repo.UpdateTrack(ctx, existingTrack); err != nil {
 		return nil, err
@@ -281,7 +661,12 @@ func (s *LabService) UpdateTrack(ctx context.Context, id, title, description str
 	return existingTrack, nil
 }
 
+// DeleteLab apaga o lab, recusando com ErrInvalidPrerequisites se outros
+// labs dependerem dele.
 func (s *LabService) DeleteLab(ctx context.Context, id string) error {
+	if err := s.checkPrerequisites(ctx, nil, nil, []string{id}); err != nil {
+		return err
+	}
 	if err := s.repo.DeleteLab(ctx, id); err != nil {
 		return err
 	}
@@ -294,3 +679,30 @@ func (s *LabService) DeleteTrack(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// checkImage valida a imagem própria de um lab contra a lista de imagens
+// permitidas pelos administradores.
+func (s *LabService) checkImage(ctx context.Context, image *domain.LabImage) error {
+	if image == nil {
+		return nil
+	}
+	if strings.TrimSpace(image.Reference) == "" {
+		return fmt.Errorf("referência da imagem é obrigatória")
+	}
+	for key := range image.Env {
+		if key == "" || strings.ContainsAny(key, "= ") {
+			return fmt.Errorf("variável de ambiente inválida na imagem: %q", key)
+		}
+	}
+
+	allowed, err := s.repo.ListAllowedImages(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao listar imagens permitidas: %w", err)
+	}
+	for _, a := range allowed {
+		if a.Matches(image.Reference) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrImageNotAllowed, image.Reference)
+}
@@ -0,0 +1,202 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+func TestQueuedRunUsesStateOfPreviousRun(t *testing.T) {
+	ctx := context.Background()
+	executor := &stateExecutor{gate: make(chan struct{})}
+	repo, labs, user := newTestLabs(t, executor, service.NewExecutionScheduler(4, 1, 0))
+	lab := createTestLab(t, labs, "Rede", "terraform")
+
+	first, err := labs.ExecuteLab(ctx, user.ID, lab.ID, "", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A segunda fica na fila até a primeira gravar o seu estado
+	second, err := labs.ExecuteLab(ctx, user.ID, lab.ID, "", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(executor.gate)
+
+	waitFinal(t, first)
+	waitFinal(t, second)
+
+	if got := executor.received(); !slices.Equal(got, []string{"", "+"}) {
+		t.Fatalf("estados recebidos pelo executor: %q, esperava [\"\" \"+\"]", got)
+	}
+	state, err := repo.GetWorkspaceState(ctx, first.WorkspaceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(state) != "++" {
+		t.Fatalf("estado final %q, esperava \"++\"", state)
+	}
+}
+
+func TestValidateLabReappliesUserCode(t *testing.T) {
+	ctx := context.Background()
+	executor := &stateExecutor{}
+	_, labs, user := newTestLabs(t, executor, service.NewExecutionScheduler(4, 1, 0))
+	lab, err := labs.CreateLab(ctx, "Shell", "linux", "", "", "", 0, "test -f /tmp/ok", domain.ResourceLimits{}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := labs.ExecuteLab(ctx, user.ID, lab.ID, "touch /tmp/ok", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFinal(t, run)
+
+	if run, err = labs.ValidateLab(ctx, user.ID, lab.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFinal(t, run)
+
+	config := executor.last()
+	if config.Code != "touch /tmp/ok" || config.ValidationCode != "test -f /tmp/ok" {
+		t.Fatalf("validação com código %q e validação %q, esperava o código do utilizador e a validação do lab", config.Code, config.ValidationCode)
+	}
+}
+
+func TestReplicasSerializeSameWorkspace(t *testing.T) {
+	ctx := context.Background()
+	executor := &stateExecutor{gate: make(chan struct{})}
+	repo, first, user := newTestLabs(t, executor, service.NewExecutionScheduler(4, 1, 0))
+	lab := createTestLab(t, first, "Rede", "terraform")
+
+	// Duas réplicas da API sobre a mesma base, cada uma com o seu scheduler
+	locks := &sharedLocks{WorkspaceRepository: repo}
+	first = service.NewLabService(locks, executor, service.NewExecutionScheduler(4, 1, 0))
+	second := service.NewLabService(locks, executor, service.NewExecutionScheduler(4, 1, 0))
+
+	a, err := first.ExecuteLab(ctx, user.ID, lab.ID, "", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	b, err := second.ExecuteLab(ctx, user.ID, lab.ID, "", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := executor.received(); len(got) != 1 {
+		t.Fatalf("%d execuções em simultâneo no mesmo workspace, esperava 1", len(got))
+	}
+	close(executor.gate)
+
+	waitFinal(t, a)
+	waitFinal(t, b)
+	if got := executor.received(); !slices.Equal(got, []string{"", "+"}) {
+		t.Fatalf("estados recebidos pelo executor: %q, esperava [\"\" \"+\"]", got)
+	}
+}
+
+func TestDeleteLabRequiredByOtherLab(t *testing.T) {
+	ctx := context.Background()
+	repo, labs, _ := newTestLabs(t, fakeExecutor{}, service.NewExecutionScheduler(4, 1, 0))
+	base := createTestLab(t, labs, "Base", "linux")
+	if _, err := labs.CreateLab(ctx, "Avançado", "linux", "", "", "", 0, "", domain.ResourceLimits{}, nil, nil, nil, []string{base.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	deletes := map[string]func(context.Context, string) error{
+		"DeleteLab": labs.DeleteLab,
+		"CleanLab":  labs.CleanLab,
+	}
+	for name, del := range deletes {
+		if err := del(ctx, base.ID); !errors.Is(err, service.ErrInvalidPrerequisites) {
+			t.Fatalf("%s de um pré-requisito: erro %v, esperava ErrInvalidPrerequisites", name, err)
+		}
+	}
+	if lab, err := repo.GetLabByID(ctx, base.ID); err != nil || lab == nil {
+		t.Fatalf("o pré-requisito foi apagado: %v, %v", lab, err)
+	}
+}
+
+func TestCancelExecution(t *testing.T) {
+	tests := []struct {
+		name   string
+		queued bool
+	}{
+		{"em execução", false},
+		{"na fila", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, labs, user := newTestLabs(t, blockingExecutor{}, service.NewExecutionScheduler(4, 1, 0))
+			lab := createTestLab(t, labs, "Linux", "linux")
+
+			var ahead *service.LiveExecution
+			if tt.queued {
+				var err error
+				if ahead, err = labs.ExecuteLab(ctx, user.ID, lab.ID, "echo 1", domain.FileChanges{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			run, err := labs.ExecuteLab(ctx, user.ID, lab.ID, "echo 2", domain.FileChanges{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			run.Cancel()
+
+			if final := waitFinal(t, run); final.Outcome != domain.OutcomeCancelled {
+				t.Fatalf("execução cancelada terminou com %q", final.Outcome)
+			}
+			if ahead != nil {
+				if !ahead.Running() {
+					t.Fatal("cancelar a execução na fila interrompeu a que estava à frente")
+				}
+				ahead.Cancel()
+				waitFinal(t, ahead)
+			}
+		})
+	}
+}
+
+func TestCreateLabChecksImageAllowList(t *testing.T) {
+	ctx := context.Background()
+	repo, labs, _ := newTestLabs(t, fakeExecutor{}, service.NewExecutionScheduler(4, 1, 0))
+	images := service.NewImageService(repo, nil)
+	for _, pattern := range []string{"hashicorp/terraform:*", "alpine:3.20"} {
+		if _, err := images.AllowImage(ctx, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		image   *domain.LabImage
+		wantErr error
+	}{
+		{"imagem padrão", nil, nil},
+		{"padrão com prefixo", &domain.LabImage{Reference: "hashicorp/terraform:1.9"}, nil},
+		{"referência exata", &domain.LabImage{Reference: "alpine:3.20"}, nil},
+		{"fora da lista", &domain.LabImage{Reference: "alpine:3.21"}, service.ErrImageNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := labs.CreateLab(ctx, tt.name, "linux", "", "", "", 0, "", domain.ResourceLimits{}, tt.image, nil, nil, nil)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("CreateLab: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateLab: erro %v, esperava %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// liveRetention é o tempo durante o qual uma execução terminada continua
+// disponível para "attach", com o seu estado final.
+const liveRetention = 10 * time.Minute
+
+// ErrExecutionNotLive indica que a execução existe no histórico mas já não
+// está em memória (terminou há mais de liveRetention ou noutro processo).
+var ErrExecutionNotLive = errors.New("a execução já não está ativa")
+
+// LiveExecution é uma execução desligada da conexão que a iniciou. O output
+// fica em memória para que um cliente possa voltar a ligar-se (Since) e o
+// estado final é sempre gravado, haja ou não clientes ligados.
+type LiveExecution struct {
+	ID          string
+	WorkspaceID string
+	UserID      string
+	Action      string
+
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	lines   []ExecutionResult
+	size    int
+	changed chan struct{}
+	final   *ExecutionFinalState
+}
+
+func newLiveExecution(userID, action, workspaceID string, cancel context.CancelFunc) *LiveExecution {
+	return &LiveExecution{
+		ID:          uuid.New().String(),
+		WorkspaceID: workspaceID,
+		UserID:      userID,
+		Action:      action,
+		cancel:      cancel,
+		changed:     make(chan struct{}),
+	}
+}
+
+// Since devolve as mensagens a partir de offset, um canal fechado na
+// próxima alteração e o estado final (nil enquanto a execução decorre).
+func (r *LiveExecution) Since(offset int) ([]ExecutionResult, <-chan struct{}, *ExecutionFinalState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var lines []ExecutionResult
+	if offset < len(r.lines) {
+		lines = append(lines, r.lines[offset:]...)
+	}
+	return lines, r.changed, r.final
+}
+
+// Running indica se a execução ainda não terminou.
+func (r *LiveExecution) Running() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.final == nil
+}
+
+// Cancel interrompe a execução.
+func (r *LiveExecution) Cancel() {
+	r.cancel()
+}
+
+// wait bloqueia até a execução terminar, com o estado final já gravado, ou
+// até ctx ser cancelado.
+func (r *LiveExecution) wait(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		final, changed := r.final, r.changed
+		r.mu.Unlock()
+		if final != nil {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *LiveExecution) publish(line ExecutionResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A memória de cada execução é limitada como o output do histórico
+	if r.size+len(line.Line) > domain.MaxExecutionOutput {
+		return
+	}
+	r.size += len(line.Line)
+	r.lines = append(r.lines, line)
+	r.notify()
+}
+
+func (r *LiveExecution) finish(state ExecutionFinalState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.final = &state
+	r.notify()
+}
+
+// notify acorda quem espera em Since. Chamado com mu bloqueado.
+func (r *LiveExecution) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// launch inicia a execução desligada do contexto do pedido: só termina
+// sozinha ou com Cancel. O estado final é gravado no workspace (ver
+// scheduleOn) antes de ser publicado.
+func (s *LabService) launch(ctx context.Context, userID, action string, config domain.ExecutionConfig, rev *domain.CodeRevision) *LiveExecution {
+	return s.launchOn(ctx, s.executor, s.scheduler, userID, action, config, rev)
+}
+
+// launchOn é o launch com outro executor (ver scheduleOn).
+func (s *LabService) launchOn(ctx context.Context, executor Executor, scheduler *ExecutionScheduler, userID, action string, config domain.ExecutionConfig, rev *domain.CodeRevision) *LiveExecution {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	run := newLiveExecution(userID, action, config.WorkspaceID, cancel)
+
+	s.liveMu.Lock()
+	s.live[run.ID] = run
+	s.liveMu.Unlock()
+
+	logStream, finalState := s.scheduleOn(runCtx, executor, scheduler, run.ID, userID, action, config)
+	if rev != nil {
+		finalState = s.trackRevision(runCtx, rev, finalState)
+	}
+
+	go func() {
+		defer cancel()
+
+		final := ExecutionFinalState{
+			WorkspaceID: config.WorkspaceID,
+			Error:       fmt.Errorf("execução terminou sem estado final"),
+			Outcome:     domain.OutcomeFailed,
+		}
+		for logStream != nil || finalState != nil {
+			select {
+			case line, ok := <-logStream:
+				if !ok {
+					logStream = nil
+					continue
+				}
+				run.publish(line)
+			case state, ok := <-finalState:
+				if !ok {
+					finalState = nil
+					continue
+				}
+				final = state
+			}
+		}
+
+		run.finish(final)
+
+		time.AfterFunc(liveRetention, func() {
+			s.liveMu.Lock()
+			delete(s.live, run.ID)
+			s.liveMu.Unlock()
+		})
+	}()
+
+	return run
+}
+
+// AttachExecution devolve uma execução em memória para o cliente voltar a
+// receber o seu output. Devolve ErrExecutionNotLive se ela só existir no
+// histórico.
+func (s *LabService) AttachExecution(ctx context.Context, viewer *domain.User, id string) (*LiveExecution, error) {
+	s.liveMu.Lock()
+	run, ok := s.live[id]
+	s.liveMu.Unlock()
+
+	if !ok {
+		if _, err := s.GetExecution(ctx, viewer, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrExecutionNotLive
+	}
+	if err := s.canView(ctx, viewer, run.WorkspaceID); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// cancelWorkspaceRuns interrompe as execuções em curso no workspace e
+// aguarda que terminem.
+func (s *LabService) cancelWorkspaceRuns(ctx context.Context, workspaceID string) error {
+	s.liveMu.Lock()
+	var running []*LiveExecution
+	for _, run := range s.live {
+		if run.WorkspaceID == workspaceID && run.Running() {
+			running = append(running, run)
+		}
+	}
+	s.liveMu.Unlock()
+
+	for _, run := range running {
+		run.Cancel()
+		if err := run.wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workspaceRunning indica se há uma execução em curso no workspace.
+func (s *LabService) workspaceRunning(workspaceID string) bool {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+	for _, run := range s.live {
+		if run.WorkspaceID == workspaceID && run.Running() {
+			return true
+		}
+	}
+	return false
+}
+
+// persistFinalState grava no workspace o resultado de uma execução: o
+// estado do Terraform, o relatório das verificações (com a penalização das
+// dicas, aplicada ao próprio relatório) e a conclusão do lab.
+func (s *LabService) persistFinalState(ctx context.Context, action, wsID string, state *ExecutionFinalState) {
+	switch {
+	case state.Outcome == domain.OutcomeCancelled || state.Outcome == domain.OutcomeTimeout || state.Outcome == domain.OutcomeOOM:
+		// O Terraform pode ter criado recursos antes da interrupção
+		if state.NewState != nil {
+			s.logPersistError(s.SaveWorkspaceState(ctx, wsID, state.NewState))
+		}
+		return
+	case state.Error != nil, state.Plan != nil:
+		return
+	case action == domain.ActionDestroy:
+		// Grava o estado sem os recursos destruídos
+		s.logPersistError(s.SaveWorkspaceState(ctx, wsID, state.NewState))
+		return
+	}
+
+	if state.Report != nil {
+		s.logPersistError(s.SaveValidationReport(ctx, wsID, state.Report))
+	}
+	if state.ValidationPassed() {
+		log.Printf("INFO [LabService]: Lab validado! Salvando status completed (workspace %s).", wsID)
+		s.logPersistError(s.SaveWorkspaceStatus(ctx, wsID, domain.WorkspaceStatusCompleted))
+	}
+	if state.NewState != nil {
+		s.logPersistError(s.SaveWorkspaceState(ctx, wsID, state.NewState))
+	}
+}
+
+func (s *LabService) logPersistError(err error) {
+	if err != nil {
+		log.Printf("ERRO [LabService]: %v", err)
+	}
+}
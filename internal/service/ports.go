@@ -2,37 +2,147 @@ package service
 
 import (
 	"context"
+	"io"
 	"lab-devops/internal/domain"
 )
 
 type ExecutionResult struct {
 	Line string
 	Err  error
+
+	// QueuePosition é maior que zero enquanto a execução aguarda na fila
+	QueuePosition int
 }
 
 type ExecutionFinalState struct {
 	WorkspaceID      string
 	NewState         []byte
 	Error            error
+	Outcome          string
 	ExecutionResult  domain.StepResult
 	ValidationResult domain.StepResult
+	// Validated indica se o passo de validação chegou a correr.
+	Validated bool
+	// Report traz o resultado das verificações estruturadas do lab, se houver.
+	Report *domain.ValidationReport
+	// Plan é o resumo do plano quando a execução foi um "terraform plan".
+	Plan *domain.PlanSummary
+}
+
+// ValidationPassed indica se a validação correu e passaram tanto o script
+// como as verificações estruturadas.
+func (s ExecutionFinalState) ValidationPassed() bool {
+	return s.Validated && s.ValidationResult.ExitCode == 0 && s.ValidationResult.Error == nil &&
+		(s.Report == nil || s.Report.Passed)
+}
+
+// PoolStats descreve o pool de containers pré-aquecidos de um tipo de lab.
+type PoolStats struct {
+	Type     string `json:"type"`
+	Image    string `json:"image"`
+	Idle     int    `json:"idle"`
+	Starting int    `json:"starting"`
+	Target   int    `json:"target"`
+	Hits     int64  `json:"hits"`
+	Misses   int64  `json:"misses"`
+}
+
+// PoolStatsProvider é implementado por executores com pool de containers.
+type PoolStatsProvider interface {
+	PoolStats() []PoolStats
+}
+
+// ImagePullProgress é o progresso agregado do download de uma imagem.
+type ImagePullProgress struct {
+	Status  string
+	Current int64
+	Total   int64
+}
+
+// ImageManager gere as imagens usadas pelo executor.
+type ImageManager interface {
+	// PullImage baixa a imagem e devolve o seu digest (sha256:...).
+	PullImage(ctx context.Context, reference string, onProgress func(ImagePullProgress)) (string, error)
+	// ImageDigest devolve o digest da imagem local; present é falso se ela não existir.
+	ImageDigest(ctx context.Context, reference string) (digest string, present bool, err error)
+	// UseImage define a imagem (já fixada por digest) usada para o tipo de lab.
+	UseImage(execType domain.ExecutionType, reference string)
 }
 
 type Executor interface {
 	Execute(ctx context.Context, config domain.ExecutionConfig) (<-chan ExecutionResult, <-chan ExecutionFinalState, error)
 }
 
+// Terminal é um shell interativo (TTY) no container de um lab: Read devolve
+// o output e Write envia as teclas. Execute corre a validação do lab no
+// mesmo container, sobre o que o utilizador lá fez.
+type Terminal interface {
+	Executor
+	io.ReadWriteCloser
+	Resize(cols, rows uint) error
+}
+
+// TerminalProvider é implementado por executores que abrem terminais.
+type TerminalProvider interface {
+	OpenTerminal(ctx context.Context, config domain.ExecutionConfig) (Terminal, error)
+}
+
+// Session é um container de lab que sobrevive entre execuções. Execute
+// corre nele cada passo sem o remover, incluindo domain.ModeCommand e
+// domain.ModeValidate; Close remove o container.
+type Session interface {
+	Executor
+	io.Closer
+	OpenShell(ctx context.Context, config domain.ExecutionConfig) (Terminal, error)
+}
+
+// SessionProvider é implementado por executores com sessões de lab.
+type SessionProvider interface {
+	StartSession(ctx context.Context, config domain.ExecutionConfig) (Session, error)
+}
+
+// CatalogSource é o repositório Git com os lab packs do catálogo.
+type CatalogSource interface {
+	// Checkout resolve ref para um commit e extrai em dir os ficheiros dos
+	// packs nesse commit.
+	Checkout(ctx context.Context, ref, dir string) (commit string, err error)
+	// String identifica o repositório nos registos de sincronização.
+	String() string
+}
+
 type WorkspaceRepository interface {
 	GetLabByID(ctx context.Context, labID string) (*domain.Lab, error)
 	ListLabs(ctx context.Context) ([]*domain.Lab, error)
-	GetWorkspaceByLabID(ctx context.Context, labID string) (*domain.Workspace, error)
+	GetWorkspaceByUserAndLab(ctx context.Context, userID, labID string) (*domain.Workspace, error)
+	GetWorkspaceByID(ctx context.Context, workspaceID string) (*domain.Workspace, error)
 	UpdateWorkspaceCode(ctx context.Context, workspaceId string, code string) error
 	UpdateWorkspaceState(ctx context.Context, workspaceId string, state []byte) error
 	GetWorkspaceState(ctx context.Context, workspaceId string) ([]byte, error)
-	CreateWorkspace(ctx context.Context, labId string) (*domain.Workspace, error)
+	ListStateVersions(ctx context.Context, workspaceID string) ([]*domain.StateVersion, error)
+	GetStateVersion(ctx context.Context, workspaceID string, version int) (*domain.StateVersion, error)
+	RestoreStateVersion(ctx context.Context, workspaceID string, version int) (*domain.StateVersion, error)
+	CreateWorkspace(ctx context.Context, userID, labId string) (*domain.Workspace, error)
 	CreateLab(ctx context.Context, lab *domain.Lab) error
 	CleanLab(ctx context.Context, labId string) error
 	UpdateWorkspaceStatus(ctx context.Context, workspaceId string, status string) error
+	ListCompletedLabIDs(ctx context.Context, userID string) ([]string, error)
+	UpdateWorkspaceReport(ctx context.Context, workspaceID string, report *domain.ValidationReport) error
+	ResetWorkspace(ctx context.Context, workspaceID string, initialCode string) error
+	CreateExecution(ctx context.Context, rec *domain.ExecutionRecord) error
+	FinishExecution(ctx context.Context, rec *domain.ExecutionRecord) error
+	ListExecutions(ctx context.Context, workspaceID string) ([]*domain.ExecutionRecord, error)
+	GetExecution(ctx context.Context, id string) (*domain.ExecutionRecord, error)
+	CreateWorkspaceRevision(ctx context.Context, rev *domain.CodeRevision) error
+	UpdateRevisionOutcome(ctx context.Context, workspaceID string, revision int, outcome string) error
+	ListWorkspaceRevisions(ctx context.Context, workspaceID string) ([]*domain.CodeRevision, error)
+	GetWorkspaceRevision(ctx context.Context, workspaceID string, revision int) (*domain.CodeRevision, error)
+	ListWorkspaceHints(ctx context.Context, workspaceID string) ([]*domain.RevealedHint, error)
+	CreateWorkspaceHint(ctx context.Context, workspaceID string, hint *domain.RevealedHint) error
+
+	ListWorkspaceFiles(ctx context.Context, workspaceID string) ([]*domain.WorkspaceFile, error)
+	UpsertWorkspaceFile(ctx context.Context, workspaceID, path, content string) error
+	DeleteWorkspaceFile(ctx context.Context, workspaceID, path string) error
+	ReplaceWorkspaceFiles(ctx context.Context, workspaceID string, files map[string]string) error
 
 	ListTracks(ctx context.Context) ([]*domain.Track, error)
 	ListLabsByTrackID(ctx context.Context, trackID string) ([]*domain.Lab, error)
@@ -42,5 +152,36 @@ type WorkspaceRepository interface {
 	UpdateTrack(ctx context.Context, track *domain.Track) error
 	DeleteTrack(ctx context.Context, trackID string) error
 	GetTrackByID(ctx context.Context, id string) (*domain.Track, error)
+
+	CreateUser(ctx context.Context, user *domain.User) error
+	GetUserByID(ctx context.Context, userID string) (*domain.User, error)
+	ListUsers(ctx context.Context) ([]*domain.User, error)
+	UpdateUserRole(ctx context.Context, userID string, role string) error
+
+	CreateAPIToken(ctx context.Context, token *domain.APIToken) error
+	GetUserByTokenHash(ctx context.Context, tokenHash string) (*domain.User, error)
+	ListAPITokens(ctx context.Context) ([]*domain.APIToken, error)
+	DeleteAPIToken(ctx context.Context, tokenID string) error
+
+	ListImages(ctx context.Context) ([]*domain.ImageCatalogEntry, error)
+	UpsertImage(ctx context.Context, img *domain.ImageCatalogEntry) error
+
+	ListAllowedImages(ctx context.Context) ([]*domain.AllowedImage, error)
+	CreateAllowedImage(ctx context.Context, allowed *domain.AllowedImage) error
+	DeleteAllowedImage(ctx context.Context, id string) error
+
+	CreateCatalogSync(ctx context.Context, sync *domain.CatalogSync) error
+	// ApplyCatalogWrites aplica as escritas e regista sync (se não for nil)
+	// numa única transação.
+	ApplyCatalogWrites(ctx context.Context, writes *domain.CatalogWrites, sync *domain.CatalogSync) error
+	GetLastCatalogSync(ctx context.Context) (*domain.CatalogSync, error)
+	ListCatalogSyncs(ctx context.Context, limit int) ([]*domain.CatalogSync, error)
 	Ping(ctx context.Context) error
+
+	// Lock obtém um lock partilhado por todas as réplicas da API que usam a
+	// base, bloqueando até estar livre ou ctx ser cancelado. A função
+	// devolvida liberta-o.
+	Lock(ctx context.Context, name string) (unlock func(), err error)
+	// TryLock é o Lock sem espera: ok é falso se outra réplica o tiver.
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
 }
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"strings"
+)
+
+var (
+	// ErrLabLocked indica um lab cujos pré-requisitos o utilizador ainda não concluiu.
+	ErrLabLocked = errors.New("lab bloqueado")
+	// ErrInvalidPrerequisites indica pré-requisitos inexistentes ou em ciclo.
+	ErrInvalidPrerequisites = errors.New("pré-requisitos inválidos")
+)
+
+// LabDetails é um lab com o workspace do utilizador e o seu bloqueio. Um lab
+// está bloqueado enquanto faltar concluir algum dos seus pré-requisitos.
+type LabDetails struct {
+	Lab                  *domain.Lab       `json:"lab"`
+	Workspace            *domain.Workspace `json:"workspace"`
+	Locked               bool              `json:"locked"`
+	MissingPrerequisites []domain.LabRef   `json:"missing_prerequisites"`
+}
+
+// missingPrerequisites devolve os labs que o utilizador ainda tem de concluir
+// antes de lab, pela ordem em que devem ser feitos. Autores e administradores
+// nunca ficam bloqueados, e os pré-requisitos entretanto apagados não contam.
+func (s *LabService) missingPrerequisites(ctx context.Context, userID string, lab *domain.Lab) ([]domain.LabRef, error) {
+	var track *domain.Track
+	if lab.TrackID != "" {
+		var err error
+		track, err = s.repo.GetTrackByID(ctx, lab.TrackID)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao buscar a trilha %s: %w", lab.TrackID, err)
+		}
+		if track != nil && track.Sequential {
+			if track.Labs, err = s.repo.ListLabsByTrackID(ctx, track.ID); err != nil {
+				return nil, fmt.Errorf("falha ao listar os labs da trilha %s: %w", track.ID, err)
+			}
+		}
+	}
+	required := domain.RequiredLabs(lab, track)
+	if len(required) == 0 {
+		return nil, nil
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil && user.HasRole(domain.RoleAdmin, domain.RoleAuthor) {
+		return nil, nil
+	}
+
+	completedIDs, err := s.repo.ListCompletedLabIDs(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar os labs concluídos: %w", err)
+	}
+	completed := make(map[string]bool, len(completedIDs))
+	for _, id := range completedIDs {
+		completed[id] = true
+	}
+
+	var missing []domain.LabRef
+	for _, id := range required {
+		if completed[id] {
+			continue
+		}
+		prerequisite, err := s.repo.GetLabByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if prerequisite != nil {
+			missing = append(missing, domain.LabRef{ID: prerequisite.ID, Title: prerequisite.Title})
+		}
+	}
+	return missing, nil
+}
+
+// checkUnlocked devolve ErrLabLocked se o utilizador ainda não pode fazer o lab.
+func (s *LabService) checkUnlocked(ctx context.Context, userID string, lab *domain.Lab) error {
+	missing, err := s.missingPrerequisites(ctx, userID, lab)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	titles := make([]string, len(missing))
+	for i, ref := range missing {
+		titles[i] = ref.Title
+	}
+	return fmt.Errorf("%w: falta concluir %s", ErrLabLocked, strings.Join(titles, ", "))
+}
+
+// checkPrerequisites valida os pré-requisitos dos labs e trilhas alterados,
+// aplicados por cima dos que já estão na base: todos os labs declarados têm
+// de existir e o conjunto não pode ter ciclos. Os labs de deleted são
+// apagados no mesmo passo, pelo que nenhum dos restantes pode depender deles.
+func (s *LabService) checkPrerequisites(ctx context.Context, labs []*domain.Lab, tracks []*domain.Track, deleted []string) error {
+	existingLabs, err := s.repo.ListLabs(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao listar labs: %w", err)
+	}
+	existingTracks, err := s.repo.ListTracks(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao listar trilhas: %w", err)
+	}
+
+	allLabs := overlay(existingLabs, labs, func(l *domain.Lab) string { return l.ID })
+	allTracks := overlay(existingTracks, tracks, func(t *domain.Track) string { return t.ID })
+
+	if len(deleted) > 0 {
+		gone := make(map[string]bool, len(deleted))
+		for _, id := range deleted {
+			gone[id] = true
+		}
+		remaining := make([]*domain.Lab, 0, len(allLabs))
+		for _, lab := range allLabs {
+			if gone[lab.ID] {
+				continue
+			}
+			for _, id := range lab.Prerequisites {
+				if gone[id] {
+					return fmt.Errorf("%w: o lab %s depende do lab %s, que vai ser apagado", ErrInvalidPrerequisites, lab.ID, id)
+				}
+			}
+			remaining = append(remaining, lab)
+		}
+		allLabs = remaining
+	}
+
+	known := make(map[string]bool, len(allLabs))
+	for _, lab := range allLabs {
+		known[lab.ID] = true
+	}
+	for _, lab := range labs {
+		for _, id := range lab.Prerequisites {
+			if id == lab.ID {
+				return fmt.Errorf("%w: o lab %s não pode depender de si próprio", ErrInvalidPrerequisites, lab.ID)
+			}
+			if !known[id] {
+				return fmt.Errorf("%w: o lab %s depende do lab %s, que não existe", ErrInvalidPrerequisites, lab.ID, id)
+			}
+		}
+	}
+	if cycle := domain.PrerequisiteCycle(allLabs, allTracks); cycle != nil {
+		return fmt.Errorf("%w: ciclo %s", ErrInvalidPrerequisites, strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// overlay devolve base com os itens de changes no lugar dos de mesmo ID, e os
+// restantes de changes no fim.
+func overlay[T any](base, changes []T, id func(T) string) []T {
+	index := make(map[string]int, len(base))
+	result := make([]T, len(base), len(base)+len(changes))
+	for i, item := range base {
+		index[id(item)] = i
+		result[i] = item
+	}
+	for _, item := range changes {
+		if i, ok := index[id(item)]; ok {
+			result[i] = item
+		} else {
+			result = append(result, item)
+		}
+	}
+	return result
+}
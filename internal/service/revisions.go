@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"log"
+	"sort"
+	"strings"
+)
+
+// ErrRevisionNotFound indica que a revisão de código pedida não existe.
+var ErrRevisionNotFound = errors.New("revisão não encontrada")
+
+// snapshotRevision grava o código submetido numa execução como uma nova
+// revisão do workspace.
+func (s *LabService) snapshotRevision(ctx context.Context, workspaceID string, mode domain.ExecutionMode, code string, files map[string]string) (*domain.CodeRevision, error) {
+	rev := &domain.CodeRevision{
+		WorkspaceID: workspaceID,
+		Mode:        mode,
+		Code:        code,
+		Files:       files,
+	}
+	if err := s.repo.CreateWorkspaceRevision(ctx, rev); err != nil {
+		return nil, fmt.Errorf("falha ao gravar revisão do workspace %s: %w", workspaceID, err)
+	}
+	return rev, nil
+}
+
+// trackRevision reencaminha o estado final de uma execução, registando o
+// seu desfecho na revisão correspondente.
+func (s *LabService) trackRevision(ctx context.Context, rev *domain.CodeRevision, finalState <-chan ExecutionFinalState) <-chan ExecutionFinalState {
+	out := make(chan ExecutionFinalState, 1)
+	go func() {
+		defer close(out)
+		for state := range finalState {
+			// O desfecho é gravado mesmo que o cliente já tenha saído
+			if err := s.repo.UpdateRevisionOutcome(context.WithoutCancel(ctx), rev.WorkspaceID, rev.Revision, state.Outcome); err != nil {
+				log.Printf("ERRO [LabService]: Falha ao gravar desfecho da revisão %d do workspace %s: %v", rev.Revision, rev.WorkspaceID, err)
+			}
+			out <- state
+		}
+	}()
+	return out
+}
+
+// ListRevisions devolve as revisões do código do utilizador, da mais
+// recente para a mais antiga.
+func (s *LabService) ListRevisions(ctx context.Context, userID, labID string) ([]*domain.CodeRevision, error) {
+	_, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.repo.ListWorkspaceRevisions(ctx, ws.ID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar revisões do workspace %s: %w", ws.ID, err)
+	}
+	if revisions == nil {
+		revisions = []*domain.CodeRevision{}
+	}
+	return revisions, nil
+}
+
+// GetRevision devolve uma revisão com o código e os ficheiros submetidos.
+func (s *LabService) GetRevision(ctx context.Context, userID, labID string, revision int) (*domain.CodeRevision, error) {
+	_, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	return s.revision(ctx, ws.ID, revision)
+}
+
+// DiffRevisions devolve o diff unificado entre duas revisões, ficheiro a
+// ficheiro.
+func (s *LabService) DiffRevisions(ctx context.Context, userID, labID string, from, to int) (string, error) {
+	lab, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return "", err
+	}
+
+	before, err := s.revision(ctx, ws.ID, from)
+	if err != nil {
+		return "", err
+	}
+	after, err := s.revision(ctx, ws.ID, to)
+	if err != nil {
+		return "", err
+	}
+
+	entry := domain.EntryFile(domain.ExecutionType(lab.Type))
+	oldTree, newTree := revisionTree(before, entry), revisionTree(after, entry)
+
+	paths := make([]string, 0, len(oldTree)+len(newTree))
+	for p := range oldTree {
+		paths = append(paths, p)
+	}
+	for p := range newTree {
+		if _, ok := oldTree[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var diff strings.Builder
+	for _, p := range paths {
+		oldContent, inOld := oldTree[p]
+		newContent, inNew := newTree[p]
+		fromName, toName := "a/"+p, "b/"+p
+		if !inOld {
+			fromName = "/dev/null"
+		}
+		if !inNew {
+			toName = "/dev/null"
+		}
+		diff.WriteString(domain.UnifiedDiff(fromName, toName, oldContent, newContent))
+	}
+	return diff.String(), nil
+}
+
+// RestoreRevision repõe o código e os ficheiros de uma revisão como o
+// conteúdo atual do workspace.
+func (s *LabService) RestoreRevision(ctx context.Context, userID, labID string, revision int) (*domain.Workspace, error) {
+	lab, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := s.revision(ctx, ws.ID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := domain.EntryFile(domain.ExecutionType(lab.Type))
+	changes := domain.FileChanges{Replace: true, Files: revisionTree(rev, entry)}
+	if _, err := s.applyFileChanges(ctx, lab, ws, "", changes); err != nil {
+		return nil, err
+	}
+
+	log.Printf("INFO [LabService]: Workspace %s reposto para a revisão %d", ws.ID, revision)
+	_, ws, err = s.labDetails(ctx, userID, labID)
+	return ws, err
+}
+
+func (s *LabService) revision(ctx context.Context, workspaceID string, revision int) (*domain.CodeRevision, error) {
+	rev, err := s.repo.GetWorkspaceRevision(ctx, workspaceID, revision)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar a revisão %d do workspace %s: %w", revision, workspaceID, err)
+	}
+	if rev == nil {
+		return nil, ErrRevisionNotFound
+	}
+	return rev, nil
+}
+
+// revisionTree devolve todos os ficheiros de uma revisão, incluindo o
+// ficheiro principal.
+func revisionTree(rev *domain.CodeRevision, entry string) map[string]string {
+	tree := make(map[string]string, len(rev.Files)+1)
+	for p, content := range rev.Files {
+		tree[p] = content
+	}
+	tree[entry] = rev.Code
+	return tree
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// ExecutionScheduler limita as execuções simultâneas: no máximo uma por
+// workspace (o diretório de execução é partilhado), maxPerUser por utilizador
+// e maxGlobal containers no total. Os terminais interativos ocupam o
+// workspace mas contam à parte, até maxInteractive: ficam abertos à espera
+// do utilizador e não devem impedi-lo de correr código noutros labs. Os
+// pedidos que excedem os limites aguardam numa fila FIFO; um pedido
+// bloqueado não impede os seguintes de avançar.
+type ExecutionScheduler struct {
+	mu             sync.Mutex
+	maxGlobal      int
+	maxPerUser     int
+	maxInteractive int
+
+	running          int
+	runningByUser    map[string]int
+	runningWorkspace map[string]bool
+	interactive      int
+	queue            []*schedulerTicket
+}
+
+type schedulerTicket struct {
+	userID      string
+	workspaceID string
+	interactive bool
+	granted     bool
+	lastPos     int
+	ready       chan struct{}
+	position    chan int
+}
+
+// SchedulerStats é uma fotografia do estado do scheduler.
+type SchedulerStats struct {
+	Running    int `json:"running"`
+	Queued     int `json:"queued"`
+	MaxGlobal  int `json:"max_global"`
+	MaxPerUser int `json:"max_per_user"`
+
+	Interactive    int `json:"interactive"`
+	MaxInteractive int `json:"max_interactive"`
+}
+
+// NewExecutionScheduler cria o scheduler. Limites menores ou iguais a zero
+// desativam o respetivo controlo.
+func NewExecutionScheduler(maxGlobal, maxPerUser, maxInteractive int) *ExecutionScheduler {
+	return &ExecutionScheduler{
+		maxGlobal:        maxGlobal,
+		maxPerUser:       maxPerUser,
+		maxInteractive:   maxInteractive,
+		runningByUser:    make(map[string]int),
+		runningWorkspace: make(map[string]bool),
+	}
+}
+
+// Acquire reserva um slot de execução, bloqueando até que os limites o
+// permitam ou o contexto seja cancelado. Enquanto aguarda, onQueued recebe a
+// posição atual na fila (1 = próximo). A função devolvida liberta o slot.
+func (s *ExecutionScheduler) Acquire(ctx context.Context, userID, workspaceID string, onQueued func(position int)) (func(), error) {
+	return s.acquire(ctx, &schedulerTicket{userID: userID, workspaceID: workspaceID}, onQueued)
+}
+
+// AcquireInteractive é o Acquire de um terminal interativo: reserva o
+// workspace, mas conta para maxInteractive em vez de maxGlobal e maxPerUser.
+func (s *ExecutionScheduler) AcquireInteractive(ctx context.Context, userID, workspaceID string, onQueued func(position int)) (func(), error) {
+	return s.acquire(ctx, &schedulerTicket{userID: userID, workspaceID: workspaceID, interactive: true}, onQueued)
+}
+
+func (s *ExecutionScheduler) acquire(ctx context.Context, t *schedulerTicket, onQueued func(position int)) (func(), error) {
+	t.ready = make(chan struct{})
+	t.position = make(chan int, 1)
+
+	s.mu.Lock()
+	s.queue = append(s.queue, t)
+	s.dispatchLocked()
+	s.mu.Unlock()
+
+	for {
+		select {
+		case <-t.ready:
+			return s.releaseFunc(t), nil
+		case pos := <-t.position:
+			if onQueued != nil {
+				onQueued(pos)
+			}
+		case <-ctx.Done():
+			s.mu.Lock()
+			if t.granted {
+				// O slot foi concedido ao mesmo tempo que o cancelamento
+				s.releaseLocked(t)
+			} else {
+				s.removeLocked(t)
+			}
+			s.dispatchLocked()
+			s.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *ExecutionScheduler) Stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SchedulerStats{
+		Running:    s.running,
+		Queued:     len(s.queue),
+		MaxGlobal:  s.maxGlobal,
+		MaxPerUser: s.maxPerUser,
+
+		Interactive:    s.interactive,
+		MaxInteractive: s.maxInteractive,
+	}
+}
+
+func (s *ExecutionScheduler) releaseFunc(t *schedulerTicket) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.releaseLocked(t)
+			s.dispatchLocked()
+			s.mu.Unlock()
+		})
+	}
+}
+
+func (s *ExecutionScheduler) canRunLocked(t *schedulerTicket) bool {
+	if s.runningWorkspace[t.workspaceID] {
+		return false
+	}
+	if t.interactive {
+		return s.maxInteractive <= 0 || s.interactive < s.maxInteractive
+	}
+	if s.maxGlobal > 0 && s.running >= s.maxGlobal {
+		return false
+	}
+	if s.maxPerUser > 0 && s.runningByUser[t.userID] >= s.maxPerUser {
+		return false
+	}
+	return true
+}
+
+// dispatchLocked concede slots aos pedidos elegíveis, por ordem de chegada,
+// e publica a nova posição dos que continuam na fila.
+func (s *ExecutionScheduler) dispatchLocked() {
+	waiting := s.queue[:0]
+	for _, t := range s.queue {
+		if s.canRunLocked(t) {
+			if t.interactive {
+				s.interactive++
+			} else {
+				s.running++
+				s.runningByUser[t.userID]++
+			}
+			s.runningWorkspace[t.workspaceID] = true
+			t.granted = true
+			close(t.ready)
+			continue
+		}
+		waiting = append(waiting, t)
+	}
+	for i := len(waiting); i < len(s.queue); i++ {
+		s.queue[i] = nil
+	}
+	s.queue = waiting
+
+	for i, t := range s.queue {
+		if t.lastPos == i+1 {
+			continue
+		}
+		t.lastPos = i + 1
+		// Mantém apenas a posição mais recente no canal
+		select {
+		case <-t.position:
+		default:
+		}
+		t.position <- i + 1
+	}
+}
+
+func (s *ExecutionScheduler) releaseLocked(t *schedulerTicket) {
+	if t.interactive {
+		s.interactive--
+	} else {
+		s.running--
+		if s.runningByUser[t.userID]--; s.runningByUser[t.userID] <= 0 {
+			delete(s.runningByUser, t.userID)
+		}
+	}
+	delete(s.runningWorkspace, t.workspaceID)
+}
+
+func (s *ExecutionScheduler) removeLocked(t *schedulerTicket) {
+	for i, queued := range s.queue {
+		if queued == t {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
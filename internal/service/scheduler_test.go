@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// acquireFunc é ExecutionScheduler.Acquire ou AcquireInteractive.
+type acquireFunc func(ctx context.Context, userID, workspaceID string, onQueued func(position int)) (func(), error)
+
+// acquireAsync pede um slot numa goroutine: o canal devolvido recebe a
+// função de release quando o slot é concedido, ou fecha se o pedido falhar.
+func acquireAsync(acquire acquireFunc, ctx context.Context, userID, workspaceID string) (<-chan func(), <-chan int) {
+	granted := make(chan func(), 1)
+	positions := make(chan int, 16)
+	go func() {
+		release, err := acquire(ctx, userID, workspaceID, func(pos int) { positions <- pos })
+		if err == nil {
+			granted <- release
+		}
+		close(granted)
+	}()
+	return granted, positions
+}
+
+func mustAcquire(s *ExecutionScheduler, ctx context.Context, userID, workspaceID string) <-chan func() {
+	granted, _ := acquireAsync(s.Acquire, ctx, userID, workspaceID)
+	return granted
+}
+
+func acquireInteractive(s *ExecutionScheduler, ctx context.Context, userID, workspaceID string) <-chan func() {
+	granted, _ := acquireAsync(s.AcquireInteractive, ctx, userID, workspaceID)
+	return granted
+}
+
+func expectGranted(t *testing.T, ch <-chan func()) func() {
+	t.Helper()
+	select {
+	case release, ok := <-ch:
+		if !ok {
+			t.Fatal("acquire falhou")
+		}
+		return release
+	case <-time.After(time.Second):
+		t.Fatal("slot não foi concedido")
+	}
+	return nil
+}
+
+func expectWaiting(t *testing.T, ch <-chan func()) {
+	t.Helper()
+	select {
+	case <-ch:
+		t.Fatal("slot concedido indevidamente")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSchedulerSerializesSameWorkspace(t *testing.T) {
+	s := NewExecutionScheduler(10, 10, 0)
+	ctx := context.Background()
+
+	first := expectGranted(t, mustAcquire(s, ctx, "u1", "ws1"))
+	second, positions := acquireAsync(s.Acquire, ctx, "u1", "ws1")
+	expectWaiting(t, second)
+
+	if pos := <-positions; pos != 1 {
+		t.Fatalf("posição esperada 1, obtida %d", pos)
+	}
+
+	// Outro workspace do mesmo utilizador não fica bloqueado
+	other := expectGranted(t, mustAcquire(s, ctx, "u1", "ws2"))
+
+	first()
+	expectGranted(t, second)()
+	other()
+
+	if stats := s.Stats(); stats.Running != 0 || stats.Queued != 0 {
+		t.Fatalf("scheduler deveria estar vazio: %+v", stats)
+	}
+}
+
+func TestSchedulerGlobalAndPerUserLimits(t *testing.T) {
+	s := NewExecutionScheduler(2, 1, 0)
+	ctx := context.Background()
+
+	a := expectGranted(t, mustAcquire(s, ctx, "u1", "ws1"))
+
+	// Limite por utilizador
+	sameUser, _ := acquireAsync(s.Acquire, ctx, "u1", "ws2")
+	expectWaiting(t, sameUser)
+
+	b := expectGranted(t, mustAcquire(s, ctx, "u2", "ws3"))
+
+	// Limite global
+	third, positions := acquireAsync(s.Acquire, ctx, "u3", "ws4")
+	expectWaiting(t, third)
+	if pos := <-positions; pos != 2 {
+		t.Fatalf("posição esperada 2, obtida %d", pos)
+	}
+
+	b()
+	// u1 continua no limite; u3 avança mesmo estando atrás na fila
+	expectGranted(t, third)()
+	a()
+	expectGranted(t, sameUser)()
+}
+
+func TestSchedulerCancelWhileQueued(t *testing.T) {
+	s := NewExecutionScheduler(1, 0, 0)
+	release := expectGranted(t, mustAcquire(s, context.Background(), "u1", "ws1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued, _ := acquireAsync(s.Acquire, ctx, "u2", "ws2")
+	expectWaiting(t, queued)
+	cancel()
+
+	if _, ok := <-queued; ok {
+		t.Fatal("acquire cancelado não deveria conceder slot")
+	}
+	if stats := s.Stats(); stats.Queued != 0 {
+		t.Fatalf("pedido cancelado deveria sair da fila: %+v", stats)
+	}
+	release()
+}
+
+func TestSchedulerInteractiveLimit(t *testing.T) {
+	s := NewExecutionScheduler(1, 1, 1)
+	ctx := context.Background()
+
+	terminal := expectGranted(t, acquireInteractive(s, ctx, "u1", "ws1"))
+
+	// O terminal não conta para os limites das execuções...
+	run := expectGranted(t, mustAcquire(s, ctx, "u1", "ws2"))
+
+	// ...mas ocupa o seu workspace e o limite dos terminais
+	sameWorkspace, _ := acquireAsync(s.Acquire, ctx, "u2", "ws1")
+	expectWaiting(t, sameWorkspace)
+	second := acquireInteractive(s, ctx, "u2", "ws3")
+	expectWaiting(t, second)
+
+	terminal()
+	expectGranted(t, second)()
+	run()
+	expectGranted(t, sameWorkspace)()
+
+	if stats := s.Stats(); stats.Running != 0 || stats.Interactive != 0 || stats.Queued != 0 {
+		t.Fatalf("scheduler deveria estar vazio: %+v", stats)
+	}
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSessionUnavailable indica que o executor não suporta sessões.
+	ErrSessionUnavailable = errors.New("o executor não suporta sessões de lab")
+	// ErrNoSession indica que o workspace não tem uma sessão ativa.
+	ErrNoSession = errors.New("não há sessão ativa para o lab")
+	// ErrSessionBusy indica que a sessão ainda está a correr um passo.
+	ErrSessionBusy = errors.New("a sessão já está a executar um passo")
+)
+
+// sessionReapInterval é o intervalo entre as passagens do ReapIdleSessions.
+const sessionReapInterval = 30 * time.Second
+
+// LabSession descreve a sessão de lab de um workspace: um container que vive
+// entre execuções, até ser terminada ou ficar inativa.
+type LabSession struct {
+	ID           string    `json:"id"`
+	WorkspaceID  string    `json:"workspace_id"`
+	LabID        string    `json:"lab_id"`
+	Type         string    `json:"type"`
+	StartedAt    time.Time `json:"started_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	Busy         bool      `json:"busy"`
+}
+
+// liveSession é uma sessão aberta. Ocupa o workspace no scheduler até
+// terminar, com um slot interativo como os terminais; os passos que nela
+// correm não voltam à fila, mas correm um de cada vez.
+type liveSession struct {
+	session Session
+	release func()
+	userID  string
+	// config é a configuração com que a sessão foi iniciada (tipo, imagem,
+	// limites e validação), base dos comandos e validações
+	config domain.ExecutionConfig
+
+	mu      sync.Mutex
+	info    LabSession
+	current *LiveExecution
+}
+
+func (l *liveSession) snapshot() *LabSession {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info := l.info
+	info.Busy = l.current != nil && l.current.Running()
+	return &info
+}
+
+func (l *liveSession) touch() {
+	l.mu.Lock()
+	l.info.LastActiveAt = time.Now()
+	l.mu.Unlock()
+}
+
+// StartSession inicia a sessão de lab do utilizador, ou devolve a que já
+// existe (created falso). O pedido aguarda enquanto não houver um slot
+// interativo livre no scheduler, ou enquanto outro pedido arranca a sessão
+// do mesmo workspace.
+func (s *LabService) StartSession(ctx context.Context, userID, labID string) (session *LabSession, created bool, err error) {
+	provider, ok := s.executor.(SessionProvider)
+	if !ok {
+		return nil, false, ErrSessionUnavailable
+	}
+
+	lab, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := s.checkUnlocked(ctx, userID, lab); err != nil {
+		return nil, false, err
+	}
+	live, done, err := s.claimSessionStart(ctx, ws.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if live != nil {
+		live.touch()
+		return live.snapshot(), false, nil
+	}
+	defer done()
+
+	if err := s.checkImage(ctx, lab.Image); err != nil {
+		return nil, false, err
+	}
+
+	release, err := s.scheduler.AcquireInteractive(ctx, userID, ws.ID, func(int) {})
+	if err != nil {
+		return nil, false, fmt.Errorf("sessão cancelada enquanto aguardava na fila: %w", err)
+	}
+	if release, err = s.lockWorkspace(ctx, ws.ID, release); err != nil {
+		return nil, false, err
+	}
+
+	config, err := s.interactiveConfig(ctx, lab, ws.ID)
+	if err != nil {
+		release()
+		return nil, false, err
+	}
+
+	sess, err := provider.StartSession(ctx, config)
+	if err != nil {
+		release()
+		return nil, false, fmt.Errorf("falha ao iniciar sessão no workspace %s: %w", ws.ID, err)
+	}
+
+	now := time.Now()
+	live = &liveSession{
+		session: sess,
+		release: release,
+		userID:  userID,
+		config:  config,
+		info: LabSession{
+			ID:           uuid.New().String(),
+			WorkspaceID:  ws.ID,
+			LabID:        labID,
+			Type:         lab.Type,
+			StartedAt:    now,
+			LastActiveAt: now,
+		},
+	}
+
+	s.sessionsMu.Lock()
+	s.sessions[ws.ID] = live
+	s.sessionsMu.Unlock()
+
+	log.Printf("INFO [LabService]: Sessão %s iniciada no workspace %s", live.info.ID, ws.ID)
+	return live.snapshot(), true, nil
+}
+
+// interactiveConfig monta a configuração de uma sessão ou terminal com o
+// código, o estado e os ficheiros atuais do workspace. É chamada depois de
+// obtido o slot: enquanto o pedido aguardava, uma execução do workspace pode
+// tê-los mudado.
+func (s *LabService) interactiveConfig(ctx context.Context, lab *domain.Lab, workspaceID string) (domain.ExecutionConfig, error) {
+	ws, err := s.repo.GetWorkspaceByID(ctx, workspaceID)
+	if err != nil {
+		return domain.ExecutionConfig{}, fmt.Errorf("falha ao buscar workspace %s: %w", workspaceID, err)
+	}
+	if ws == nil {
+		return domain.ExecutionConfig{}, fmt.Errorf("workspace %s não encontrado", workspaceID)
+	}
+
+	files, err := s.workspaceFiles(ctx, ws.ID)
+	if err != nil {
+		return domain.ExecutionConfig{}, err
+	}
+
+	return domain.ExecutionConfig{
+		WorkspaceID:    ws.ID,
+		Code:           ws.UserCode,
+		State:          ws.State,
+		ValidationCode: lab.ValidationCode,
+		Checks:         lab.Checks,
+		Type:           domain.ExecutionType(lab.Type),
+		Limits:         lab.Limits,
+		Image:          lab.Image,
+		Files:          files,
+	}, nil
+}
+
+// GetSession devolve a sessão ativa do utilizador no lab.
+func (s *LabService) GetSession(ctx context.Context, userID, labID string) (*LabSession, error) {
+	_, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	live := s.sessionFor(ws.ID)
+	if live == nil {
+		return nil, ErrNoSession
+	}
+	return live.snapshot(), nil
+}
+
+// StopSession termina a sessão do utilizador no lab, interrompendo o passo
+// em curso e removendo o container.
+func (s *LabService) StopSession(ctx context.Context, userID, labID string) error {
+	_, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return err
+	}
+	if !s.stopSession(ws.ID, "terminada pelo utilizador") {
+		return ErrNoSession
+	}
+	return nil
+}
+
+// RunCommand corre um comando shell na sessão do utilizador, em /workspace.
+func (s *LabService) RunCommand(ctx context.Context, userID, labID, command string) (*LiveExecution, error) {
+	if strings.TrimSpace(command) == "" {
+		return nil, fmt.Errorf("comando vazio")
+	}
+
+	_, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	live := s.sessionFor(ws.ID)
+	if live == nil {
+		return nil, ErrNoSession
+	}
+
+	config := live.config
+	config.Mode = domain.ModeCommand
+	config.Code = command
+	return s.runInSession(ctx, live, userID, domain.ActionCommand, config, nil)
+}
+
+// ReapIdleSessions termina as sessões sem passos há mais de idleTimeout,
+// até ctx ser cancelado. Com idleTimeout <= 0 as sessões só terminam a
+// pedido.
+func (s *LabService) ReapIdleSessions(ctx context.Context, idleTimeout time.Duration) {
+	if idleTimeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(min(sessionReapInterval, idleTimeout))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.sessionsMu.Lock()
+		var idle []string
+		for wsID, live := range s.sessions {
+			info := live.snapshot()
+			if info.Busy {
+				// A inatividade conta a partir do fim do passo
+				live.touch()
+				continue
+			}
+			if time.Since(info.LastActiveAt) > idleTimeout {
+				idle = append(idle, wsID)
+			}
+		}
+		s.sessionsMu.Unlock()
+
+		for _, wsID := range idle {
+			s.stopSession(wsID, "inativa")
+		}
+	}
+}
+
+// claimSessionStart devolve a sessão do workspace, se houver. Caso
+// contrário marca o workspace como a arrancar uma sessão até done ser
+// chamado; os pedidos seguintes aguardam e recebem a sessão arrancada.
+func (s *LabService) claimSessionStart(ctx context.Context, workspaceID string) (live *liveSession, done func(), err error) {
+	for {
+		s.sessionsMu.Lock()
+		if live := s.sessions[workspaceID]; live != nil {
+			s.sessionsMu.Unlock()
+			return live, nil, nil
+		}
+		starting, ok := s.startingSessions[workspaceID]
+		if !ok {
+			starting = make(chan struct{})
+			s.startingSessions[workspaceID] = starting
+			s.sessionsMu.Unlock()
+			return nil, func() {
+				s.sessionsMu.Lock()
+				delete(s.startingSessions, workspaceID)
+				s.sessionsMu.Unlock()
+				close(starting)
+			}, nil
+		}
+		s.sessionsMu.Unlock()
+
+		select {
+		case <-starting:
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("sessão cancelada enquanto aguardava o arranque: %w", ctx.Err())
+		}
+	}
+}
+
+func (s *LabService) sessionFor(workspaceID string) *liveSession {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	return s.sessions[workspaceID]
+}
+
+// stopSession termina a sessão do workspace, se houver.
+func (s *LabService) stopSession(workspaceID, reason string) bool {
+	s.sessionsMu.Lock()
+	live, ok := s.sessions[workspaceID]
+	delete(s.sessions, workspaceID)
+	s.sessionsMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	live.mu.Lock()
+	if live.current != nil {
+		live.current.Cancel()
+	}
+	live.mu.Unlock()
+
+	live.session.Close()
+	live.release()
+	log.Printf("INFO [LabService]: Sessão %s do workspace %s terminada (%s)", live.info.ID, workspaceID, reason)
+	return true
+}
+
+// runInSession corre um passo no container da sessão. A sessão já ocupa o
+// slot do workspace, pelo que o passo não passa pelo scheduler.
+func (s *LabService) runInSession(ctx context.Context, live *liveSession, userID, action string, config domain.ExecutionConfig, rev *domain.CodeRevision) (*LiveExecution, error) {
+	live.mu.Lock()
+	defer live.mu.Unlock()
+
+	if live.current != nil && live.current.Running() {
+		return nil, ErrSessionBusy
+	}
+	live.info.LastActiveAt = time.Now()
+	live.current = s.launchOn(ctx, live.session, nil, userID, action, config, rev)
+	return live.current, nil
+}
@@ -0,0 +1,92 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+func TestStartSessionConcurrent(t *testing.T) {
+	ctx := context.Background()
+	provider := &fakeSessions{}
+	scheduler := service.NewExecutionScheduler(4, 1, 4)
+	_, labs, user := newTestLabs(t, provider, scheduler)
+	lab := createTestLab(t, labs, "Shell", "linux")
+	// Cria o workspace antes dos pedidos em paralelo
+	if _, err := labs.GetLabDetails(ctx, user.ID, lab.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	const requests = 5
+	var (
+		wg      sync.WaitGroup
+		created atomic.Int32
+		ids     [requests]string
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session, isNew, err := labs.StartSession(ctx, user.ID, lab.ID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if isNew {
+				created.Add(1)
+			}
+			ids[i] = session.ID
+		}(i)
+	}
+	wg.Wait()
+
+	if created.Load() != 1 || provider.started.Load() != 1 {
+		t.Fatalf("%d sessões criadas e %d arrancadas, esperava 1", created.Load(), provider.started.Load())
+	}
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Fatalf("pedidos receberam sessões diferentes: %v", ids)
+		}
+	}
+
+	// A sessão não ocupa os slots das execuções do utilizador
+	if stats := scheduler.Stats(); stats.Running != 0 || stats.Interactive != 1 {
+		t.Fatalf("scheduler: %+v, esperava só a sessão como interativa", stats)
+	}
+	if err := labs.StopSession(ctx, user.ID, lab.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateLabInSession(t *testing.T) {
+	ctx := context.Background()
+	provider := &fakeSessions{}
+	_, labs, user := newTestLabs(t, provider, service.NewExecutionScheduler(4, 1, 4))
+	lab, err := labs.CreateLab(ctx, "Shell", "linux", "", "echo ola", "", 0, "test -f /tmp/ok", domain.ResourceLimits{}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := labs.StartSession(ctx, user.ID, lab.ID); err != nil {
+		t.Fatal(err)
+	}
+	defer labs.StopSession(ctx, user.ID, lab.ID)
+
+	run, err := labs.ValidateLab(ctx, user.ID, lab.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFinal(t, run)
+
+	step := provider.lastStep()
+	if step.Mode != domain.ModeValidate || step.ValidationCode != "test -f /tmp/ok" {
+		t.Fatalf("passo na sessão com modo %q e validação %q, esperava a validação do lab", step.Mode, step.ValidationCode)
+	}
+	if step.Code != "echo ola" {
+		t.Fatalf("código %q, esperava o código do workspace", step.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"log"
+)
+
+var (
+	// ErrStateVersionNotFound indica que a versão do estado pedida não existe.
+	ErrStateVersionNotFound = errors.New("versão do estado não encontrada")
+	// ErrWorkspaceBusy indica que o workspace tem uma execução ou sessão em
+	// curso que ainda pode gravar o estado.
+	ErrWorkspaceBusy = errors.New("o workspace tem uma execução ou sessão em curso")
+)
+
+// ListStateVersions devolve o histórico do estado Terraform do workspace do
+// utilizador, da versão mais recente para a mais antiga.
+func (s *LabService) ListStateVersions(ctx context.Context, userID, labID string) ([]*domain.StateVersion, error) {
+	_, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.repo.ListStateVersions(ctx, ws.ID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar versões do estado do workspace %s: %w", ws.ID, err)
+	}
+	if versions == nil {
+		versions = []*domain.StateVersion{}
+	}
+	return versions, nil
+}
+
+// GetStateVersion devolve uma versão do estado, com o conteúdo do tfstate.
+func (s *LabService) GetStateVersion(ctx context.Context, userID, labID string, version int) (*domain.StateVersion, error) {
+	_, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	return s.stateVersion(ctx, ws.ID, version)
+}
+
+// DiffStateVersions compara duas versões do estado por endereço de recurso.
+func (s *LabService) DiffStateVersions(ctx context.Context, userID, labID string, from, to int) (*domain.StateDiff, error) {
+	_, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := s.stateVersion(ctx, ws.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.stateVersion(ctx, ws.ID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := domain.DiffStates(before.State, after.State)
+	if err != nil {
+		return nil, err
+	}
+	diff.From, diff.To = from, to
+	return diff, nil
+}
+
+// RollbackState repõe uma versão antiga como estado atual do workspace. A
+// reposição fica no histórico como uma nova versão; os recursos já criados
+// no LocalStack não são alterados até à próxima execução. É recusada com
+// ErrWorkspaceBusy enquanto houver uma execução ou sessão no workspace, que
+// gravaria o seu estado por cima do reposto.
+func (s *LabService) RollbackState(ctx context.Context, userID, labID string, version int) (*domain.StateVersion, error) {
+	_, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	if s.sessionFor(ws.ID) != nil || s.workspaceRunning(ws.ID) {
+		return nil, ErrWorkspaceBusy
+	}
+
+	// O slot cobre as execuções pedidas entretanto e, com o lock, as das
+	// outras réplicas: a reposição espera que terminem
+	release, err := s.scheduler.Acquire(ctx, userID, ws.ID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reposição do estado do workspace %s cancelada enquanto aguardava na fila: %w", ws.ID, err)
+	}
+	if release, err = s.lockWorkspace(ctx, ws.ID, release); err != nil {
+		return nil, err
+	}
+	defer release()
+
+	restored, err := s.repo.RestoreStateVersion(ctx, ws.ID, version)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao repor a versão %d do estado do workspace %s: %w", version, ws.ID, err)
+	}
+	if restored == nil {
+		return nil, ErrStateVersionNotFound
+	}
+
+	log.Printf("INFO [LabService]: Estado do workspace %s reposto para a versão %d (nova versão %d)", ws.ID, version, restored.Version)
+	return restored, nil
+}
+
+func (s *LabService) stateVersion(ctx context.Context, workspaceID string, version int) (*domain.StateVersion, error) {
+	v, err := s.repo.GetStateVersion(ctx, workspaceID, version)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar a versão %d do estado do workspace %s: %w", version, workspaceID, err)
+	}
+	if v == nil {
+		return nil, ErrStateVersionNotFound
+	}
+	return v, nil
+}
@@ -0,0 +1,49 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+func TestRollbackStateRefusedWhileRunning(t *testing.T) {
+	ctx := context.Background()
+	executor := &stateExecutor{gate: make(chan struct{})}
+	repo, labs, user := newTestLabs(t, executor, service.NewExecutionScheduler(4, 1, 0))
+	lab := createTestLab(t, labs, "Rede", "terraform")
+
+	first, err := labs.ExecuteLab(ctx, user.ID, lab.ID, "", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// O apply ainda não gravou o estado: a reposição seria sobrescrita
+	if _, err := labs.RollbackState(ctx, user.ID, lab.ID, 1); !errors.Is(err, service.ErrWorkspaceBusy) {
+		t.Fatalf("RollbackState durante o apply: erro %v, esperava ErrWorkspaceBusy", err)
+	}
+	close(executor.gate)
+	waitFinal(t, first)
+
+	second, err := labs.ExecuteLab(ctx, user.ID, lab.ID, "", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFinal(t, second)
+
+	restored, err := labs.RollbackState(ctx, user.ID, lab.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Version != 3 || restored.RestoredFrom == nil || *restored.RestoredFrom != 1 {
+		t.Fatalf("versão reposta: %+v", restored)
+	}
+	ws, err := repo.GetWorkspaceByUserAndLab(ctx, user.ID, lab.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ws.State) != "+" {
+		t.Fatalf("estado depois da reposição = %q, esperava %q", ws.State, "+")
+	}
+}
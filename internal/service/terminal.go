@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTerminalUnavailable indica que o executor não abre terminais.
+	ErrTerminalUnavailable = errors.New("o executor não suporta terminais interativos")
+	// ErrTerminalUnsupported indica um pedido de terminal num tipo de lab sem shell.
+	ErrTerminalUnsupported = errors.New("terminal disponível apenas em labs linux, docker e kubernetes")
+	// ErrValidationRunning indica que o terminal já tem uma validação em curso.
+	ErrValidationRunning = errors.New("já existe uma validação em curso")
+)
+
+// Motivos de fecho de um terminal, enviados ao cliente.
+const (
+	TerminalClosedIdle    = "sessão terminada por inatividade"
+	TerminalClosedExpired = "sessão atingiu a duração máxima"
+	TerminalClosedExited  = "o shell terminou"
+	TerminalClosedClient  = "sessão fechada pelo cliente"
+)
+
+// terminalTypes são os tipos de lab em que faz sentido um shell interativo.
+var terminalTypes = map[domain.ExecutionType]bool{
+	domain.TypeLinux:  true,
+	domain.TypeDocker: true,
+	domain.TypeK8s:    true,
+}
+
+// TerminalLimits limita a duração dos terminais. Zero desativa o limite.
+type TerminalLimits struct {
+	// IdleTimeout fecha o terminal quando o utilizador não escreve nada.
+	IdleTimeout time.Duration
+	// MaxDuration fecha o terminal ao fim deste tempo, haja ou não atividade.
+	MaxDuration time.Duration
+}
+
+// TerminalService abre terminais interativos nos containers dos labs.
+type TerminalService struct {
+	labs      *LabService
+	terminals TerminalProvider
+	limits    TerminalLimits
+}
+
+// NewTerminalService cria o serviço. terminals pode ser nil quando o
+// executor não suporta terminais.
+func NewTerminalService(labs *LabService, terminals TerminalProvider, limits TerminalLimits) *TerminalService {
+	return &TerminalService{labs: labs, terminals: terminals, limits: limits}
+}
+
+// TerminalSession é um terminal aberto no container do workspace de um
+// utilizador. Ocupa o workspace no scheduler até fechar, pelo que outras
+// execuções do mesmo workspace aguardam na fila; as dos outros labs do
+// utilizador não (ver AcquireInteractive).
+type TerminalSession struct {
+	ID                 string     `json:"id"`
+	WorkspaceID        string     `json:"workspace_id"`
+	StartedAt          time.Time  `json:"started_at"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	IdleTimeoutSeconds int        `json:"idle_timeout_seconds,omitempty"`
+
+	labs     *LabService
+	userID   string
+	terminal Terminal
+	config   domain.ExecutionConfig
+	release  func()
+	// session é a sessão de lab em que o terminal foi aberto, se houver
+	session *liveSession
+
+	idleTimeout time.Duration
+	idle        *time.Timer
+	expiry      *time.Timer
+
+	mu         sync.Mutex
+	validation *LiveExecution
+	closeOnce  sync.Once
+	reason     string
+	done       chan struct{}
+}
+
+// Open abre um terminal no workspace do utilizador. Enquanto aguarda o
+// workspace ou um slot interativo, onQueued recebe a posição na fila.
+func (s *TerminalService) Open(ctx context.Context, userID, labID string, onQueued func(position int)) (*TerminalSession, error) {
+	if s.terminals == nil {
+		return nil, ErrTerminalUnavailable
+	}
+
+	lab, ws, err := s.labs.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	if !terminalTypes[domain.ExecutionType(lab.Type)] {
+		return nil, ErrTerminalUnsupported
+	}
+	if err := s.labs.checkUnlocked(ctx, userID, lab); err != nil {
+		return nil, err
+	}
+	if err := s.labs.checkImage(ctx, lab.Image); err != nil {
+		return nil, err
+	}
+
+	// Com uma sessão aberta o shell corre no seu container, que continua
+	// depois de o terminal fechar
+	if live := s.labs.sessionFor(ws.ID); live != nil {
+		terminal, err := live.session.OpenShell(ctx, live.config)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao abrir terminal na sessão do workspace %s: %w", ws.ID, err)
+		}
+		session := newTerminalSession(s.labs, userID, terminal, live.config, func() {}, s.limits)
+		session.session = live
+		live.touch()
+		log.Printf("INFO [TerminalService]: Terminal %s aberto na sessão do workspace %s", session.ID, ws.ID)
+		return session, nil
+	}
+
+	release, err := s.labs.scheduler.AcquireInteractive(ctx, userID, ws.ID, onQueued)
+	if err != nil {
+		return nil, fmt.Errorf("terminal cancelado enquanto aguardava na fila: %w", err)
+	}
+	if release, err = s.labs.lockWorkspace(ctx, ws.ID, release); err != nil {
+		return nil, err
+	}
+
+	config, err := s.labs.interactiveConfig(ctx, lab, ws.ID)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	terminal, err := s.terminals.OpenTerminal(ctx, config)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("falha ao abrir terminal no workspace %s: %w", ws.ID, err)
+	}
+
+	session := newTerminalSession(s.labs, userID, terminal, config, release, s.limits)
+	log.Printf("INFO [TerminalService]: Terminal %s aberto no workspace %s", session.ID, ws.ID)
+	return session, nil
+}
+
+func newTerminalSession(labs *LabService, userID string, terminal Terminal, config domain.ExecutionConfig, release func(), limits TerminalLimits) *TerminalSession {
+	t := &TerminalSession{
+		ID:          uuid.New().String(),
+		WorkspaceID: config.WorkspaceID,
+		StartedAt:   time.Now(),
+		labs:        labs,
+		userID:      userID,
+		terminal:    terminal,
+		config:      config,
+		release:     release,
+		idleTimeout: limits.IdleTimeout,
+		done:        make(chan struct{}),
+	}
+
+	// Os timers podem disparar antes de serem atribuídos: Close lê-os com mu
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if limits.IdleTimeout > 0 {
+		t.IdleTimeoutSeconds = int(limits.IdleTimeout / time.Second)
+		t.idle = time.AfterFunc(limits.IdleTimeout, func() { t.Close(TerminalClosedIdle) })
+	}
+	if limits.MaxDuration > 0 {
+		expiresAt := t.StartedAt.Add(limits.MaxDuration)
+		t.ExpiresAt = &expiresAt
+		t.expiry = time.AfterFunc(limits.MaxDuration, func() { t.Close(TerminalClosedExpired) })
+	}
+	return t
+}
+
+// Read lê o output do terminal. Devolve erro quando o shell termina ou a
+// sessão é fechada.
+func (t *TerminalSession) Read(p []byte) (int, error) {
+	return t.terminal.Read(p)
+}
+
+// Write envia as teclas do utilizador e reinicia o tempo de inatividade.
+func (t *TerminalSession) Write(p []byte) (int, error) {
+	if t.idle != nil {
+		t.idle.Reset(t.idleTimeout)
+	}
+	if t.session != nil {
+		t.session.touch()
+	}
+	return t.terminal.Write(p)
+}
+
+// Resize ajusta o tamanho do TTY à janela do cliente.
+func (t *TerminalSession) Resize(cols, rows uint) error {
+	if cols == 0 || rows == 0 {
+		return fmt.Errorf("tamanho de terminal inválido: %dx%d", cols, rows)
+	}
+	return t.terminal.Resize(cols, rows)
+}
+
+// Validate corre a validação do lab no container do terminal. O resultado
+// é registado e gravado como o de uma validação normal (ver ValidateLab).
+func (t *TerminalSession) Validate(ctx context.Context) (*LiveExecution, error) {
+	if t.config.ValidationCode == "" && len(t.config.Checks) == 0 {
+		return nil, fmt.Errorf("lab não possui código de validação")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Reason() != "" {
+		return nil, fmt.Errorf("o terminal está fechado")
+	}
+	if t.validation != nil && t.validation.Running() {
+		return nil, ErrValidationRunning
+	}
+
+	// Numa sessão a validação corre como qualquer passo da sessão
+	if t.session != nil {
+		config := t.config
+		config.Mode = domain.ModeValidate
+		run, err := t.labs.runInSession(ctx, t.session, t.userID, domain.ActionValidate, config, nil)
+		if err != nil {
+			return nil, err
+		}
+		t.validation = run
+		return run, nil
+	}
+
+	// O terminal já ocupa o slot do workspace: a validação não volta à fila
+	t.validation = t.labs.launchOn(ctx, t.terminal, nil, t.userID, domain.ActionValidate, t.config, nil)
+	return t.validation, nil
+}
+
+// Close fecha o terminal, remove o container e liberta o slot do scheduler.
+// Só o primeiro motivo fica registado.
+func (t *TerminalSession) Close(reason string) {
+	t.closeOnce.Do(func() {
+		t.reason = reason
+
+		t.mu.Lock()
+		if t.idle != nil {
+			t.idle.Stop()
+		}
+		if t.expiry != nil {
+			t.expiry.Stop()
+		}
+		if t.validation != nil {
+			t.validation.Cancel()
+		}
+		t.mu.Unlock()
+
+		t.terminal.Close()
+		t.release()
+		log.Printf("INFO [TerminalService]: Terminal %s fechado: %s", t.ID, reason)
+		close(t.done)
+	})
+}
+
+// Done é fechado quando o terminal fecha; Reason diz porquê.
+func (t *TerminalSession) Done() <-chan struct{} {
+	return t.done
+}
+
+// Reason devolve o motivo do fecho (vazio enquanto o terminal está aberto).
+func (t *TerminalSession) Reason() string {
+	select {
+	case <-t.done:
+		return t.reason
+	default:
+		return ""
+	}
+}
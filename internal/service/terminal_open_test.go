@@ -0,0 +1,53 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+func TestRunWhileTerminalOpen(t *testing.T) {
+	ctx := context.Background()
+
+	// Uma execução por utilizador: antes, o terminal ocupava-a
+	_, labs, user := newTestLabs(t, fakeExecutor{}, service.NewExecutionScheduler(4, 1, 4))
+	terminals := service.NewTerminalService(labs, fakeExecutor{}, service.TerminalLimits{})
+
+	shell := createTestLab(t, labs, "Shell", "linux")
+	other := createTestLab(t, labs, "Outro", "linux")
+
+	terminal, err := terminals.Open(ctx, user.ID, shell.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer terminal.Close(service.TerminalClosedClient)
+
+	run, err := labs.ExecuteLab(ctx, user.ID, other.ID, "echo ola", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	for {
+		lines, changed, final := run.Since(0)
+		for _, line := range lines {
+			if line.QueuePosition > 0 {
+				t.Fatalf("execução ficou na fila (posição %d) com o terminal aberto", line.QueuePosition)
+			}
+		}
+		if final != nil {
+			if final.Outcome != domain.OutcomeSuccess {
+				t.Fatalf("desfecho %q, esperava %q", final.Outcome, domain.OutcomeSuccess)
+			}
+			return
+		}
+		select {
+		case <-changed:
+		case <-runCtx.Done():
+			t.Fatal("a execução não começou com o terminal aberto")
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lab-devops/internal/service"
+)
+
+// openFakeTerminal abre um terminal num lab Linux com os limites dados.
+func openFakeTerminal(t *testing.T, limits service.TerminalLimits) (*service.TerminalSession, *fakeTerminal, *service.ExecutionScheduler) {
+	t.Helper()
+	terminals := &fakeTerminals{}
+	scheduler := service.NewExecutionScheduler(4, 1, 4)
+	_, labs, user := newTestLabs(t, terminals, scheduler)
+	lab := createTestLab(t, labs, "Shell", "linux")
+
+	session, err := service.NewTerminalService(labs, terminals, limits).Open(context.Background(), user.ID, lab.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session, terminals.last(), scheduler
+}
+
+func expectClosed(t *testing.T, session *service.TerminalSession, reason string) {
+	t.Helper()
+	select {
+	case <-session.Done():
+	case <-time.After(time.Second):
+		t.Fatal("terminal deveria ter fechado")
+	}
+	if session.Reason() != reason {
+		t.Fatalf("motivo = %q, esperado %q", session.Reason(), reason)
+	}
+}
+
+func TestTerminalSessionIdleTimeout(t *testing.T) {
+	session, term, scheduler := openFakeTerminal(t, service.TerminalLimits{IdleTimeout: 80 * time.Millisecond})
+
+	// A escrita adia o fecho por inatividade
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		session.Write([]byte("ls\r"))
+	}
+	if session.Reason() != "" {
+		t.Fatalf("terminal fechou apesar da atividade: %s", session.Reason())
+	}
+
+	expectClosed(t, session, service.TerminalClosedIdle)
+	if !term.closed.Load() || scheduler.Stats().Interactive != 0 {
+		t.Fatal("o fecho deveria fechar o terminal e libertar o slot")
+	}
+}
+
+func TestTerminalSessionMaxDuration(t *testing.T) {
+	session, _, _ := openFakeTerminal(t, service.TerminalLimits{IdleTimeout: time.Minute, MaxDuration: 100 * time.Millisecond})
+	if session.ExpiresAt == nil {
+		t.Fatal("ExpiresAt deveria estar definido")
+	}
+
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		session.Write([]byte("x"))
+	}
+	expectClosed(t, session, service.TerminalClosedExpired)
+
+	// Fechos seguintes não alteram o motivo
+	session.Close(service.TerminalClosedClient)
+	if session.Reason() != service.TerminalClosedExpired {
+		t.Fatalf("motivo alterado para %q", session.Reason())
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"lab-devops/internal/domain"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type UserService struct {
+	repo WorkspaceRepository
+}
+
+func NewUserService(repo WorkspaceRepository) *UserService {
+	return &UserService{
+		repo: repo,
+	}
+}
+
+func (s *UserService) CreateUser(ctx context.Context, name, email, role string) (*domain.User, error) {
+	name = strings.TrimSpace(name)
+	email = strings.TrimSpace(email)
+	if name == "" || email == "" {
+		return nil, fmt.Errorf("nome e email são obrigatórios")
+	}
+	if role == "" {
+		role = domain.RoleLearner
+	}
+	if !domain.IsValidRole(role) {
+		return nil, fmt.Errorf("papel inválido: %s", role)
+	}
+
+	newUser := &domain.User{
+		ID:    uuid.New().String(),
+		Name:  name,
+		Email: email,
+		Role:  role,
+	}
+
+	if err := s.repo.CreateUser(ctx, newUser); err != nil {
+		return nil, fmt.Errorf("falha ao criar utilizador: %w", err)
+	}
+
+	return newUser, nil
+}
+
+func (s *UserService) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar utilizador %s: %w", userID, err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("utilizador com ID %s não encontrado", userID)
+	}
+	return user, nil
+}
+
+func (s *UserService) ListUsers(ctx context.Context) ([]*domain.User, error) {
+	users, err := s.repo.ListUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar utilizadores: %w", err)
+	}
+	return users, nil
+}
+
+func (s *UserService) UpdateUserRole(ctx context.Context, userID, role string) (*domain.User, error) {
+	if !domain.IsValidRole(role) {
+		return nil, fmt.Errorf("papel inválido: %s", role)
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateUserRole(ctx, userID, role); err != nil {
+		return nil, fmt.Errorf("falha ao atualizar papel do utilizador %s: %w", userID, err)
+	}
+
+	user.Role = role
+	return user, nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab-devops/internal/domain"
+	"sort"
+)
+
+var (
+	// ErrFileNotFound indica que o ficheiro pedido não existe no workspace.
+	ErrFileNotFound = errors.New("ficheiro não encontrado")
+	// ErrInvalidFile indica um caminho ou conteúdo de ficheiro inválido.
+	ErrInvalidFile = errors.New("ficheiro inválido")
+)
+
+// ListWorkspaceFiles devolve a árvore de ficheiros do workspace do utilizador
+// (sem conteúdo), incluindo o ficheiro principal do lab.
+func (s *LabService) ListWorkspaceFiles(ctx context.Context, userID, labID string) ([]*domain.WorkspaceFile, error) {
+	_, ws, err := s.labDetails(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range ws.Files {
+		f.Content = ""
+	}
+	return ws.Files, nil
+}
+
+func (s *LabService) ReadWorkspaceFile(ctx context.Context, userID, labID, filePath string) (*domain.WorkspaceFile, error) {
+	_, ws, err := s.labDetails(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = cleanFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range ws.Files {
+		if f.Path == filePath {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFileNotFound, filePath)
+}
+
+// WriteWorkspaceFile cria ou substitui um ficheiro do workspace. Escrever o
+// ficheiro principal do lab equivale a atualizar o código do utilizador.
+func (s *LabService) WriteWorkspaceFile(ctx context.Context, userID, labID, filePath, content string) (*domain.WorkspaceFile, error) {
+	lab, ws, err := s.labDetails(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := domain.FileChanges{Files: map[string]string{filePath: content}}
+	if _, err := s.applyFileChanges(ctx, lab, ws, "", changes); err != nil {
+		return nil, err
+	}
+	return s.ReadWorkspaceFile(ctx, userID, labID, filePath)
+}
+
+func (s *LabService) DeleteWorkspaceFile(ctx context.Context, userID, labID, filePath string) error {
+	if _, err := s.ReadWorkspaceFile(ctx, userID, labID, filePath); err != nil {
+		return err
+	}
+	lab, ws, err := s.labDetails(ctx, userID, labID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.applyFileChanges(ctx, lab, ws, "", domain.FileChanges{Deleted: []string{filePath}})
+	return err
+}
+
+// workspaceTree devolve todos os ficheiros do workspace, com o ficheiro
+// principal (UserCode) em primeiro lugar.
+func (s *LabService) workspaceTree(ctx context.Context, lab *domain.Lab, ws *domain.Workspace) ([]*domain.WorkspaceFile, error) {
+	extra, err := s.repo.ListWorkspaceFiles(ctx, ws.ID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar ficheiros do workspace %s: %w", ws.ID, err)
+	}
+
+	entry := &domain.WorkspaceFile{
+		Path:      domain.EntryFile(domain.ExecutionType(lab.Type)),
+		Content:   ws.UserCode,
+		Size:      len(ws.UserCode),
+		UpdatedAt: ws.UpdatedAt,
+	}
+	return append([]*domain.WorkspaceFile{entry}, extra...), nil
+}
+
+// applyFileChanges valida e grava as alterações à árvore do workspace,
+// devolvendo o código principal resultante. code (o user_code legado) só é
+// usado quando as alterações não incluem o ficheiro principal; vazio mantém
+// o código atual.
+func (s *LabService) applyFileChanges(ctx context.Context, lab *domain.Lab, ws *domain.Workspace, code string, changes domain.FileChanges) (string, error) {
+	entry := domain.EntryFile(domain.ExecutionType(lab.Type))
+	if code == "" {
+		code = ws.UserCode
+	}
+
+	files := make(map[string]string, len(changes.Files))
+	for p, content := range changes.Files {
+		cleaned, err := cleanFilePath(p)
+		if err != nil {
+			return "", err
+		}
+		if len(content) > domain.MaxWorkspaceFileSize {
+			return "", fmt.Errorf("%w: %s excede %d bytes", ErrInvalidFile, cleaned, domain.MaxWorkspaceFileSize)
+		}
+		if cleaned == entry {
+			code = content
+			continue
+		}
+		files[cleaned] = content
+	}
+
+	deleted := make([]string, 0, len(changes.Deleted))
+	for _, p := range changes.Deleted {
+		cleaned, err := cleanFilePath(p)
+		if err != nil {
+			return "", err
+		}
+		if cleaned == entry {
+			return "", fmt.Errorf("%w: o ficheiro principal %s não pode ser apagado", ErrInvalidFile, entry)
+		}
+		deleted = append(deleted, cleaned)
+	}
+
+	if changes.Replace {
+		if len(files)+1 > domain.MaxWorkspaceFiles {
+			return "", fmt.Errorf("%w: o workspace excede %d ficheiros", ErrInvalidFile, domain.MaxWorkspaceFiles)
+		}
+		if err := s.repo.ReplaceWorkspaceFiles(ctx, ws.ID, files); err != nil {
+			return "", fmt.Errorf("falha ao gravar ficheiros do workspace %s: %w", ws.ID, err)
+		}
+	} else if len(files) > 0 || len(deleted) > 0 {
+		existing, err := s.repo.ListWorkspaceFiles(ctx, ws.ID)
+		if err != nil {
+			return "", fmt.Errorf("falha ao listar ficheiros do workspace %s: %w", ws.ID, err)
+		}
+		count := make(map[string]bool, len(existing)+len(files))
+		for _, f := range existing {
+			count[f.Path] = true
+		}
+		for p := range files {
+			count[p] = true
+		}
+		for _, p := range deleted {
+			delete(count, p)
+		}
+		if len(count)+1 > domain.MaxWorkspaceFiles {
+			return "", fmt.Errorf("%w: o workspace excede %d ficheiros", ErrInvalidFile, domain.MaxWorkspaceFiles)
+		}
+
+		paths := make([]string, 0, len(files))
+		for p := range files {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			if err := s.repo.UpsertWorkspaceFile(ctx, ws.ID, p, files[p]); err != nil {
+				return "", fmt.Errorf("falha ao gravar %s: %w", p, err)
+			}
+		}
+		for _, p := range deleted {
+			if err := s.repo.DeleteWorkspaceFile(ctx, ws.ID, p); err != nil {
+				return "", fmt.Errorf("falha ao apagar %s: %w", p, err)
+			}
+		}
+	}
+
+	if code != ws.UserCode {
+		if err := s.repo.UpdateWorkspaceCode(ctx, ws.ID, code); err != nil {
+			return "", fmt.Errorf("falha ao atualizar workspace para o lab %s: %w", lab.ID, err)
+		}
+		ws.UserCode = code
+	}
+	return code, nil
+}
+
+// workspaceFiles devolve os ficheiros adicionais do workspace no formato
+// usado pelo executor.
+func (s *LabService) workspaceFiles(ctx context.Context, workspaceID string) (map[string]string, error) {
+	extra, err := s.repo.ListWorkspaceFiles(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar ficheiros do workspace %s: %w", workspaceID, err)
+	}
+	files := make(map[string]string, len(extra))
+	for _, f := range extra {
+		files[f.Path] = f.Content
+	}
+	return files, nil
+}
+
+func cleanFilePath(p string) (string, error) {
+	cleaned, err := domain.CleanFilePath(p)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return cleaned, nil
+}
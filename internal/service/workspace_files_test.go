@@ -0,0 +1,69 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"lab-devops/internal/service"
+)
+
+func TestWriteWorkspaceFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		wantPath string
+		wantCode bool // o ficheiro é o principal e atualiza o user_code
+		wantErr  error
+	}{
+		{"ficheiro adicional", "modules/net/main.tf", "modules/net/main.tf", false, nil},
+		{"caminho normalizado", "./modules//vars.tf", "modules/vars.tf", false, nil},
+		{"ficheiro principal", "main.tf", "main.tf", true, nil},
+		{"fora do workspace", "../lab.db", "", false, service.ErrInvalidFile},
+		{"caminho absoluto", "/etc/passwd", "", false, service.ErrInvalidFile},
+		{"ficheiro reservado", "provider.tf", "", false, service.ErrInvalidFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo, labs, user := newTestLabs(t, fakeExecutor{}, service.NewExecutionScheduler(4, 1, 0))
+			lab := createTestLab(t, labs, "Rede", "terraform")
+
+			file, err := labs.WriteWorkspaceFile(ctx, user.ID, lab.ID, tt.path, "# conteúdo")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("WriteWorkspaceFile(%q): erro %v, esperava %v", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WriteWorkspaceFile(%q): %v", tt.path, err)
+			}
+			if file.Path != tt.wantPath {
+				t.Errorf("ficheiro gravado em %q, esperava %q", file.Path, tt.wantPath)
+			}
+
+			ws, err := repo.GetWorkspaceByUserAndLab(ctx, user.ID, lab.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ws.UserCode == "# conteúdo"; got != tt.wantCode {
+				t.Errorf("user_code = %q depois de escrever %s", ws.UserCode, tt.path)
+			}
+		})
+	}
+}
+
+func TestDeleteEntryFileRefused(t *testing.T) {
+	ctx := context.Background()
+	_, labs, user := newTestLabs(t, fakeExecutor{}, service.NewExecutionScheduler(4, 1, 0))
+	lab := createTestLab(t, labs, "Rede", "terraform")
+	if _, err := labs.WriteWorkspaceFile(ctx, user.ID, lab.ID, "main.tf", "# conteúdo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := labs.DeleteWorkspaceFile(ctx, user.ID, lab.ID, "main.tf"); !errors.Is(err, service.ErrInvalidFile) {
+		t.Fatalf("apagar o ficheiro principal: erro %v, esperava ErrInvalidFile", err)
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"lab-devops/internal/domain"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// resetTimeout limita o reset do workspace depois de obtido o slot,
+// incluindo o "terraform destroy".
+const resetTimeout = 30 * time.Minute
+
+// DestroyLab corre "terraform destroy" com o código e o estado gravados no
+// workspace do utilizador, removendo os recursos que ele provisionou. O
+// estado é relido depois de obtido o slot (ver scheduleOn): um destroy pedido
+// durante um apply destrói também o que esse apply criou.
+func (s *LabService) DestroyLab(ctx context.Context, userID, labID string) (*LiveExecution, error) {
+	lab, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	if domain.ExecutionType(lab.Type) != domain.TypeTerraform {
+		return nil, ErrTerraformOnly
+	}
+
+	config, err := s.destroyConfig(ctx, lab, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	if session := s.sessionFor(ws.ID); session != nil {
+		return s.runInSession(ctx, session, userID, domain.ActionDestroy, config, nil)
+	}
+	return s.launch(ctx, userID, domain.ActionDestroy, config, nil), nil
+}
+
+// ResetWorkspace recomeça o lab: destrói os recursos Terraform do estado
+// (se houver), repõe o InitialCode, apaga os ficheiros adicionais, o estado
+// e a pontuação, e volta a pôr o workspace em curso. A sessão de lab, se
+// houver, é terminada e as execuções em curso são interrompidas. As dicas
+// reveladas continuam registadas.
+func (s *LabService) ResetWorkspace(ctx context.Context, userID, labID string) (*domain.Workspace, error) {
+	lab, ws, err := s.labAndWorkspace(ctx, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A sessão ocupa o slot do workspace e guarda o ambiente antigo
+	s.stopSession(ws.ID, "reset do workspace")
+	if err := s.cancelWorkspaceRuns(ctx, ws.ID); err != nil {
+		return nil, fmt.Errorf("reset do workspace %s cancelado enquanto aguardava as execuções em curso: %w", ws.ID, err)
+	}
+
+	// O slot do workspace fica reservado até ao fim, para que nenhuma
+	// execução use o código ou o estado enquanto são repostos
+	release, err := s.scheduler.Acquire(ctx, userID, ws.ID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reset do workspace %s cancelado enquanto aguardava na fila: %w", ws.ID, err)
+	}
+	if release, err = s.lockWorkspace(ctx, ws.ID, release); err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// A partir daqui o reset não depende da conexão do cliente
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resetTimeout)
+	defer cancel()
+
+	// As execuções interrompidas podem ter gravado um estado novo
+	if lab, ws, err = s.labAndWorkspace(ctx, userID, labID); err != nil {
+		return nil, err
+	}
+
+	if domain.ExecutionType(lab.Type) == domain.TypeTerraform && len(ws.State) > 0 {
+		config, err := s.destroyConfig(ctx, lab, ws)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("INFO [LabService]: A destruir recursos do workspace %s antes do reset", ws.ID)
+		state := waitFinalState(s.scheduleOn(ctx, s.executor, nil, uuid.New().String(), userID, domain.ActionDestroy, config))
+		if state.Error != nil {
+			// O destroy pode ter removido parte dos recursos antes de falhar
+			if len(state.NewState) > 0 {
+				s.logPersistError(s.SaveWorkspaceState(ctx, ws.ID, state.NewState))
+			}
+			return nil, fmt.Errorf("falha ao destruir recursos do workspace %s: %w", ws.ID, state.Error)
+		}
+	}
+
+	if err := s.repo.ResetWorkspace(ctx, ws.ID, lab.InitialCode); err != nil {
+		return nil, fmt.Errorf("falha ao repor workspace %s: %w", ws.ID, err)
+	}
+
+	_, ws, err = s.labDetails(ctx, userID, labID)
+	return ws, err
+}
+
+func (s *LabService) destroyConfig(ctx context.Context, lab *domain.Lab, ws *domain.Workspace) (domain.ExecutionConfig, error) {
+	if err := s.checkImage(ctx, lab.Image); err != nil {
+		return domain.ExecutionConfig{}, err
+	}
+
+	files, err := s.workspaceFiles(ctx, ws.ID)
+	if err != nil {
+		return domain.ExecutionConfig{}, err
+	}
+
+	return domain.ExecutionConfig{
+		WorkspaceID: ws.ID,
+		Code:        ws.UserCode,
+		State:       ws.State,
+		Type:        domain.ExecutionType(lab.Type),
+		Limits:      lab.Limits,
+		Image:       lab.Image,
+		Files:       files,
+		Mode:        domain.ModeDestroy,
+	}, nil
+}
+
+// waitFinalState consome os canais de uma execução até ao fim e devolve o
+// seu estado final.
+func waitFinalState(logStream <-chan ExecutionResult, finalState <-chan ExecutionFinalState) ExecutionFinalState {
+	final := ExecutionFinalState{Error: fmt.Errorf("execução terminou sem estado final"), Outcome: domain.OutcomeFailed}
+	for logStream != nil || finalState != nil {
+		select {
+		case _, ok := <-logStream:
+			if !ok {
+				logStream = nil
+			}
+		case state, ok := <-finalState:
+			if !ok {
+				finalState = nil
+				continue
+			}
+			final = state
+		}
+	}
+	return final
+}
@@ -0,0 +1,38 @@
+package service_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"lab-devops/internal/domain"
+	"lab-devops/internal/service"
+)
+
+func TestQueuedDestroyUsesStateOfPreviousRun(t *testing.T) {
+	ctx := context.Background()
+	executor := &stateExecutor{gate: make(chan struct{})}
+	_, labs, user := newTestLabs(t, executor, service.NewExecutionScheduler(4, 1, 0))
+	lab := createTestLab(t, labs, "Rede", "terraform")
+
+	apply, err := labs.ExecuteLab(ctx, user.ID, lab.ID, "", domain.FileChanges{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// O destroy é pedido antes de o apply gravar os recursos que criou
+	destroy, err := labs.DestroyLab(ctx, user.ID, lab.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(executor.gate)
+
+	waitFinal(t, apply)
+	if final := waitFinal(t, destroy); final.Outcome != domain.OutcomeSuccess {
+		t.Fatalf("destroy terminou com %q", final.Outcome)
+	}
+	if got := executor.received(); !slices.Equal(got, []string{"", "+"}) {
+		t.Fatalf("estados recebidos pelo executor: %q, esperava que o destroy recebesse o do apply", got)
+	}
+}
@@ -0,0 +1,2 @@
+#!/bin/sh
+# Escreve aqui os teus comandos
@@ -0,0 +1,4 @@
+#!/bin/sh
+set -e
+test "$(stat -c %a ola.sh)" = "700"
+./ola.sh | grep -q ola